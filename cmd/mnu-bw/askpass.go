@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	"github.com/netbrain/mnu/internal/bwref"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	uipkg "github.com/netbrain/mnu/internal/ui"
+)
+
+// Personalities selected by the name mnu-bw is invoked as.
+const (
+	askpassName  = "mnu-askpass"
+	pinentryName = "mnu-pinentry"
+)
+
+// errCancelled is returned when no secret was chosen.
+var errCancelled = errors.New("cancelled")
+
+// askpassSubcommand implements SSH_ASKPASS, SUDO_ASKPASS and GIT_ASKPASS:
+// the prompt arrives in the arguments and the secret goes to stdout.
+func askpassSubcommand(args []string) {
+	prompt := strings.Join(args, " ")
+
+	// OpenSSH asks for host key and agent confirmations through askpass
+	// too; those want a yes/no answer, never a secret.
+	switch os.Getenv("SSH_ASKPASS_PROMPT") {
+	case "confirm":
+		ok, err := uipkg.Confirm(prompt, 30*time.Second)
+		if err != nil || !ok {
+			os.Exit(exitError)
+		}
+		return
+	case "none":
+		return
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	mgr := unlockedManager(config)
+	secret, err := askpassSecret(mgr, config, prompt, "")
+	if errors.Is(err, errCancelled) {
+		os.Exit(exitError)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get secret: %v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Println(secret)
+}
+
+// askpassSecret answers a prompt with the first askpass rule whose pattern
+// matches it, or else with the password of an item picked in the TUI on tty.
+func askpassSecret(mgr bwpkg.Manager, config *cfgpkg.Config, prompt, tty string) (string, error) {
+	ref, err := askpassRef(config, prompt)
+	if err != nil {
+		return "", err
+	}
+	if ref == nil {
+		return pickSecret(mgr, config, tty)
+	}
+	return resolveRef(mgr, *ref)
+}
+
+// askpassRef returns the reference of the first rule matching prompt, or nil.
+func askpassRef(config *cfgpkg.Config, prompt string) (*bwref.Ref, error) {
+	for _, rule := range config.AskpassRules {
+		re, err := regexp.Compile(rule.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("askpass rule %q: %w", rule.Prompt, err)
+		}
+		if !re.MatchString(prompt) {
+			continue
+		}
+		ref, err := bwref.Parse(rule.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("askpass rule %q: %w", rule.Prompt, err)
+		}
+		return &ref, nil
+	}
+	return nil, nil
+}
+
+// pickSecret returns the password of an item picked in the TUI on tty.
+func pickSecret(mgr bwpkg.Manager, config *cfgpkg.Config, tty string) (string, error) {
+	id, err := uipkg.Pick(mgr, config, uipkg.Options{TTY: tty})
+	if err != nil {
+		return "", fmt.Errorf("no terminal to pick an item: %w", err)
+	}
+	if id == "" {
+		return "", errCancelled
+	}
+	return resolveRef(mgr, bwref.Ref{Item: id, Field: "password"})
+}
+
+func resolveRef(mgr bwpkg.Manager, ref bwref.Ref) (string, error) {
+	resolver, err := bwref.NewResolver(mgr)
+	if err != nil {
+		return "", err
+	}
+	return resolver.Resolve(ref)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/netbrain/mnu/internal/audit"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+)
+
+func auditSubcommand(args []string) {
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	maxAgeDays := fs.Int("max-age-days", config.AuditMaxAgeDays, "Report passwords unchanged for more than this many days (0 disables)")
+	breachDB := fs.String("breach-db", config.BreachDB, "Check passwords against a local Pwned Passwords SHA-1 or NTLM file (ordered by hash)")
+	buildIndex := fs.Bool("build-index", false, "Write an index next to the breach database to speed up lookups, then exit")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw audit [--max-age-days N] [--breach-db path [--build-index]]")
+		fs.PrintDefaults()
+	}
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	opts := audit.Options{MaxAge: time.Duration(*maxAgeDays) * 24 * time.Hour}
+	if *buildIndex {
+		if *breachDB == "" {
+			fmt.Fprintln(os.Stderr, "--build-index requires --breach-db")
+			os.Exit(exitError)
+		}
+		if err := audit.BuildBreachIndex(*breachDB); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to build breach index: %v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Printf("Wrote %s.idx\n", *breachDB)
+		return
+	}
+	if *breachDB != "" {
+		db, err := audit.OpenBreachDB(*breachDB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open breach database: %v\n", err)
+			os.Exit(exitError)
+		}
+		defer db.Close()
+		opts.Breaches = db
+	}
+
+	mgr := unlockedManager(config)
+	entries, err := audit.Collect(mgr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load items: %v\n", err)
+		os.Exit(exitError)
+	}
+	findings, err := audit.Check(entries, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Audit failed: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	fmt.Printf("Audited %d login(s)\n", len(entries))
+	for _, kind := range audit.Kinds {
+		var group []audit.Finding
+		for _, f := range findings {
+			if f.Kind == kind {
+				group = append(group, f)
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s (%d)\n", kind.Title(), len(group))
+		for _, f := range group {
+			fmt.Printf("  %s\t%s\t%s\n", f.Item.ID, f.Item.Name, f.Detail)
+		}
+	}
+}
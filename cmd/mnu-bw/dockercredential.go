@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	uipkg "github.com/netbrain/mnu/internal/ui"
+	"github.com/netbrain/mnu/internal/urimatch"
+)
+
+// dockerCredentialName is the personality docker looks for when
+// ~/.docker/config.json sets "credsStore": "mnu".
+const dockerCredentialName = "docker-credential-mnu"
+
+// errCredentialsNotFound is matched verbatim by docker to tell a missing
+// credential from a failure.
+const errCredentialsNotFound = "credentials not found in native keychain"
+
+type dockerCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// dockerCredentialSubcommand implements the docker credential helper
+// protocol. Requests arrive on stdin; answers and errors go to stdout.
+func dockerCredentialSubcommand(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s get|store|erase|list\n", dockerCredentialName)
+		os.Exit(exitError)
+	}
+	h := &dockerHelper{out: os.Stdout}
+	switch args[0] {
+	case "get", "store", "erase", "list":
+		config, err := cfgpkg.Load()
+		if err != nil {
+			fmt.Printf("failed to load config: %v\n", err)
+			os.Exit(exitError)
+		}
+		if h.mgr, err = openVault(config); err != nil {
+			fmt.Println(err)
+			os.Exit(exitError)
+		}
+		h.folder = config.DockerCredentialFolder
+		h.pick = func(ids []string) (string, error) {
+			return uipkg.Pick(h.mgr, config, uipkg.Options{IDs: ids})
+		}
+	}
+	if err := h.handle(args[0], os.Stdin); err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+}
+
+// dockerHelper answers credential helper requests from the vault.
+type dockerHelper struct {
+	mgr bwpkg.Manager
+	// folder holds the items docker login stores and list reports.
+	folder string
+	out    io.Writer
+	// pick lets the user choose between several matching items.
+	pick func(ids []string) (string, error)
+}
+
+func (h *dockerHelper) handle(action string, in io.Reader) error {
+	switch action {
+	case "get":
+		return h.get(in)
+	case "store":
+		return h.store(in)
+	case "erase":
+		return h.erase(in)
+	case "list":
+		return h.list()
+	}
+	return fmt.Errorf("unknown credential action %q", action)
+}
+
+// registryURL turns a docker server URL, which is often a bare hostname,
+// into something the item URI matching understands.
+func registryURL(server string) string {
+	server = strings.TrimSpace(server)
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	return server
+}
+
+// registryItems loads the login items in the registry folder whose URIs
+// match the registry. Logins elsewhere are websites: a gitlab.com login is
+// not the credential for registry.gitlab.com, even with the same username.
+func (h *dockerHelper) registryItems(server string) ([]bwpkg.Item, error) {
+	folderID, err := h.folderID(false)
+	if err != nil || folderID == "" {
+		return nil, err
+	}
+	raw, err := h.mgr.GetItems()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load items: %w", err)
+	}
+	return bwpkg.FilterURL(inFolder(bwpkg.ItemsFromMaps(raw), folderID), registryURL(server)), nil
+}
+
+// folderID returns the ID of the registry folder, creating it if asked to.
+// It returns "" if the folder does not exist.
+func (h *dockerHelper) folderID(create bool) (string, error) {
+	return findFolder(h.mgr, h.folder, create)
+}
+
+func (h *dockerHelper) get(r io.Reader) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	server := strings.TrimSpace(string(in))
+	if server == "" {
+		return fmt.Errorf("no server URL given")
+	}
+	matches, err := h.registryItems(server)
+	if err != nil {
+		return err
+	}
+
+	var item bwpkg.Item
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf(errCredentialsNotFound)
+	case 1:
+		item = matches[0]
+	default:
+		ids := make([]string, len(matches))
+		for i, it := range matches {
+			ids[i] = it.ID
+		}
+		picked, err := h.pick(ids)
+		if err != nil {
+			return fmt.Errorf("%d items match %s: %v", len(matches), server, err)
+		}
+		for _, it := range matches {
+			if it.ID == picked {
+				item = it
+			}
+		}
+		if item.ID == "" {
+			return fmt.Errorf(errCredentialsNotFound)
+		}
+	}
+
+	secret, err := bwpkg.GetField(h.mgr, item, "password")
+	if err != nil {
+		return fmt.Errorf("failed to get password: %w", err)
+	}
+	return json.NewEncoder(h.out).Encode(dockerCredentials{
+		ServerURL: server,
+		Username:  item.Username,
+		Secret:    strings.TrimSpace(secret),
+	})
+}
+
+// store saves the credentials of a docker login. A single matching item in
+// the registry folder with the same username gets its password updated;
+// otherwise a new login item named after the registry is created there.
+func (h *dockerHelper) store(r io.Reader) error {
+	var creds dockerCredentials
+	if err := json.NewDecoder(r).Decode(&creds); err != nil {
+		return fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if creds.ServerURL == "" {
+		return fmt.Errorf("no server URL given")
+	}
+	matches, err := h.registryItems(creds.ServerURL)
+	if err != nil {
+		return err
+	}
+	var same []bwpkg.Item
+	for _, it := range matches {
+		if it.Username == creds.Username {
+			same = append(same, it)
+		}
+	}
+
+	switch len(same) {
+	case 0:
+		folderID, err := h.folderID(true)
+		if err != nil {
+			return err
+		}
+		u := registryURL(creds.ServerURL)
+		_, err = h.mgr.CreateItem(map[string]interface{}{
+			"type":     bwpkg.TypeLogin,
+			"name":     urimatch.HostOf(u),
+			"folderId": folderID,
+			"login": map[string]interface{}{
+				"username": creds.Username,
+				"password": creds.Secret,
+				"uris": []map[string]interface{}{
+					{"uri": u, "match": nil},
+				},
+			},
+		})
+		return err
+	case 1:
+		item, err := h.mgr.GetItem(same[0].ID)
+		if err != nil {
+			return err
+		}
+		login, ok := item["login"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("item %s has no login", same[0].ID)
+		}
+		if login["password"] == creds.Secret {
+			return nil
+		}
+		login["password"] = creds.Secret
+		return h.mgr.EditItem(same[0].ID, item)
+	default:
+		return fmt.Errorf("%d items match %s for %s; not storing credentials", len(same), creds.ServerURL, creds.Username)
+	}
+}
+
+// erase removes the registry's items from the registry folder on docker
+// logout. Only items for this very host go, not those of other registries
+// under the same domain, and they go to the vault's trash, from which they
+// can be restored.
+func (h *dockerHelper) erase(r io.Reader) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	server := strings.TrimSpace(string(in))
+	if server == "" {
+		return fmt.Errorf("no server URL given")
+	}
+	matches, err := h.registryItems(server)
+	if err != nil {
+		return err
+	}
+	host := urimatch.HostOf(registryURL(server))
+	erased := 0
+	for _, it := range matches {
+		for _, u := range it.URIs {
+			if urimatch.HostOf(u.URI) == host {
+				if err := h.mgr.DeleteItem(it.ID); err != nil {
+					return fmt.Errorf("failed to delete %q: %w", it.Name, err)
+				}
+				erased++
+				break
+			}
+		}
+	}
+	if erased == 0 {
+		return fmt.Errorf(errCredentialsNotFound)
+	}
+	return nil
+}
+
+// list prints the URIs of the login items in the registry folder with their
+// usernames, which is what `docker-credential-* list` reports. Other logins
+// are websites, not registries, and are left out.
+func (h *dockerHelper) list() error {
+	out := map[string]string{}
+	folderID, err := h.folderID(false)
+	if err != nil {
+		return err
+	}
+	if folderID != "" {
+		raw, err := h.mgr.GetItems()
+		if err != nil {
+			return fmt.Errorf("failed to load items: %w", err)
+		}
+		for _, it := range bwpkg.ItemsFromMaps(raw) {
+			if it.Type != bwpkg.TypeLogin || it.FolderID != folderID {
+				continue
+			}
+			for _, u := range it.URIs {
+				if u.URI != "" {
+					out[u.URI] = it.Username
+				}
+			}
+		}
+	}
+	return json.NewEncoder(h.out).Encode(out)
+}
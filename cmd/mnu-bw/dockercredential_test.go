@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+)
+
+// fakeVault is a Manager over items and folders held in memory.
+type fakeVault struct {
+	bwpkg.Manager // panics on anything the tests do not expect
+	items         []map[string]interface{}
+	folders       []map[string]interface{}
+}
+
+func newFakeVault(t *testing.T, items, folders string) *fakeVault {
+	t.Helper()
+	v := &fakeVault{}
+	if err := json.Unmarshal([]byte(items), &v.items); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(folders), &v.folders); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func (v *fakeVault) GetItems() ([]map[string]interface{}, error) { return v.items, nil }
+
+func (v *fakeVault) GetFolders() ([]map[string]interface{}, error) { return v.folders, nil }
+
+func (v *fakeVault) item(id string) (map[string]interface{}, error) {
+	for _, it := range v.items {
+		if it["id"] == id {
+			return it, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func (v *fakeVault) GetItem(id string) (map[string]interface{}, error) { return v.item(id) }
+
+func (v *fakeVault) GetPassword(id string) (string, error) {
+	it, err := v.item(id)
+	if err != nil {
+		return "", err
+	}
+	return bwpkg.LoginPassword(it), nil
+}
+
+// roundtrip stores item the way bw would return it, as decoded JSON.
+func roundtrip(item map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(item)
+	var m map[string]interface{}
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+func (v *fakeVault) CreateItem(item map[string]interface{}) (string, error) {
+	id := fmt.Sprintf("new%d", len(v.items))
+	item = roundtrip(item)
+	item["id"] = id
+	v.items = append(v.items, item)
+	return id, nil
+}
+
+func (v *fakeVault) EditItem(id string, item map[string]interface{}) error {
+	for i, it := range v.items {
+		if it["id"] == id {
+			v.items[i] = roundtrip(item)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+func (v *fakeVault) DeleteItem(id string) error {
+	for i, it := range v.items {
+		if it["id"] == id {
+			v.items = append(v.items[:i], v.items[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+func (v *fakeVault) CreateFolder(name string) (string, error) {
+	id := fmt.Sprintf("folder%d", len(v.folders))
+	v.folders = append(v.folders, map[string]interface{}{"id": id, "name": name})
+	return id, nil
+}
+
+const dockerItems = `[
+	{"id": "gh", "type": 1, "name": "GitHub", "login": {"username": "alice", "password": "site-pw",
+	 "uris": [{"uri": "https://github.com"}]}},
+	{"id": "ghcr", "type": 1, "name": "ghcr.io", "folderId": "reg", "login": {"username": "alice",
+	 "password": "ghcr-token", "uris": [{"uri": "https://ghcr.io"}]}},
+	{"id": "quay", "type": 1, "name": "quay.io", "folderId": "reg", "login": {"username": "bob",
+	 "password": "quay-pw", "uris": [{"uri": "https://quay.io"}]}}
+]`
+
+const dockerFolders = `[{"id": "reg", "name": "Docker registries"}, {"id": "other", "name": "Work"}]`
+
+func runDockerHelper(t *testing.T, v *fakeVault, action, stdin string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	h := &dockerHelper{mgr: v, folder: "Docker registries", out: &out}
+	h.pick = func([]string) (string, error) { return "", errors.New("unexpected pick") }
+	err := h.handle(action, strings.NewReader(stdin))
+	return out.String(), err
+}
+
+func TestDockerCredentialGet(t *testing.T) {
+	v := newFakeVault(t, dockerItems, dockerFolders)
+	out, err := runDockerHelper(t, v, "get", "ghcr.io\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ServerURL":"ghcr.io","Username":"alice","Secret":"ghcr-token"}` + "\n"
+	if out != want {
+		t.Errorf("get printed %q, want %q", out, want)
+	}
+}
+
+func TestDockerCredentialGetNotFound(t *testing.T) {
+	v := newFakeVault(t, dockerItems, dockerFolders)
+	_, err := runDockerHelper(t, v, "get", "registry.example.com")
+	if err == nil || err.Error() != errCredentialsNotFound {
+		t.Errorf("get returned %v, want %q", err, errCredentialsNotFound)
+	}
+}
+
+func TestDockerCredentialList(t *testing.T) {
+	// Only the registry folder is listed, not every website login.
+	v := newFakeVault(t, dockerItems, dockerFolders)
+	out, err := runDockerHelper(t, v, "list", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"https://ghcr.io":"alice","https://quay.io":"bob"}` + "\n"
+	if out != want {
+		t.Errorf("list printed %q, want %q", out, want)
+	}
+}
+
+func TestDockerCredentialListWithoutFolder(t *testing.T) {
+	v := newFakeVault(t, dockerItems, `[]`)
+	out, err := runDockerHelper(t, v, "list", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "{}\n" {
+		t.Errorf("list printed %q, want an empty object", out)
+	}
+	if len(v.folders) != 0 {
+		t.Errorf("list created a folder")
+	}
+}
+
+func TestDockerCredentialStoreNew(t *testing.T) {
+	v := newFakeVault(t, dockerItems, `[]`)
+	in := `{"ServerURL": "registry.example.com", "Username": "carol", "Secret": "s3cret"}`
+	if _, err := runDockerHelper(t, v, "store", in); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.folders) != 1 || v.folders[0]["name"] != "Docker registries" {
+		t.Fatalf("store did not create the registry folder: %v", v.folders)
+	}
+	created := v.items[len(v.items)-1]
+	if created["name"] != "registry.example.com" || created["folderId"] != v.folders[0]["id"] {
+		t.Errorf("store created %v", created)
+	}
+	out, err := runDockerHelper(t, v, "list", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"https://registry.example.com":"carol"}` + "\n"; out != want {
+		t.Errorf("list printed %q, want %q", out, want)
+	}
+}
+
+func TestDockerCredentialStoreUpdate(t *testing.T) {
+	v := newFakeVault(t, dockerItems, dockerFolders)
+	in := `{"ServerURL": "quay.io", "Username": "bob", "Secret": "new-pw"}`
+	if _, err := runDockerHelper(t, v, "store", in); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.items) != 3 {
+		t.Fatalf("store created an item instead of updating")
+	}
+	if pw, _ := v.GetPassword("quay"); pw != "new-pw" {
+		t.Errorf("password is %q after store, want new-pw", pw)
+	}
+}
+
+func TestDockerCredentialErrors(t *testing.T) {
+	v := newFakeVault(t, dockerItems, dockerFolders)
+	for _, tt := range []struct{ action, stdin string }{
+		{"get", ""},
+		{"store", "not json"},
+		{"store", `{"Username": "bob"}`},
+		{"frobnicate", ""},
+	} {
+		if _, err := runDockerHelper(t, v, tt.action, tt.stdin); err == nil {
+			t.Errorf("%s with %q succeeded", tt.action, tt.stdin)
+		}
+	}
+}
+
+func TestDockerCredentialParentDomainLogin(t *testing.T) {
+	// A website login on the parent domain with the same username is
+	// neither answered for the registry nor overwritten by docker login.
+	v := newFakeVault(t, `[
+		{"id": "gl", "type": 1, "name": "GitLab", "login": {"username": "alice", "password": "site-pw",
+		 "uris": [{"uri": "https://gitlab.com"}]}}
+	]`, dockerFolders)
+	if _, err := runDockerHelper(t, v, "get", "registry.gitlab.com"); err == nil || err.Error() != errCredentialsNotFound {
+		t.Fatalf("get returned %v, want %q", err, errCredentialsNotFound)
+	}
+
+	in := `{"ServerURL": "registry.gitlab.com", "Username": "alice", "Secret": "glpat-token"}`
+	if _, err := runDockerHelper(t, v, "store", in); err != nil {
+		t.Fatal(err)
+	}
+	if pw, _ := v.GetPassword("gl"); pw != "site-pw" {
+		t.Fatalf("store changed the website login to %q", pw)
+	}
+	if len(v.items) != 2 || v.items[1]["folderId"] != "reg" {
+		t.Fatalf("store did not create a registry item: %v", v.items)
+	}
+
+	out, err := runDockerHelper(t, v, "get", "registry.gitlab.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ServerURL":"registry.gitlab.com","Username":"alice","Secret":"glpat-token"}` + "\n"
+	if out != want {
+		t.Errorf("get printed %q, want %q", out, want)
+	}
+}
+
+func TestDockerCredentialErase(t *testing.T) {
+	v := newFakeVault(t, dockerItems, dockerFolders)
+	if _, err := runDockerHelper(t, v, "erase", "ghcr.io\n"); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, it := range v.items {
+		ids = append(ids, it["id"].(string))
+	}
+	if strings.Join(ids, ",") != "gh,quay" {
+		t.Errorf("items left after erase: %v, want gh,quay", ids)
+	}
+	if _, err := runDockerHelper(t, v, "erase", "ghcr.io\n"); err == nil || err.Error() != errCredentialsNotFound {
+		t.Errorf("second erase returned %v, want %q", err, errCredentialsNotFound)
+	}
+}
+
+func TestDockerCredentialEraseOnlyThatHost(t *testing.T) {
+	// Website logins and other registries under the same domain stay.
+	v := newFakeVault(t, `[
+		{"id": "gl", "type": 1, "name": "GitLab", "login": {"username": "alice", "password": "site-pw",
+		 "uris": [{"uri": "https://gitlab.com"}]}},
+		{"id": "reg", "type": 1, "name": "registry.gitlab.com", "folderId": "reg", "login": {"username": "alice",
+		 "password": "token", "uris": [{"uri": "https://registry.gitlab.com"}]}},
+		{"id": "dep", "type": 1, "name": "dependency-proxy", "folderId": "reg", "login": {"username": "alice",
+		 "password": "token2", "uris": [{"uri": "gitlab.com"}]}}
+	]`, dockerFolders)
+	if _, err := runDockerHelper(t, v, "erase", "registry.gitlab.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.items) != 2 || v.items[0]["id"] != "gl" || v.items[1]["id"] != "dep" {
+		t.Errorf("items left after erase: %v", v.items)
+	}
+}
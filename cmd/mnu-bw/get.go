@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	"github.com/netbrain/mnu/internal/clipboard"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	"github.com/netbrain/mnu/internal/keychain"
+)
+
+// Exit codes shared by the non-interactive subcommands.
+const (
+	exitError     = 1
+	exitNotFound  = 2
+	exitAmbiguous = 3
+	exitLocked    = 4
+)
+
+func getSubcommand(args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	field := fs.String("field", "password", "Field to print: password, username, totp, uri, notes or field:<name>")
+	copyOut := fs.Bool("copy", false, "Copy to the clipboard instead of printing")
+	noNewline := fs.Bool("n", false, "Do not print a trailing newline")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw get <query|id> [--field name] [--copy] [-n]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	mgr := unlockedManager(config)
+
+	raw, err := bwpkg.ListItems(mgr, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load items: %v\n", err)
+		os.Exit(exitError)
+	}
+	items := bwpkg.ItemsFromMaps(raw)
+	item, err := bwpkg.Resolve(items, positional[0])
+	switch {
+	case errors.Is(err, bwpkg.ErrNotFound):
+		fmt.Fprintf(os.Stderr, "No item matches %q\n", positional[0])
+		os.Exit(exitNotFound)
+	case errors.Is(err, bwpkg.ErrAmbiguous):
+		fmt.Fprintf(os.Stderr, "%q matches more than one item:\n", positional[0])
+		for _, it := range bwpkg.Filter(items, positional[0]) {
+			fmt.Fprintf(os.Stderr, "  %s\t%s\t%s\n", it.ID, it.Name, it.Username)
+		}
+		os.Exit(exitAmbiguous)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Failed to find %q: %v\n", positional[0], err)
+		os.Exit(exitError)
+	}
+
+	value, err := bwpkg.GetField(mgr, item, *field)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get %s: %v\n", *field, err)
+		os.Exit(exitError)
+	}
+	if *field == "password" || *field == "totp" {
+		value = strings.TrimSpace(value)
+	}
+
+	if *copyOut {
+		if err := clipboard.Copy(value, config.ClipboardTimeout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to copy to clipboard: %v\n", err)
+			os.Exit(exitError)
+		}
+		return
+	}
+	if *noNewline {
+		fmt.Print(value)
+	} else {
+		fmt.Println(value)
+	}
+}
+
+// unlockedManager connects to Bitwarden for a non-interactive subcommand and
+// exits with exitLocked if the vault is not unlocked.
+func unlockedManager(config *cfgpkg.Config) bwpkg.Manager {
+	mgr, err := openVault(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.As(err, new(lockedError)) {
+			os.Exit(exitLocked)
+		}
+		os.Exit(exitError)
+	}
+	return mgr
+}
+
+// lockedError is returned by openVault when the vault needs unlocking.
+type lockedError string
+
+func (e lockedError) Error() string { return string(e) }
+
+// openVault is unlockedManager for subcommands that report errors their own
+// way.
+func openVault(config *cfgpkg.Config) (bwpkg.Manager, error) {
+	loadSessionKey()
+	mgr, _, err := connectManager(config, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to Bitwarden: %v", err)
+	}
+	if !mgr.IsInstalled() {
+		return nil, errors.New("bw is not installed")
+	}
+	loggedIn, err := mgr.IsLoggedIn()
+	if errors.Is(err, keychain.ErrSessionExpired) {
+		return nil, lockedError("Session expired; unlock the vault with mnu-bw again")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to check vault status: %v", err)
+	}
+	if !loggedIn {
+		return nil, lockedError("Vault is locked; unlock it with mnu-bw first")
+	}
+	return mgr, nil
+}
+
+// findFolder returns the ID of the vault folder with the given name, creating
+// it if asked to. It returns "" if the folder does not exist.
+func findFolder(mgr bwpkg.Manager, name string, create bool) (string, error) {
+	folders, err := mgr.GetFolders()
+	if err != nil {
+		return "", fmt.Errorf("failed to list folders: %w", err)
+	}
+	for _, f := range folders {
+		if n, _ := f["name"].(string); n == name {
+			id, _ := f["id"].(string)
+			return id, nil
+		}
+	}
+	if !create {
+		return "", nil
+	}
+	id, err := mgr.CreateFolder(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create folder %q: %w", name, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	uipkg "github.com/netbrain/mnu/internal/ui"
+)
+
+// gitCredentialSubcommand implements git's credential helper protocol:
+// key=value lines on stdin, answered on stdout. Configure it with
+//
+//	git config --global credential.helper '!mnu-bw git-credential'
+func gitCredentialSubcommand(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw git-credential get|store|erase")
+		os.Exit(exitError)
+	}
+	attrs, err := readCredentialAttrs(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read credential request: %v\n", err)
+		os.Exit(exitError)
+	}
+	switch args[0] {
+	case "get", "store":
+	default:
+		// Git erases credentials it believes are wrong, but a failed login
+		// is no proof of that, so vault items are never deleted on its
+		// behalf. Unknown actions must be ignored per the protocol.
+		return
+	}
+	if attrs["protocol"] == "" || attrs["host"] == "" {
+		return
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	if args[0] == "store" && !config.GitCredentialStore {
+		return
+	}
+	h := &gitHelper{
+		mgr:    unlockedManager(config),
+		folder: config.GitCredentialFolder,
+		out:    os.Stdout,
+		log:    os.Stderr,
+	}
+	h.pick = func(ids []string) (string, error) {
+		return uipkg.Pick(h.mgr, config, uipkg.Options{IDs: ids})
+	}
+	if err := h.handle(args[0], attrs); err != nil {
+		fmt.Fprintf(os.Stderr, "mnu-bw: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// gitHelper answers git credential requests from the vault.
+type gitHelper struct {
+	mgr bwpkg.Manager
+	// folder holds the items store creates and updates.
+	folder string
+	out    io.Writer
+	// log takes notes for the user; git shows the helper's stderr.
+	log io.Writer
+	// pick lets the user choose between several matching items.
+	pick func(ids []string) (string, error)
+}
+
+func (h *gitHelper) handle(action string, attrs map[string]string) error {
+	switch action {
+	case "get":
+		return h.get(attrs)
+	case "store":
+		return h.store(attrs)
+	}
+	// See gitCredentialSubcommand for why erase does nothing.
+	return nil
+}
+
+// readCredentialAttrs parses key=value lines until a blank line or EOF.
+func readCredentialAttrs(r io.Reader) (map[string]string, error) {
+	attrs := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			break
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs, sc.Err()
+}
+
+// credentialURL rebuilds the URL git is asking about.
+func credentialURL(attrs map[string]string) string {
+	u := attrs["protocol"] + "://" + attrs["host"]
+	if p := attrs["path"]; p != "" {
+		u += "/" + strings.TrimPrefix(p, "/")
+	}
+	return u
+}
+
+// matchCredentialItems returns the login items whose URIs match the request,
+// narrowed to the requested username if git sent one.
+func matchCredentialItems(items []bwpkg.Item, attrs map[string]string) []bwpkg.Item {
+	matches := bwpkg.FilterURL(items, credentialURL(attrs))
+	if user := attrs["username"]; user != "" {
+		var same []bwpkg.Item
+		for _, it := range matches {
+			if it.Username == user {
+				same = append(same, it)
+			}
+		}
+		matches = same
+	}
+	return matches
+}
+
+func (h *gitHelper) items() ([]bwpkg.Item, error) {
+	raw, err := h.mgr.GetItems()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load items: %w", err)
+	}
+	return bwpkg.ItemsFromMaps(raw), nil
+}
+
+func (h *gitHelper) get(attrs map[string]string) error {
+	if attrs["protocol"] == "" || attrs["host"] == "" {
+		return nil
+	}
+	items, err := h.items()
+	if err != nil {
+		return err
+	}
+	matches := matchCredentialItems(items, attrs)
+	// Credentials store saved for git win over website logins for the host.
+	folderID, err := findFolder(h.mgr, h.folder, false)
+	if err != nil {
+		return err
+	}
+	if stored := inFolder(matches, folderID); len(stored) > 0 {
+		matches = stored
+	}
+
+	var item bwpkg.Item
+	switch len(matches) {
+	case 0:
+		// Let git fall through to the next helper or prompt.
+		return nil
+	case 1:
+		item = matches[0]
+	default:
+		ids := make([]string, len(matches))
+		for i, it := range matches {
+			ids[i] = it.ID
+		}
+		picked, err := h.pick(ids)
+		if err != nil {
+			fmt.Fprintf(h.log, "mnu-bw: %d items match %s and no terminal is available to pick one: %v\n", len(matches), credentialURL(attrs), err)
+			return nil
+		}
+		for _, it := range matches {
+			if it.ID == picked {
+				item = it
+			}
+		}
+		if item.ID == "" {
+			return nil
+		}
+	}
+
+	password, err := bwpkg.GetField(h.mgr, item, "password")
+	if err != nil {
+		return fmt.Errorf("failed to get password: %w", err)
+	}
+	password = strings.TrimSpace(password)
+	// A line break would end the value early and let the rest pass for
+	// another attribute.
+	if strings.ContainsAny(item.Username, "\n\x00") || strings.ContainsAny(password, "\n\x00") {
+		return fmt.Errorf("the username or password of %q contains a line break, which git cannot take", item.Name)
+	}
+	fmt.Fprintf(h.out, "username=%s\n", item.Username)
+	fmt.Fprintf(h.out, "password=%s\n", password)
+	return nil
+}
+
+// store saves credentials git reports as working, when enabled with
+// git_credential_store. Only the git credential folder is written to: a
+// single item there matching the remote and username gets its password
+// updated, otherwise a new login item is created in it. A website login for
+// the same host and username elsewhere in the vault is left alone.
+func (h *gitHelper) store(attrs map[string]string) error {
+	if attrs["protocol"] == "" || attrs["host"] == "" || attrs["username"] == "" || attrs["password"] == "" {
+		return nil
+	}
+	folderID, err := findFolder(h.mgr, h.folder, false)
+	if err != nil {
+		return err
+	}
+	var matches []bwpkg.Item
+	if folderID != "" {
+		items, err := h.items()
+		if err != nil {
+			return err
+		}
+		matches = matchCredentialItems(inFolder(items, folderID), attrs)
+	}
+
+	switch len(matches) {
+	case 0:
+		if folderID, err = findFolder(h.mgr, h.folder, true); err != nil {
+			return err
+		}
+		_, err = h.mgr.CreateItem(map[string]interface{}{
+			"type":     bwpkg.TypeLogin,
+			"name":     attrs["host"],
+			"folderId": folderID,
+			"login": map[string]interface{}{
+				"username": attrs["username"],
+				"password": attrs["password"],
+				"uris": []map[string]interface{}{
+					{"uri": attrs["protocol"] + "://" + attrs["host"], "match": nil},
+				},
+			},
+		})
+	case 1:
+		var item map[string]interface{}
+		item, err = h.mgr.GetItem(matches[0].ID)
+		if err != nil {
+			break
+		}
+		login, ok := item["login"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("item %s has no login", matches[0].ID)
+		}
+		if login["password"] == attrs["password"] {
+			return nil
+		}
+		login["password"] = attrs["password"]
+		err = h.mgr.EditItem(matches[0].ID, item)
+	default:
+		return fmt.Errorf("%d items in %q match %s; not storing credentials", len(matches), h.folder, credentialURL(attrs))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store credentials: %w", err)
+	}
+	return nil
+}
+
+// inFolder returns the items in the folder with the given ID, none if it is "".
+func inFolder(items []bwpkg.Item, folderID string) []bwpkg.Item {
+	var out []bwpkg.Item
+	for _, it := range items {
+		if folderID != "" && it.FolderID == folderID {
+			out = append(out, it)
+		}
+	}
+	return out
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const gitItems = `[
+	{"id": "gh", "type": 1, "name": "GitHub", "login": {"username": "alice", "password": "site-pw",
+	 "uris": [{"uri": "https://github.com"}]}},
+	{"id": "gh-bob", "type": 1, "name": "GitHub (bob)", "login": {"username": "bob", "password": "bob-pw",
+	 "uris": [{"uri": "https://github.com"}]}},
+	{"id": "gl", "type": 1, "name": "GitLab", "login": {"username": "carol", "password": " gl-pw \n",
+	 "uris": [{"uri": "https://gitlab.com"}]}},
+	{"id": "evil", "type": 1, "name": "Evil", "login": {"username": "mallory", "password": "pw\nusername=root",
+	 "uris": [{"uri": "https://evil.example"}]}}
+]`
+
+// runGitHelper feeds stdin to the helper the way git would.
+func runGitHelper(t *testing.T, v *fakeVault, action, stdin string) (string, string, error) {
+	t.Helper()
+	attrs, err := readCredentialAttrs(strings.NewReader(stdin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, log bytes.Buffer
+	h := &gitHelper{mgr: v, folder: "Git credentials", out: &out, log: &log}
+	h.pick = func([]string) (string, error) { return "", errors.New("no terminal") }
+	err = h.handle(action, attrs)
+	return out.String(), log.String(), err
+}
+
+func TestReadCredentialAttrs(t *testing.T) {
+	in := "protocol=https\nhost=github.com\npath=org/repo.git\nusername=alice\npassword=a=b\nnot an attribute\n\nhost=ignored.example\n"
+	got, err := readCredentialAttrs(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"protocol": "https",
+		"host":     "github.com",
+		"path":     "org/repo.git",
+		"username": "alice",
+		"password": "a=b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCredentialURL(t *testing.T) {
+	for _, tt := range []struct {
+		attrs map[string]string
+		want  string
+	}{
+		{map[string]string{"protocol": "https", "host": "github.com"}, "https://github.com"},
+		{map[string]string{"protocol": "https", "host": "github.com", "path": "org/repo.git"}, "https://github.com/org/repo.git"},
+		{map[string]string{"protocol": "https", "host": "git.example:8443", "path": "/repo"}, "https://git.example:8443/repo"},
+	} {
+		if got := credentialURL(tt.attrs); got != tt.want {
+			t.Errorf("credentialURL(%v) = %q, want %q", tt.attrs, got, tt.want)
+		}
+	}
+}
+
+func TestGitCredentialGet(t *testing.T) {
+	for _, tt := range []struct {
+		name, stdin, want string
+	}{
+		{"single match", "protocol=https\nhost=gitlab.com\npath=group/repo.git\n\n", "username=carol\npassword=gl-pw\n"},
+		{"narrowed by username", "protocol=https\nhost=github.com\nusername=bob\n\n", "username=bob\npassword=bob-pw\n"},
+		{"subdomain of a login", "protocol=https\nhost=gist.github.com\nusername=alice\n\n", "username=alice\npassword=site-pw\n"},
+		{"unknown host", "protocol=https\nhost=example.org\n\n", ""},
+		{"unknown username", "protocol=https\nhost=github.com\nusername=dave\n\n", ""},
+		{"no host", "protocol=https\n\n", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newFakeVault(t, gitItems, `[]`)
+			out, _, err := runGitHelper(t, v, "get", tt.stdin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tt.want {
+				t.Errorf("get printed %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitCredentialGetAmbiguousWithoutTerminal(t *testing.T) {
+	v := newFakeVault(t, gitItems, `[]`)
+	out, log, err := runGitHelper(t, v, "get", "protocol=https\nhost=github.com\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		t.Errorf("get printed %q, want nothing so git prompts", out)
+	}
+	if !strings.Contains(log, "2 items match https://github.com") {
+		t.Errorf("get logged %q", log)
+	}
+}
+
+func TestGitCredentialGetRejectsLineBreaks(t *testing.T) {
+	v := newFakeVault(t, gitItems, `[]`)
+	out, _, err := runGitHelper(t, v, "get", "protocol=https\nhost=evil.example\n\n")
+	if err == nil {
+		t.Error("get accepted a password with a line break")
+	}
+	if out != "" {
+		t.Errorf("get printed %q", out)
+	}
+}
+
+func TestGitCredentialStore(t *testing.T) {
+	// A website login for the same host and username is not the git
+	// credential and must survive.
+	v := newFakeVault(t, gitItems, `[]`)
+	store := "protocol=https\nhost=github.com\nusername=alice\npassword=ghp_token1\n\n"
+	if _, _, err := runGitHelper(t, v, "store", store); err != nil {
+		t.Fatal(err)
+	}
+	if pw, _ := v.GetPassword("gh"); pw != "site-pw" {
+		t.Fatalf("store changed the website login to %q", pw)
+	}
+	if len(v.folders) != 1 || v.folders[0]["name"] != "Git credentials" {
+		t.Fatalf("store did not create the folder: %v", v.folders)
+	}
+	created := v.items[len(v.items)-1]
+	if created["name"] != "github.com" || created["folderId"] != v.folders[0]["id"] {
+		t.Fatalf("store created %v", created)
+	}
+
+	// The stored item wins over the website login from now on...
+	out, _, err := runGitHelper(t, v, "get", "protocol=https\nhost=github.com\nusername=alice\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "username=alice\npassword=ghp_token1\n"; out != want {
+		t.Errorf("get printed %q, want %q", out, want)
+	}
+
+	// ...and is the one updated.
+	n := len(v.items)
+	store = strings.Replace(store, "ghp_token1", "ghp_token2", 1)
+	if _, _, err := runGitHelper(t, v, "store", store); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.items) != n {
+		t.Fatalf("store created another item instead of updating")
+	}
+	if pw, _ := v.GetPassword(created["id"].(string)); pw != "ghp_token2" {
+		t.Errorf("stored password is %q, want ghp_token2", pw)
+	}
+	if pw, _ := v.GetPassword("gh"); pw != "site-pw" {
+		t.Errorf("store changed the website login to %q", pw)
+	}
+}
+
+func TestGitCredentialStoreIncomplete(t *testing.T) {
+	v := newFakeVault(t, gitItems, `[]`)
+	for _, stdin := range []string{
+		"protocol=https\nhost=github.com\nusername=alice\n\n",
+		"protocol=https\nhost=github.com\npassword=x\n\n",
+		"host=github.com\nusername=alice\npassword=x\n\n",
+	} {
+		if _, _, err := runGitHelper(t, v, "store", stdin); err != nil {
+			t.Errorf("store %q: %v", stdin, err)
+		}
+	}
+	if len(v.items) != 4 || len(v.folders) != 0 {
+		t.Errorf("incomplete store requests changed the vault")
+	}
+}
+
+func TestGitCredentialErase(t *testing.T) {
+	// erase never touches the vault.
+	if _, _, err := runGitHelper(t, nil, "erase", "protocol=https\nhost=github.com\nusername=alice\n\n"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/netbrain/mnu/internal/bwref"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+)
+
+func injectSubcommand(args []string) {
+	fs := flag.NewFlagSet("inject", flag.ExitOnError)
+	in := fs.String("i", "-", "Template to read (- for stdin)")
+	out := fs.String("o", "-", "Output file, FIFO, or - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw inject [-i template] [-o output]")
+		fs.PrintDefaults()
+	}
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	var src []byte
+	var err error
+	if *in == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read template: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	mgr := unlockedManager(config)
+	resolver, err := bwref.NewResolver(mgr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load items: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	rendered, unresolved, err := renderTemplate(*in, src, resolver)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to render template: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(unresolved) > 0 {
+		fmt.Fprintf(os.Stderr, "%d unresolved reference(s):\n", len(unresolved))
+		for _, e := range unresolved {
+			fmt.Fprintf(os.Stderr, "  %v\n", e)
+		}
+		os.Exit(exitError)
+	}
+	defer func() {
+		for i := range rendered {
+			rendered[i] = 0
+		}
+	}()
+
+	if err := writeSecretOutput(*out, rendered); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// renderTemplate executes a template whose bw function resolves secrets:
+// {{ bw "item" "field" }} or {{ bw "bw://item/field" }}. Failed lookups are
+// collected rather than stopping at the first one.
+func renderTemplate(name string, src []byte, resolver *bwref.Resolver) ([]byte, []error, error) {
+	var unresolved []error
+	funcs := template.FuncMap{
+		"bw": func(args ...string) (string, error) {
+			var ref bwref.Ref
+			var err error
+			switch len(args) {
+			case 1:
+				ref, err = bwref.Parse(args[0])
+			case 2:
+				ref, err = bwref.New(args[0], args[1])
+			default:
+				return "", fmt.Errorf(`bw expects "item" "field" or "bw://item/field"`)
+			}
+			if err != nil {
+				unresolved = append(unresolved, err)
+				return "", nil
+			}
+			value, err := resolver.Resolve(ref)
+			if err != nil {
+				unresolved = append(unresolved, err)
+				return "", nil
+			}
+			return value, nil
+		},
+	}
+	tmpl, err := template.New(filepath.Base(name)).Option("missingkey=error").Funcs(funcs).Parse(string(src))
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), unresolved, nil
+}
+
+// writeSecretOutput writes to stdout, into an existing FIFO, or atomically
+// replaces a regular file created with 0600 permissions.
+func writeSecretOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if st, err := os.Stat(path); err == nil && st.Mode()&(os.ModeNamedPipe|os.ModeCharDevice) != 0 {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/netbrain/mnu/internal/bwref"
+)
+
+const injectItems = `[
+	{"id": "gh", "type": 1, "name": "GitHub", "login": {"username": "alice", "password": "gh-pw",
+	 "uris": [{"uri": "https://github.com"}]}},
+	{"id": "db", "type": 1, "name": "Prod DB", "login": {"username": "postgres", "password": "db-pw"},
+	 "fields": [{"name": "API key", "value": "k3y", "type": 1}]}
+]`
+
+func newInjectResolver(t *testing.T) *bwref.Resolver {
+	t.Helper()
+	r, err := bwref.NewResolver(newFakeVault(t, injectItems, `[]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRenderTemplate(t *testing.T) {
+	src := `user={{ bw "GitHub" "username" }}
+pass={{ bw "bw://GitHub/password" }}
+key={{ bw "Prod DB" "field/API key" }}
+key2={{ bw "bw://Prod%20DB/field/API%20key" }}
+`
+	out, unresolved, err := renderTemplate("env.tmpl", []byte(src), newInjectResolver(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unresolved) != 0 {
+		t.Fatalf("unresolved: %v", unresolved)
+	}
+	want := "user=alice\npass=gh-pw\nkey=k3y\nkey2=k3y\n"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRenderTemplateListsEveryUnresolvedReference(t *testing.T) {
+	src := `a={{ bw "Nowhere" "password" }}
+b={{ bw "GitHub" "totp" }}
+c={{ bw "bw://GitHub" }}
+d={{ bw "GitHub" "secret" }}
+e={{ bw "Prod DB" "field:Missing" }}
+ok={{ bw "GitHub" "username" }}
+`
+	out, unresolved, err := renderTemplate("env.tmpl", []byte(src), newInjectResolver(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, e := range unresolved {
+		msgs = append(msgs, e.Error())
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{"bw://Nowhere/password", "bw://GitHub/totp", `"bw://GitHub"`, `"secret"`, "bw://Prod%20DB/field/Missing"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("unresolved references do not mention %s:\n%s", want, all)
+		}
+	}
+	if len(unresolved) != 5 {
+		t.Errorf("got %d unresolved references, want 5:\n%s", len(unresolved), all)
+	}
+	if !strings.Contains(string(out), "ok=alice") {
+		t.Errorf("rendering stopped early: %q", out)
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	for _, src := range []string{
+		`{{ bw "GitHub" }`,
+		`{{ bw "GitHub" "password" "extra" }}`,
+		`{{ bw }}`,
+	} {
+		if _, _, err := renderTemplate("t", []byte(src), newInjectResolver(t)); err == nil {
+			t.Errorf("renderTemplate(%q) succeeded", src)
+		}
+	}
+}
+
+func TestWriteSecretOutputMode(t *testing.T) {
+	old := syscall.Umask(0)
+	defer syscall.Umask(old)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.env")
+	// An existing, readable file is replaced rather than rewritten in place.
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSecretOutput(path, []byte("TOKEN=x\n")); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := st.Mode().Perm(); perm != 0600 {
+		t.Errorf("output mode = %v, want 0600", perm)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "TOKEN=x\n" {
+		t.Errorf("output = %q", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestWriteSecretOutputFIFO(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	got := make(chan string, 1)
+	go func() {
+		f, err := os.Open(path)
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		defer f.Close()
+		data, _ := io.ReadAll(f)
+		got <- string(data)
+	}()
+	if err := writeSecretOutput(path, []byte("TOKEN=x\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-got; data != "TOKEN=x\n" {
+		t.Errorf("read %q from the FIFO", data)
+	}
+	st, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("FIFO replaced by %v", st.Mode())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("files created next to the FIFO: %v", entries)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+)
+
+// listEntry is the JSON shape of one item in `mnu-bw list --json`. It only
+// carries metadata; secrets are never included.
+type listEntry struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Username string   `json:"username,omitempty"`
+	URIs     []string `json:"uris,omitempty"`
+	HasTotp  bool     `json:"hasTotp"`
+	Folder   string   `json:"folder,omitempty"`
+}
+
+func listSubcommand(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print one JSON object per line")
+	query := fs.String("query", "", "Only list items matching this search query")
+	folder := fs.String("folder", "", "Only list items in this folder (name or ID)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw list [--json] [--query text] [--folder name]")
+		fs.PrintDefaults()
+	}
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	mgr := unlockedManager(config)
+
+	raw, err := bwpkg.ListItems(mgr, *query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load items: %v\n", err)
+		os.Exit(exitError)
+	}
+	rawFolders, err := mgr.GetFolders()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load folders: %v\n", err)
+		os.Exit(exitError)
+	}
+	folders := bwpkg.FolderNames(rawFolders)
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, it := range bwpkg.ItemsFromMaps(raw) {
+		folderName := folders[it.FolderID]
+		if *folder != "" && it.FolderID != *folder && !strings.EqualFold(folderName, *folder) {
+			continue
+		}
+		if !*asJSON {
+			fmt.Printf("%s\t%s\t%s\n", it.ID, it.Name, it.Username)
+			continue
+		}
+		uris := make([]string, len(it.URIs))
+		for i, u := range it.URIs {
+			uris[i] = u.URI
+		}
+		if err := enc.Encode(listEntry{
+			ID:       it.ID,
+			Name:     it.Name,
+			Username: it.Username,
+			URIs:     uris,
+			HasTotp:  it.HasTotp,
+			Folder:   folderName,
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+}
@@ -1,22 +1,21 @@
 package main
 
 import (
-	"crypto/sha256"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strconv"
 	"syscall"
 	"time"
 
-	"github.com/atotto/clipboard"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/netbrain/mnu/internal/activewin"
 	bwpkg "github.com/netbrain/mnu/internal/bw"
+	clipboardpkg "github.com/netbrain/mnu/internal/clipboard"
 	cfgpkg "github.com/netbrain/mnu/internal/config"
 	"github.com/netbrain/mnu/internal/debugflag"
 	"github.com/netbrain/mnu/internal/keychain"
@@ -27,6 +26,8 @@ import (
 
 var bwManager bwpkg.Manager
 var debug bool
+var urlFilter string
+var fromWindow bool
 
 func clearClipboardSubcommand() {
 	if len(os.Args) < 3 {
@@ -39,11 +40,6 @@ func clearClipboardSubcommand() {
 		fmt.Printf("Failed to read content from stdin: %v\n", err)
 		os.Exit(1)
 	}
-	origHash := sha256.Sum256(contentBytes)
-	content := string(contentBytes)
-	for i := range contentBytes {
-		contentBytes[i] = 0
-	}
 
 	timeoutSeconds, err := strconv.Atoi(os.Args[1])
 	if err != nil {
@@ -52,6 +48,26 @@ func clearClipboardSubcommand() {
 	}
 	uniqueID := os.Args[2]
 
+	// Without a config the clipboard defaults still work.
+	var opts clipboardpkg.Options
+	if config, err := cfgpkg.Load(); err == nil {
+		opts = clipboardpkg.Options{
+			Backend:   config.ClipboardBackend,
+			PasteOnce: config.ClipboardPasteOnce,
+			Primary:   config.ClipboardSelection == "primary",
+			Command: clipboardpkg.Command{
+				Write: config.ClipboardCommand.Write,
+				Read:  config.ClipboardCommand.Read,
+				Clear: config.ClipboardCommand.Clear,
+			},
+		}
+	}
+	backend, err := clipboardpkg.New(opts)
+	if err != nil {
+		fmt.Printf("Failed to set up clipboard: %v\n", err)
+		os.Exit(1)
+	}
+
 	configDir, err := util.GetConfigDir()
 	if err != nil {
 		fmt.Printf("Failed to get config directory: %v\n", err)
@@ -65,13 +81,7 @@ func clearClipboardSubcommand() {
 	}
 	defer os.Remove(fifoPath)
 
-	if err := clipboard.WriteAll(content); err != nil {
-		fmt.Printf("Failed to copy to clipboard: %v\n", err)
-		os.Exit(1)
-	}
-	content = ""
-
-	cancelChan := make(chan struct{})
+	signals := make(chan string, 1)
 	go func() {
 		fifo, err := os.OpenFile(fifoPath, os.O_RDONLY, 0600)
 		if err != nil {
@@ -79,49 +89,89 @@ func clearClipboardSubcommand() {
 			return
 		}
 		defer fifo.Close()
-		buf := make([]byte, 1)
-		_, err = fifo.Read(buf)
+		buf := make([]byte, 16)
+		n, err := fifo.Read(buf)
 		if err != nil && err != io.EOF {
 			fmt.Printf("Error reading from FIFO: %v\n", err)
 			return
 		}
-		close(cancelChan)
+		signals <- string(buf[:n])
 	}()
 
-	select {
-	case <-time.After(time.Duration(timeoutSeconds) * time.Second):
-		current, err := clipboard.ReadAll()
-		if err != nil {
-			fmt.Printf("Failed to read clipboard for sanity check: %v\n", err)
-			os.Exit(1)
-		}
-		curHash := sha256.Sum256([]byte(current))
-		if curHash != origHash {
-			fmt.Println("Clipboard content changed; skipping clear.")
-			return
-		}
-		if err := clipboard.WriteAll(""); err != nil {
-			fmt.Printf("Failed to clear clipboard: %v\n", err)
-			os.Exit(1)
-		}
-	case <-cancelChan:
+	outcome, err := clipboardpkg.Hold(backend, contentBytes, time.Duration(timeoutSeconds)*time.Second, signals)
+	if err != nil {
+		fmt.Printf("Clipboard clearer: %v\n", err)
+		os.Exit(1)
+	}
+	switch outcome {
+	case clipboardpkg.ClearedEarly:
+		fmt.Println("Clearing clipboard early.")
+	case clipboardpkg.Cancelled:
 		fmt.Println("Clipboard clearer cancelled.")
+	case clipboardpkg.Changed:
+		fmt.Println("Clipboard content changed; skipping clear.")
 	}
 }
 
-func serveSubcommand() {
-	if url, ok := serve.FindAdvertised(); ok {
-		fmt.Printf("bw serve already running at %s\n", url)
-		return
+// loadSessionKey exports a stored session key as BW_SESSION, if there is one.
+func loadSessionKey() {
+	sessionKey, err := keychain.GetSessionKey()
+	if err == nil && sessionKey != "" {
+		os.Setenv("BW_SESSION", sessionKey)
 	}
-	if err := serve.RunAdvertiser(); err != nil {
-		fmt.Printf("Failed to run bw serve advertiser: %v\n", err)
-		os.Exit(1)
+}
+
+// connectManager returns the Bitwarden manager for the configured mode. In API
+// mode a running `mnu-bw serve` daemon is preferred; if none is running, startServe
+// decides between launching one (returned server is owned by the caller) and
+// falling back to the bw CLI.
+func connectManager(config *cfgpkg.Config, startServe bool) (bwpkg.Manager, *serve.Server, error) {
+	if !config.ApiMode {
+		return bwpkg.NewProcessManager(), nil, nil
+	}
+	if ep, ok := serve.FindAdvertised(); ok {
+		return bwpkg.NewDaemonManager(ep), nil, nil
+	}
+	if !startServe {
+		return bwpkg.NewProcessManager(), nil, nil
+	}
+	srv, err := serve.Start()
+	if err != nil {
+		return nil, nil, err
+	}
+	return bwpkg.NewAPIManager(srv.Endpoint.Socket, srv.Endpoint.Token), srv, nil
+}
+
+// windowOptions maps the window mnu-bw was launched from to a URL filter when its title
+// contains one, or to a search query for its application otherwise.
+func windowOptions() uipkg.Options {
+	provider, err := activewin.Detect()
+	if err != nil {
+		if debugflag.Enabled {
+			log.Printf("Active window: %v", err)
+		}
+		return uipkg.Options{}
 	}
+	win, err := provider.ActiveWindow()
+	if err != nil {
+		if debugflag.Enabled {
+			log.Printf("Active window: %v", err)
+		}
+		return uipkg.Options{}
+	}
+	if debugflag.Enabled {
+		log.Printf("Active window: app=%q title=%q pid=%d", win.AppID, win.Title, win.PID)
+	}
+	if url := win.URL(); url != "" {
+		return uipkg.Options{URL: url}
+	}
+	return uipkg.Options{Query: win.Query()}
 }
 
 func bitwardenMain() {
 	flag.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flag.StringVar(&urlFilter, "url", "", "Only show items with a login URI matching this URL")
+	flag.BoolVar(&fromWindow, "from-window", false, "Pre-filter by the focused window's URL or application")
 	flag.Parse()
 
 	lockFile, err := util.AcquireAppLock()
@@ -142,55 +192,58 @@ func bitwardenMain() {
 		log.Println("Debug is enabled")
 	}
 
+	opts := uipkg.Options{URL: urlFilter}
+	if fromWindow && opts.URL == "" {
+		opts = windowOptions()
+	}
+
 	config, err := cfgpkg.Load()
 	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v\n", err)
 		os.Exit(1)
 	}
 
-	sessionKey, err := keychain.GetSessionKey()
-	if err == nil && sessionKey != "" {
-		os.Setenv("BW_SESSION", sessionKey)
-	}
+	loadSessionKey()
 
-	var bwServeCmd *exec.Cmd
-	if config.ApiMode {
-		if apiUrl, ok := serve.FindAdvertised(); ok {
-			bwManager = bwpkg.NewAPIManager(apiUrl)
-		} else {
-			apiUrl, cmd, err := serve.Start()
-			if err != nil {
-				fmt.Printf("Alas, there's been an error: %v\n", err)
-				os.Exit(1)
-			}
-			bwServeCmd = cmd
-			bwManager = bwpkg.NewAPIManager(apiUrl)
-		}
-	} else {
-		bwManager = bwpkg.NewProcessManager()
+	var bwServer *serve.Server
+	bwManager, bwServer, err = connectManager(config, true)
+	if err != nil {
+		fmt.Printf("Alas, there's been an error: %v\n", err)
+		os.Exit(1)
 	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		<-c
-		if bwServeCmd != nil {
-			bwServeCmd.Process.Kill()
+		if bwServer != nil {
+			bwServer.Close()
 		}
 		os.Exit(0)
 	}()
 
-	p := tea.NewProgram(uipkg.InitialModel(bwManager, config))
+	p := tea.NewProgram(uipkg.InitialModel(bwManager, config, opts))
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v\n", err)
 		os.Exit(1)
 	}
-	if bwServeCmd != nil {
-		bwServeCmd.Process.Kill()
+	if bwServer != nil {
+		bwServer.Close()
 	}
 }
 
 func main() {
+	switch filepath.Base(os.Args[0]) {
+	case dockerCredentialName:
+		dockerCredentialSubcommand(os.Args[1:])
+		return
+	case askpassName:
+		askpassSubcommand(os.Args[1:])
+		return
+	case pinentryName:
+		pinentrySubcommand(os.Args[1:])
+		return
+	}
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "clear-clipboard":
@@ -198,7 +251,40 @@ func main() {
 			clearClipboardSubcommand()
 			return
 		case "serve":
-			serveSubcommand()
+			serveSubcommand(os.Args[2:])
+			return
+		case "get":
+			getSubcommand(os.Args[2:])
+			return
+		case "list":
+			listSubcommand(os.Args[2:])
+			return
+		case "audit":
+			auditSubcommand(os.Args[2:])
+			return
+		case "ssh-agent":
+			sshAgentSubcommand(os.Args[2:])
+			return
+		case "run":
+			runSubcommand(os.Args[2:])
+			return
+		case "inject":
+			injectSubcommand(os.Args[2:])
+			return
+		case "git-credential":
+			gitCredentialSubcommand(os.Args[2:])
+			return
+		case "docker-credential":
+			dockerCredentialSubcommand(os.Args[2:])
+			return
+		case "askpass":
+			askpassSubcommand(os.Args[2:])
+			return
+		case "pinentry":
+			pinentrySubcommand(os.Args[2:])
+			return
+		case "secret-service":
+			secretServiceSubcommand(os.Args[2:])
 			return
 		}
 	}
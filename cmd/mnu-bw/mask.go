@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+)
+
+const maskText = "<concealed by mnu>"
+
+// maskWriter replaces secret values in a stream before passing it on. A tail
+// that could be the start of a secret is held back until the next write (or
+// Flush) shows whether it is one.
+type maskWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets [][]byte
+	pending []byte
+}
+
+func newMaskWriter(w io.Writer, secrets []string) *maskWriter {
+	mw := &maskWriter{w: w}
+	for _, s := range secrets {
+		if s != "" {
+			mw.secrets = append(mw.secrets, []byte(s))
+		}
+	}
+	// Prefer the longest secret when one contains another.
+	sort.Slice(mw.secrets, func(i, j int) bool { return len(mw.secrets[i]) > len(mw.secrets[j]) })
+	return mw
+}
+
+func (mw *maskWriter) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	out, rest := mw.mask(append(mw.pending, p...), false)
+	mw.pending = append([]byte(nil), rest...)
+	if _, err := mw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any held-back bytes; they cannot complete a secret anymore,
+// but may still hold a shorter one.
+func (mw *maskWriter) Flush() error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	out, _ := mw.mask(mw.pending, true)
+	mw.pending = nil
+	_, err := mw.w.Write(out)
+	return err
+}
+
+// mask replaces the secrets in buf. Unless final, it stops at a tail that
+// could still grow into a secret, longer ones first, and returns it as rest.
+func (mw *maskWriter) mask(buf []byte, final bool) (out, rest []byte) {
+	var b bytes.Buffer
+	i := 0
+scan:
+	for i < len(buf) {
+		for _, s := range mw.secrets {
+			if bytes.HasPrefix(buf[i:], s) {
+				b.WriteString(maskText)
+				i += len(s)
+				continue scan
+			}
+			if !final && len(buf)-i < len(s) && bytes.HasPrefix(s, buf[i:]) {
+				break scan
+			}
+		}
+		b.WriteByte(buf[i])
+		i++
+	}
+	return b.Bytes(), buf[i:]
+}
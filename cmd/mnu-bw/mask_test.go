@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMaskWriter(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		secrets []string
+		writes  []string
+		want    string
+	}{
+		{"no secret", []string{"hunter2"}, []string{"hello world\n"}, "hello world\n"},
+		{"whole secret", []string{"hunter2"}, []string{"pw=hunter2\n"}, "pw=" + maskText + "\n"},
+		{"repeated", []string{"ab"}, []string{"abab-ab"}, maskText + maskText + "-" + maskText},
+		{"split across writes", []string{"hunter2"}, []string{"pw=hun", "ter2\n"}, "pw=" + maskText + "\n"},
+		{"split byte by byte", []string{"s3cret"}, []string{"x", "s", "3", "c", "r", "e", "t", "y"}, "x" + maskText + "y"},
+		{"prefix that does not complete", []string{"hunter2"}, []string{"hunt", "ing\n"}, "hunting\n"},
+		{"longest wins", []string{"token", "token-extended"}, []string{"a token-extended b token c"}, "a " + maskText + " b " + maskText + " c"},
+		{"longest wins across writes", []string{"abc", "abcdef"}, []string{"abc", "def"}, maskText},
+		{"shorter after a failed longer", []string{"abc", "abcdef"}, []string{"abc", "dex"}, maskText + "dex"},
+		{"held back until flush", []string{"hunter2"}, []string{"end: hunt"}, "end: hunt"},
+		{"shorter secret at the end", []string{"abc", "abcdef"}, []string{"x abc"}, "x " + maskText},
+		{"empty secret ignored", []string{""}, []string{"plain"}, "plain"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			mw := newMaskWriter(&out, tt.secrets)
+			for _, w := range tt.writes {
+				n, err := mw.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if err := mw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskWriterHoldsBackPrefix(t *testing.T) {
+	var out bytes.Buffer
+	mw := newMaskWriter(&out, []string{"hunter2"})
+	mw.Write([]byte("pw=hunt"))
+	if got := out.String(); got != "pw=" {
+		t.Fatalf("before Flush got %q, want the possible secret held back", got)
+	}
+	mw.Flush()
+	if got := out.String(); got != "pw=hunt" {
+		t.Errorf("after Flush got %q, want the held-back bytes released", got)
+	}
+	mw.Write([]byte("er2"))
+	mw.Flush()
+	if got := out.String(); got != "pw=hunter2" {
+		t.Errorf("after a second Flush got %q; Flush must end any secret in progress", got)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+)
+
+// Assuan error codes as gpg-agent expects them from a pinentry (error source
+// 5, GPG_ERR_SOURCE_PINENTRY).
+const (
+	assuanErrCancelled  = 5<<24 | 99
+	assuanErrUnknownCmd = 5<<24 | 275
+	assuanErrGeneral    = 5<<24 | 1
+)
+
+// pinentry holds what gpg-agent told us about the passphrase it wants.
+type pinentry struct {
+	config  *cfgpkg.Config
+	mgr     bwpkg.Manager
+	w       *bufio.Writer
+	title   string
+	desc    string
+	prompt  string
+	keyinfo string
+	errText string
+	repeat  bool
+	tty     string
+}
+
+// pinentrySubcommand speaks the pinentry subset of the Assuan protocol on
+// stdin/stdout so gpg-agent (pinentry-program in gpg-agent.conf) can take
+// passphrases from the vault.
+func pinentrySubcommand(args []string) {
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	p := &pinentry{config: config, w: bufio.NewWriter(os.Stdout)}
+	if err := p.serve(os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "pinentry: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+func (p *pinentry) serve(r io.Reader) error {
+	p.ok("Pleased to meet you")
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		cmd = strings.ToUpper(cmd)
+		arg = assuanUnescape(arg)
+		switch cmd {
+		case "BYE":
+			p.ok("closing connection")
+			return nil
+		case "RESET":
+			*p = pinentry{config: p.config, mgr: p.mgr, w: p.w, tty: p.tty}
+			p.ok("")
+		case "OPTION":
+			if k, v, ok := strings.Cut(arg, "="); ok && k == "ttyname" {
+				p.tty = v
+			}
+			p.ok("")
+		case "SETTITLE":
+			p.title = arg
+			p.ok("")
+		case "SETDESC":
+			p.desc = arg
+			p.ok("")
+		case "SETPROMPT":
+			p.prompt = arg
+			p.ok("")
+		case "SETKEYINFO":
+			p.keyinfo = arg
+			if arg == "--clear" {
+				p.keyinfo = ""
+			}
+			p.ok("")
+		case "SETERROR":
+			p.errText = arg
+			p.ok("")
+		case "SETREPEAT":
+			p.repeat = true
+			p.ok("")
+		case "GETINFO":
+			p.getinfo(arg)
+		case "GETPIN":
+			p.getpin()
+		case "CONFIRM", "MESSAGE":
+			// There is nobody to show a message to; only one-button
+			// confirmations (acknowledgements) succeed.
+			if cmd == "MESSAGE" || strings.Contains(arg, "--one-button") {
+				p.ok("")
+			} else {
+				p.err(assuanErrCancelled, "Operation cancelled")
+			}
+		default:
+			// Button labels, timeouts, quality bars and the like.
+			if strings.HasPrefix(cmd, "SET") || cmd == "NOP" || cmd == "CLEARPASSPHRASE" {
+				p.ok("")
+			} else {
+				p.err(assuanErrUnknownCmd, "Unknown IPC command")
+			}
+		}
+	}
+	return sc.Err()
+}
+
+func (p *pinentry) getinfo(what string) {
+	switch what {
+	case "pid":
+		p.data(strconv.Itoa(os.Getpid()))
+	case "version":
+		p.data("1.0.0")
+	case "flavor":
+		p.data("mnu")
+	case "ttyinfo":
+		p.data(p.tty + " - -")
+	default:
+		p.err(assuanErrUnknownCmd, "Unknown IPC command")
+		return
+	}
+	p.ok("")
+}
+
+// getpin answers with the vault secret for the current key. After a failed
+// attempt (SETERROR) the askpass rules are skipped, since they would only
+// produce the same wrong secret again; an item can still be picked if a
+// terminal is available.
+func (p *pinentry) getpin() {
+	if p.mgr == nil {
+		mgr, err := pinentryManager(p.config)
+		if err != nil {
+			p.err(assuanErrGeneral, err.Error())
+			return
+		}
+		p.mgr = mgr
+	}
+
+	var secret string
+	var err error
+	if p.errText != "" {
+		secret, err = pickSecret(p.mgr, p.config, p.tty)
+	} else {
+		text := strings.Join([]string{p.title, p.desc, p.prompt, p.keyinfo}, "\n")
+		secret, err = askpassSecret(p.mgr, p.config, text, p.tty)
+	}
+	p.errText = ""
+	if errors.Is(err, errCancelled) {
+		p.err(assuanErrCancelled, "Operation cancelled")
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pinentry: %v\n", err)
+		p.err(assuanErrCancelled, "Operation cancelled")
+		return
+	}
+	if p.repeat {
+		p.status("PIN_REPEATED")
+	}
+	p.data(secret)
+	p.ok("")
+}
+
+// pinentryManager is unlockedManager without the exits, which would leave
+// gpg-agent without an answer.
+func pinentryManager(config *cfgpkg.Config) (bwpkg.Manager, error) {
+	loadSessionKey()
+	mgr, _, err := connectManager(config, false)
+	if err != nil {
+		return nil, err
+	}
+	loggedIn, err := mgr.IsLoggedIn()
+	if err != nil {
+		return nil, err
+	}
+	if !loggedIn {
+		return nil, errors.New("vault is locked")
+	}
+	return mgr, nil
+}
+
+func (p *pinentry) ok(msg string) {
+	if msg == "" {
+		fmt.Fprintln(p.w, "OK")
+	} else {
+		fmt.Fprintf(p.w, "OK %s\n", msg)
+	}
+	p.w.Flush()
+}
+
+func (p *pinentry) err(code int, msg string) {
+	fmt.Fprintf(p.w, "ERR %d %s <Pinentry>\n", code, msg)
+	p.w.Flush()
+}
+
+func (p *pinentry) status(keyword string) {
+	fmt.Fprintf(p.w, "S %s\n", keyword)
+}
+
+func (p *pinentry) data(s string) {
+	fmt.Fprintf(p.w, "D %s\n", assuanEscape(s))
+}
+
+// assuanEscape percent-escapes the characters a data line may not contain.
+func assuanEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '%', '\r', '\n':
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// assuanUnescape decodes %XX escapes in a command argument.
+func assuanUnescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+)
+
+func TestAssuanEscapeRoundTrip(t *testing.T) {
+	for _, tt := range []struct{ in, escaped string }{
+		{"", ""},
+		{"plain passphrase", "plain passphrase"},
+		{"100%", "100%25"},
+		{"%0A", "%250A"},
+		{"line1\nline2", "line1%0Aline2"},
+		{"crlf\r\n", "crlf%0D%0A"},
+		{"%\r\n%", "%25%0D%0A%25"},
+		{"ünïcödé", "ünïcödé"},
+	} {
+		if got := assuanEscape(tt.in); got != tt.escaped {
+			t.Errorf("assuanEscape(%q) = %q, want %q", tt.in, got, tt.escaped)
+		}
+		if got := assuanUnescape(tt.escaped); got != tt.in {
+			t.Errorf("assuanUnescape(%q) = %q, want %q", tt.escaped, got, tt.in)
+		}
+	}
+}
+
+func TestAssuanUnescapeInvalid(t *testing.T) {
+	// Anything that is not a valid %XX escape is taken literally.
+	for in, want := range map[string]string{
+		"%":      "%",
+		"50%":    "50%",
+		"%4":     "%4",
+		"%zz":    "%zz",
+		"%G0":    "%G0",
+		"%+1":    "%+1",
+		"%%41":   "%A",
+		"%0a":    "\n",
+		"a%2":    "a%2",
+		"%25%":   "%%",
+		"%e2%82": "\xe2\x82",
+	} {
+		if got := assuanUnescape(in); got != want {
+			t.Errorf("assuanUnescape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+const pinentryItems = `[
+	{"id": "gpg", "type": 1, "name": "GPG key", "login": {"password": "pa%ss\nword"}}
+]`
+
+// runPinentry plays script to the pinentry the way gpg-agent would.
+func runPinentry(t *testing.T, script string) (*pinentry, []string) {
+	t.Helper()
+	var out bytes.Buffer
+	p := &pinentry{
+		config: &cfgpkg.Config{AskpassRules: []cfgpkg.AskpassRule{
+			{Prompt: `(?m)^n/0123ABCD$`, Ref: "bw://GPG%20key/password"},
+		}},
+		mgr: newFakeVault(t, pinentryItems, `[]`),
+		w:   bufio.NewWriter(&out),
+	}
+	if err := p.serve(strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	return p, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestPinentrySession(t *testing.T) {
+	script := `OPTION ttyname=/dev/pts/3
+OPTION lc-ctype=C.UTF-8
+GETINFO flavor
+GETINFO ttyinfo
+SETTITLE Passphrase
+SETDESC Please enter the passphrase for%0A"Alice <alice@example.org>"%0A100%25 sure
+SETPROMPT Passphrase:
+SETKEYINFO n/0123ABCD
+SETOK Unlock
+GETPIN
+FROBNICATE
+CONFIRM
+CONFIRM --one-button
+MESSAGE
+BYE
+GETPIN
+`
+	p, got := runPinentry(t, script)
+	want := []string{
+		"OK Pleased to meet you",
+		"OK", // OPTION ttyname
+		"OK", // OPTION lc-ctype
+		"D mnu",
+		"OK",
+		"D /dev/pts/3 - -",
+		"OK",
+		"OK", // SETTITLE
+		"OK", // SETDESC
+		"OK", // SETPROMPT
+		"OK", // SETKEYINFO
+		"OK", // SETOK
+		"D pa%25ss%0Aword",
+		"OK",
+		"ERR 83886355 Unknown IPC command <Pinentry>",
+		"ERR 83886179 Operation cancelled <Pinentry>",
+		"OK", // CONFIRM --one-button
+		"OK", // MESSAGE
+		"OK closing connection",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if wantDesc := "Please enter the passphrase for\n\"Alice <alice@example.org>\"\n100% sure"; p.desc != wantDesc {
+		t.Errorf("desc = %q, want %q", p.desc, wantDesc)
+	}
+	if p.tty != "/dev/pts/3" {
+		t.Errorf("tty = %q", p.tty)
+	}
+}
+
+func TestPinentryRepeatAndReset(t *testing.T) {
+	script := `OPTION ttyname=/dev/pts/3
+SETDESC first key
+SETKEYINFO n/0123ABCD
+SETREPEAT
+GETPIN
+RESET
+GETINFO ttyinfo
+SETKEYINFO n/FFFF
+`
+	p, got := runPinentry(t, script)
+	want := []string{
+		"OK Pleased to meet you",
+		"OK", "OK", "OK", "OK",
+		"S PIN_REPEATED",
+		"D pa%25ss%0Aword",
+		"OK",
+		"OK", // RESET
+		"D /dev/pts/3 - -",
+		"OK",
+		"OK",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if p.desc != "" || p.repeat || p.keyinfo != "n/FFFF" {
+		t.Errorf("RESET left desc %q, repeat %v, keyinfo %q", p.desc, p.repeat, p.keyinfo)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/netbrain/mnu/internal/bwref"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+func runSubcommand(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var envs stringList
+	fs.Var(&envs, "env", "NAME=bw://<item>/<field> to set in the child's environment (repeatable)")
+	mask := fs.Bool("mask", false, "Conceal secret values in the child's stdout and stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw run [--env NAME=bw://item/field]... [--mask] -- command [args...]")
+		fs.PrintDefaults()
+	}
+
+	var command []string
+	for i, a := range args {
+		if a == "--" {
+			args, command = args[:i], args[i+1:]
+			break
+		}
+	}
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 || len(command) == 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	// References come from --env and from inherited variables whose value
+	// is a reference, like `op run`.
+	refs := map[string]string{}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && bwref.IsRef(value) {
+			refs[name] = value
+		}
+	}
+	for _, kv := range envs {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "Invalid --env %q: expected NAME=bw://item/field\n", kv)
+			os.Exit(exitError)
+		}
+		refs[name] = value
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	mgr := unlockedManager(config)
+	resolver, err := bwref.NewResolver(mgr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load items: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	// The child gets neither the session key, which unlockedManager may
+	// have put in BW_SESSION, nor the unresolved references.
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, isRef := refs[name]; !isRef && name != "BW_SESSION" {
+			env = append(env, kv)
+		}
+	}
+	var secrets []string
+	for name, value := range refs {
+		ref, err := bwref.Parse(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(exitError)
+		}
+		secret, err := resolver.Resolve(ref)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(exitError)
+		}
+		env = append(env, name+"="+secret)
+		secrets = append(secrets, secret)
+	}
+
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find %s: %v\n", command[0], err)
+		os.Exit(exitError)
+	}
+	if !*mask {
+		if err := syscall.Exec(path, command, env); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to run %s: %v\n", command[0], err)
+			os.Exit(exitError)
+		}
+	}
+
+	stdout := newMaskWriter(os.Stdout, secrets)
+	stderr := newMaskWriter(os.Stderr, secrets)
+	cmd := exec.Command(path, command[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run %s: %v\n", command[0], err)
+		os.Exit(exitError)
+	}
+	// Pass signals on and let the child decide when to exit.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range sigs {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+	err = cmd.Wait()
+	stdout.Flush()
+	stderr.Flush()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(childExitCode(exitErr))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run %s: %v\n", command[0], err)
+		os.Exit(exitError)
+	}
+}
+
+// childExitCode returns the code to exit with after the child failed. Like a
+// shell, a child killed by a signal gives 128 plus the signal number, where
+// ExitCode would report -1.
+func childExitCode(err *exec.ExitError) int {
+	if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return err.ExitCode()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestChildExitCode(t *testing.T) {
+	for _, tt := range []struct {
+		script string
+		want   int
+	}{
+		{"exit 3", 3},
+		{"kill -TERM $$", 128 + 15},
+		{"kill -KILL $$", 128 + 9},
+	} {
+		err := exec.Command("sh", "-c", tt.script).Run()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("%s: got %v, want an exit error", tt.script, err)
+		}
+		if got := childExitCode(exitErr); got != tt.want {
+			t.Errorf("%s: exit code %d, want %d", tt.script, got, tt.want)
+		}
+	}
+}
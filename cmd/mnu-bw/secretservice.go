@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	"github.com/netbrain/mnu/internal/secretservice"
+)
+
+func secretServiceSubcommand(args []string) {
+	fs := flag.NewFlagSet("secret-service", flag.ExitOnError)
+	folder := fs.String("folder", "", "Vault folder holding the secrets (default: secret_service_folder from the config)")
+	replace := fs.Bool("replace", false, "Take over org.freedesktop.secrets from a provider that allows it")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw secret-service [--folder name] [--replace]")
+		fs.PrintDefaults()
+	}
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	if *folder == "" {
+		*folder = config.SecretServiceFolder
+	}
+	mgr := unlockedManager(config)
+
+	store, err := secretservice.OpenStore(mgr, *folder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open folder: %v\n", err)
+		os.Exit(exitError)
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to the session bus: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer conn.Close()
+
+	svc := secretservice.New(conn, store, *folder)
+	if err := svc.Register(*replace); err != nil {
+		if errors.Is(err, secretservice.ErrNameTaken) {
+			fmt.Fprintf(os.Stderr, "Failed to start: %v (stop it or use --replace)\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to start: %v\n", err)
+		}
+		os.Exit(exitError)
+	}
+	log.Printf("Serving org.freedesktop.secrets from folder %q", *folder)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	clipboardpkg "github.com/netbrain/mnu/internal/clipboard"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	"github.com/netbrain/mnu/internal/keychain"
+	"github.com/netbrain/mnu/internal/lockevents"
+	"github.com/netbrain/mnu/internal/serve"
+)
+
+// serveSubcommand runs the bw serve advertiser, or controls a running one.
+func serveSubcommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	idle := fs.Duration("idle-timeout", 0, "Shut down after this long without clients (default: never; "+serve.DefaultIdleTimeout.String()+" with --install-systemd)")
+	installSystemd := fs.Bool("install-systemd", false, "Write socket-activated systemd user units and exit")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw serve [--idle-timeout d] [--install-systemd] [status|stop|restart|lock|sync]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) > 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	if *installSystemd {
+		if *idle == 0 {
+			*idle = serve.DefaultIdleTimeout
+		}
+		installServeUnits(*idle)
+		return
+	}
+	if len(positional) == 0 {
+		runServe(serve.Options{IdleTimeout: *idle})
+		return
+	}
+	switch positional[0] {
+	case "status":
+		serveStatus()
+	case "stop":
+		if err := stopServe(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to stop mnu-bw serve: %v\n", err)
+			os.Exit(exitError)
+		}
+	case "restart":
+		if err := stopServe(); err != nil && !errors.Is(err, serve.ErrNotRunning) {
+			fmt.Fprintf(os.Stderr, "Failed to stop mnu-bw serve: %v\n", err)
+			os.Exit(exitError)
+		}
+		// spawnServe's ping starts a socket-activated service by itself,
+		// and the spawned one then leaves it alone.
+		if err := spawnServe(*idle); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start mnu-bw serve: %v\n", err)
+			os.Exit(exitError)
+		}
+	case "lock", "sync":
+		if _, err := serve.Control(positional[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to %s: %v\n", positional[0], err)
+			os.Exit(exitError)
+		}
+	default:
+		fs.Usage()
+		os.Exit(exitError)
+	}
+}
+
+func runServe(opts serve.Options) {
+	// An activated socket is our own; asking it would only wait for us.
+	if !serve.SocketActivated() {
+		if ep, ok := serve.FindAdvertised(); ok {
+			fmt.Printf("bw serve already running behind %s\n", ep.Socket)
+			return
+		}
+	}
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if config.LockOnEvents {
+		go func() {
+			if err := lockevents.Watch(nil, lockOnEvent); err != nil {
+				log.Printf("Not locking on suspend, screen lock or logout: %v", err)
+			}
+		}()
+	}
+	if err := serve.RunAdvertiser(opts); err != nil {
+		fmt.Printf("Failed to run bw serve advertiser: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func installServeUnits(idle time.Duration) {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to locate mnu-bw: %v\n", err)
+		os.Exit(exitError)
+	}
+	dir, err := serve.InstallSystemd(exe, idle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write systemd units: %v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Printf("Wrote %s and %s to %s. Enable them with:\n", serve.ServiceUnit, serve.SocketUnit, dir)
+	fmt.Printf("  systemctl --user daemon-reload && systemctl --user enable --now %s\n", serve.SocketUnit)
+}
+
+// lockOnEvent locks everything that could expose the vault once the user
+// has walked away: bw serve, the bw CLI, the stored session key and a
+// secret still waiting in the clipboard.
+func lockOnEvent(reason string) {
+	log.Printf("Locking the vault on %s", reason)
+	if _, err := serve.Control(serve.CmdLock); err != nil {
+		log.Printf("Failed to lock bw serve: %v", err)
+	}
+	if err := bwpkg.NewProcessManager().Lock(); err != nil {
+		log.Printf("Failed to lock bw: %v", err)
+	}
+	if err := keychain.DeleteSessionKey(); err != nil {
+		log.Printf("Failed to delete session key: %v", err)
+	}
+	if err := clipboardpkg.ClearPending(); err != nil {
+		log.Printf("Failed to clear clipboard: %v", err)
+	}
+}
+
+func serveStatus() {
+	resp, err := serve.Control(serve.CmdStatus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get status: %v\n", err)
+		os.Exit(exitError)
+	}
+	st := resp.Status
+	how := "running"
+	if st.Activated {
+		how = "running, socket-activated"
+	}
+	fmt.Printf("mnu-bw serve: %s (pid %d, up %s, %d restart(s))\n",
+		how, st.PID, time.Duration(st.UptimeSeconds)*time.Second, st.Restarts)
+	fmt.Printf("bw serve: pid %d, endpoint %s\n", st.BWPID, st.Socket)
+	vault := st.Vault
+	if st.Profile != "" {
+		vault += " (" + st.Profile + ")"
+	}
+	fmt.Printf("Vault: %s\n", vault)
+	if st.LastSync != "" {
+		fmt.Printf("Last sync: %s\n", st.LastSync)
+	}
+}
+
+// stopServe asks the advertiser to shut down and waits until its process is
+// gone. Pinging instead would start a socket-activated one again.
+func stopServe() error {
+	resp, err := serve.Control(serve.CmdStatus)
+	if err != nil {
+		return err
+	}
+	if _, err := serve.Control(serve.CmdShutdown); err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if err := syscall.Kill(resp.Status.PID, 0); errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("still running after shutdown")
+}
+
+// spawnServe starts `mnu-bw serve` in its own session and waits until it
+// answers.
+func spawnServe(idle time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "serve", "--idle-timeout", idle.String())
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		if _, err := serve.Control(serve.CmdPing); err == nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("did not come up")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	"github.com/netbrain/mnu/internal/sshagent"
+	uipkg "github.com/netbrain/mnu/internal/ui"
+	"github.com/netbrain/mnu/internal/util"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// vaultPollInterval is how often the agent checks whether the vault locked.
+const vaultPollInterval = 15 * time.Second
+
+func sshAgentSubcommand(args []string) {
+	fs := flag.NewFlagSet("ssh-agent", flag.ExitOnError)
+	confirm := fs.Bool("confirm", false, "Ask on this terminal before every signature")
+	socket := fs.String("socket", "", "Socket path (default: ssh-agent.sock in the mnu runtime directory)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mnu-bw ssh-agent [--confirm] [--socket path]")
+		fs.PrintDefaults()
+	}
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	config, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
+	}
+	mgr := unlockedManager(config)
+
+	sock := *socket
+	if sock == "" {
+		runtimeDir, err := util.GetRuntimeDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get runtime directory: %v\n", err)
+			os.Exit(exitError)
+		}
+		sock = filepath.Join(runtimeDir, "ssh-agent.sock")
+	}
+
+	var confirmFn sshagent.ConfirmFunc
+	if *confirm {
+		// Prompts share one terminal, so ask one at a time.
+		var mu sync.Mutex
+		confirmFn = func(k *agent.Key) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			ok, err := uipkg.Confirm(fmt.Sprintf("Allow signing with %q (%s)?", k.Comment, ssh.FingerprintSHA256(k)), 30*time.Second)
+			if err != nil {
+				log.Printf("Confirmation failed: %v", err)
+			}
+			return ok
+		}
+	}
+	a := sshagent.New(confirmFn)
+
+	keys, err := sshagent.LoadKeys(mgr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load keys: %v\n", err)
+		os.Exit(exitError)
+	}
+	if err := a.SetKeys(keys); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load keys: %v\n", err)
+		os.Exit(exitError)
+	}
+	log.Printf("Loaded %d key(s) from the vault", len(keys))
+
+	ln, err := util.ListenPrivate(sock)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start agent: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		a.Clear()
+		ln.Close()
+	}()
+
+	// Drop keys as soon as the vault locks and reload them once it is
+	// unlocked again.
+	go a.Watch(mgr, vaultPollInterval, nil)
+
+	fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", sock)
+	_ = a.Serve(ln)
+	_ = os.Remove(sock)
+}
@@ -34,6 +34,11 @@
           modules = ./gomod2nix.toml;
           subPackages = [ "cmd/mnu-bw" ];
           propagatedBuildInputs = [ pkgs.bitwarden-cli ];
+          postInstall = ''
+            ln -s mnu-bw $out/bin/docker-credential-mnu
+            ln -s mnu-bw $out/bin/mnu-askpass
+            ln -s mnu-bw $out/bin/mnu-pinentry
+          '';
         };
         mnu-run = buildGoApplication {
           pname = "mnu-run";
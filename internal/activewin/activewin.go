@@ -0,0 +1,163 @@
+// Package activewin reports the focused window so mnu-bw can pre-filter the
+// item list to the application it was launched from.
+package activewin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrNoProvider = errors.New("no supported window manager detected")
+
+// Window describes the focused window.
+type Window struct {
+	Title string
+	AppID string
+	// PID is the process owning the window, or 0 when unknown.
+	PID int
+}
+
+// Provider returns the window mnu-bw was launched from: the most recently
+// focused one that does not belong to mnu-bw itself. Started from a
+// terminal, that is the window focused before the terminal.
+type Provider interface {
+	ActiveWindow() (Window, error)
+}
+
+// ownPIDs holds mnu-bw and the processes it was started from, typically a
+// shell and the terminal running it.
+var ownPIDs = sync.OnceValue(func() map[int]bool {
+	pids := map[int]bool{os.Getpid(): true}
+	for pid := os.Getppid(); pid > 1 && !pids[pid]; pid = parentPID(pid) {
+		pids[pid] = true
+	}
+	return pids
+})
+
+// parentPID reads the parent of pid from /proc, returning 0 if unknown.
+func parentPID(pid int) int {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+	// pid (comm) state ppid ...; comm may contain spaces and parentheses.
+	s := string(data)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(fields) < 2 {
+		return 0
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return ppid
+}
+
+// ownWindow reports whether w, with X11 window ID xid (0 if none), belongs
+// to mnu-bw or the terminal it runs in. Terminals on X11 name their window
+// in WINDOWID, which covers i3, where the tree has no PIDs.
+func ownWindow(w Window, xid uint64) bool {
+	if w.PID != 0 && ownPIDs()[w.PID] {
+		return true
+	}
+	if xid == 0 {
+		return false
+	}
+	id, err := strconv.ParseUint(os.Getenv("WINDOWID"), 10, 64)
+	return err == nil && id == xid
+}
+
+// Detect picks a provider for the running session: sway or i3 via their IPC
+// socket, Hyprland via its control socket, or X11 via _NET_ACTIVE_WINDOW.
+func Detect() (Provider, error) {
+	if sock := os.Getenv("SWAYSOCK"); sock != "" {
+		return NewI3(sock), nil
+	}
+	if sock := os.Getenv("I3SOCK"); sock != "" {
+		return NewI3(sock), nil
+	}
+	if sig := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE"); sig != "" {
+		return NewHyprland(hyprlandSocket(sig)), nil
+	}
+	if os.Getenv("DISPLAY") != "" {
+		return NewX11(), nil
+	}
+	return nil, ErrNoProvider
+}
+
+// pick returns the first of windows, which are in focus order, that is not
+// mnu-bw's own. xids holds their X11 window IDs, if known. The focused
+// window is returned when all of them are.
+func pick(windows []Window, xids []uint64) (Window, error) {
+	if len(windows) == 0 {
+		return Window{}, errors.New("no focused window")
+	}
+	for i, w := range windows {
+		var xid uint64
+		if i < len(xids) {
+			xid = xids[i]
+		}
+		if !ownWindow(w, xid) {
+			return w, nil
+		}
+	}
+	return windows[0], nil
+}
+
+func hyprlandSocket(sig string) string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sock := filepath.Join(dir, "hypr", sig, ".socket.sock")
+		if _, err := os.Stat(sock); err == nil {
+			return sock
+		}
+	}
+	return filepath.Join("/tmp", "hypr", sig, ".socket.sock")
+}
+
+var (
+	urlPattern    = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	domainPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}\b`)
+)
+
+var browsers = []string{
+	"firefox", "librewolf", "chromium", "chrome", "brave", "vivaldi",
+	"qutebrowser", "epiphany", "opera", "floorp", "zen",
+}
+
+// IsBrowser reports whether the window belongs to a known web browser.
+func (w Window) IsBrowser() bool {
+	app := strings.ToLower(w.AppID)
+	for _, b := range browsers {
+		if strings.Contains(app, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns a URL found in the window title. Browser titles are also
+// searched for a bare domain name, which is returned as an https URL.
+func (w Window) URL() string {
+	if u := urlPattern.FindString(w.Title); u != "" {
+		return u
+	}
+	if w.IsBrowser() {
+		if d := domainPattern.FindString(w.Title); d != "" {
+			return "https://" + strings.ToLower(d)
+		}
+	}
+	return ""
+}
+
+// Query returns a search query for the window's application: the last
+// component of the app ID, so org.gnome.Nautilus becomes nautilus.
+func (w Window) Query() string {
+	app := strings.TrimSpace(w.AppID)
+	if i := strings.LastIndex(app, "."); i >= 0 && i < len(app)-1 {
+		app = app[i+1:]
+	}
+	return strings.ToLower(app)
+}
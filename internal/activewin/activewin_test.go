@@ -0,0 +1,43 @@
+package activewin
+
+import "testing"
+
+func TestWindowURL(t *testing.T) {
+	tests := []struct {
+		name string
+		win  Window
+		want string
+	}{
+		{"url in title", Window{Title: "Login - https://github.com/login — Mozilla Firefox", AppID: "firefox"}, "https://github.com/login"},
+		{"url outside browser", Window{Title: "curl http://localhost:8080/api", AppID: "Alacritty"}, "http://localhost:8080/api"},
+		{"domain in browser title", Window{Title: "GitHub.com - Brave", AppID: "brave-browser"}, "https://github.com"},
+		{"domain outside browser", Window{Title: "notes about example.com", AppID: "org.gnome.TextEditor"}, ""},
+		{"no url", Window{Title: "Inbox", AppID: "thunderbird"}, ""},
+		{"empty", Window{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.win.URL(); got != tt.want {
+				t.Errorf("URL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowQuery(t *testing.T) {
+	tests := []struct {
+		appID string
+		want  string
+	}{
+		{"org.gnome.Nautilus", "nautilus"},
+		{"Slack", "slack"},
+		{" thunderbird ", "thunderbird"},
+		{"trailing.", "trailing."},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := (Window{AppID: tt.appID}).Query(); got != tt.want {
+			t.Errorf("Query() for %q = %q, want %q", tt.appID, got, tt.want)
+		}
+	}
+}
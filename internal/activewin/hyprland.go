@@ -0,0 +1,53 @@
+package activewin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+// Hyprland queries the compositor's request socket.
+type Hyprland struct{ sock string }
+
+func NewHyprland(sock string) Provider { return &Hyprland{sock: sock} }
+
+// ActiveWindow goes through the clients in focus history order.
+func (p *Hyprland) ActiveWindow() (Window, error) {
+	conn, err := net.DialTimeout("unix", p.sock, time.Second)
+	if err != nil {
+		return Window{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte("j/clients")); err != nil {
+		return Window{}, err
+	}
+	body, err := io.ReadAll(conn)
+	if err != nil {
+		return Window{}, err
+	}
+	var clients []struct {
+		Class          string `json:"class"`
+		Title          string `json:"title"`
+		PID            int    `json:"pid"`
+		Mapped         bool   `json:"mapped"`
+		FocusHistoryID int    `json:"focusHistoryID"`
+	}
+	if err := json.Unmarshal(body, &clients); err != nil {
+		return Window{}, fmt.Errorf("unexpected reply from hyprland: %w", err)
+	}
+	// focusHistoryID is 0 for the focused window, 1 for the one before...
+	sort.SliceStable(clients, func(i, j int) bool {
+		return clients[i].FocusHistoryID < clients[j].FocusHistoryID
+	})
+	var windows []Window
+	for _, c := range clients {
+		if c.Mapped && c.FocusHistoryID >= 0 {
+			windows = append(windows, Window{Title: c.Title, AppID: c.Class, PID: c.PID})
+		}
+	}
+	return pick(windows, nil)
+}
@@ -0,0 +1,78 @@
+package activewin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveHyprland answers j/clients on a stand-in control socket.
+func serveHyprland(t *testing.T, clients string) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), ".socket.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 64)
+			n, _ := conn.Read(buf)
+			if string(buf[:n]) == "j/clients" {
+				conn.Write([]byte(clients))
+			}
+			conn.Close()
+		}
+	}()
+	return sock
+}
+
+func hyprlandClients(termPID int) string {
+	return fmt.Sprintf(`[
+		{"class": "Slack", "title": "Slack", "pid": 800, "mapped": true, "focusHistoryID": 2},
+		{"class": "kitty", "title": "zsh", "pid": %d, "mapped": true, "focusHistoryID": 0},
+		{"class": "hidden", "title": "unmapped", "pid": 900, "mapped": false, "focusHistoryID": 1},
+		{"class": "firefox", "title": "GitHub — Mozilla Firefox", "pid": 700, "mapped": true, "focusHistoryID": 3}
+	]`, termPID)
+}
+
+func TestHyprlandSkipsOwnTerminal(t *testing.T) {
+	sock := serveHyprland(t, hyprlandClients(os.Getpid()))
+	win, err := NewHyprland(sock).ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Window{Title: "Slack", AppID: "Slack", PID: 800}
+	if win != want {
+		t.Errorf("ActiveWindow() = %+v, want %+v", win, want)
+	}
+}
+
+func TestHyprlandFocusedWindow(t *testing.T) {
+	if ownPIDs()[600] {
+		t.Skip("pid 600 runs the test")
+	}
+	sock := serveHyprland(t, hyprlandClients(600))
+	win, err := NewHyprland(sock).ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Window{Title: "zsh", AppID: "kitty", PID: 600}
+	if win != want {
+		t.Errorf("ActiveWindow() = %+v, want %+v", win, want)
+	}
+}
+
+func TestHyprlandBadReply(t *testing.T) {
+	sock := serveHyprland(t, "unknown request")
+	if _, err := NewHyprland(sock).ActiveWindow(); err == nil {
+		t.Error("ActiveWindow() succeeded on a non-JSON reply")
+	}
+}
@@ -0,0 +1,121 @@
+package activewin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+const (
+	i3Magic   = "i3-ipc"
+	i3GetTree = 4
+)
+
+// I3 talks to sway or i3 over their shared IPC protocol.
+type I3 struct{ sock string }
+
+func NewI3(sock string) Provider { return &I3{sock: sock} }
+
+type i3Node struct {
+	ID               int64    `json:"id"`
+	Type             string   `json:"type"`
+	Name             string   `json:"name"`
+	AppID            string   `json:"app_id"`
+	PID              int      `json:"pid"`    // sway only
+	Window           uint64   `json:"window"` // X11 window ID
+	Focus            []int64  `json:"focus"`
+	Nodes            []i3Node `json:"nodes"`
+	FloatingNodes    []i3Node `json:"floating_nodes"`
+	WindowProperties struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+}
+
+func (p *I3) ActiveWindow() (Window, error) {
+	conn, err := net.DialTimeout("unix", p.sock, time.Second)
+	if err != nil {
+		return Window{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	req := make([]byte, len(i3Magic)+8)
+	copy(req, i3Magic)
+	binary.LittleEndian.PutUint32(req[len(i3Magic):], 0)
+	binary.LittleEndian.PutUint32(req[len(i3Magic)+4:], i3GetTree)
+	if _, err := conn.Write(req); err != nil {
+		return Window{}, err
+	}
+
+	header := make([]byte, len(i3Magic)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return Window{}, err
+	}
+	if string(header[:len(i3Magic)]) != i3Magic {
+		return Window{}, fmt.Errorf("unexpected IPC reply")
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header[len(i3Magic):]))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return Window{}, err
+	}
+	var root i3Node
+	if err := json.Unmarshal(body, &root); err != nil {
+		return Window{}, err
+	}
+	var windows []Window
+	var xids []uint64
+	for _, n := range root.windows(nil) {
+		app := n.AppID
+		if app == "" {
+			app = n.WindowProperties.Class
+		}
+		windows = append(windows, Window{Title: n.Name, AppID: app, PID: n.PID})
+		xids = append(xids, n.Window)
+	}
+	return pick(windows, xids)
+}
+
+// windows appends the windows under n in focus order: each container lists
+// its children in the order they were focused, most recent first.
+func (n *i3Node) windows(out []*i3Node) []*i3Node {
+	children := make([]*i3Node, 0, len(n.Nodes)+len(n.FloatingNodes))
+	for i := range n.Nodes {
+		children = append(children, &n.Nodes[i])
+	}
+	for i := range n.FloatingNodes {
+		children = append(children, &n.FloatingNodes[i])
+	}
+	if len(children) == 0 {
+		if (n.Type == "con" || n.Type == "floating_con") && (n.PID != 0 || n.Window != 0 || n.AppID != "") {
+			out = append(out, n)
+		}
+		return out
+	}
+	rank := make(map[int64]int, len(n.Focus))
+	for i, id := range n.Focus {
+		rank[id] = i
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		ri, ok := rank[children[i].ID]
+		if !ok {
+			ri = len(n.Focus)
+		}
+		rj, ok := rank[children[j].ID]
+		if !ok {
+			rj = len(n.Focus)
+		}
+		return ri < rj
+	})
+	for _, c := range children {
+		// __i3 holds the scratchpad, whose windows are hidden.
+		if c.Type == "output" && c.Name == "__i3" {
+			continue
+		}
+		out = c.windows(out)
+	}
+	return out
+}
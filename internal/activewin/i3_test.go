@@ -0,0 +1,116 @@
+package activewin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveI3 answers GET_TREE requests on a stand-in IPC socket with tree.
+func serveI3(t *testing.T, tree string) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "ipc.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			header := make([]byte, len(i3Magic)+8)
+			if _, err := io.ReadFull(conn, header); err == nil &&
+				binary.LittleEndian.Uint32(header[len(i3Magic)+4:]) == i3GetTree {
+				reply := make([]byte, len(i3Magic)+8)
+				copy(reply, i3Magic)
+				binary.LittleEndian.PutUint32(reply[len(i3Magic):], uint32(len(tree)))
+				binary.LittleEndian.PutUint32(reply[len(i3Magic)+4:], i3GetTree)
+				conn.Write(append(reply, tree...))
+			}
+			conn.Close()
+		}
+	}()
+	return sock
+}
+
+// i3Tree is a sway tree with the terminal (pid termPID) focused, Firefox
+// focused before it on the same workspace, and Slack on another workspace,
+// next to a hidden scratchpad window.
+func i3Tree(termPID, termWindow int) string {
+	return fmt.Sprintf(`{"id": 1, "type": "root", "name": "root", "focus": [3, 2], "nodes": [
+		{"id": 2, "type": "output", "name": "__i3", "focus": [20], "nodes": [
+			{"id": 20, "type": "workspace", "name": "__i3_scratch", "focus": [21], "nodes": [],
+			 "floating_nodes": [{"id": 21, "type": "floating_con", "name": "scratch", "app_id": "keepassxc", "pid": 900}]}
+		]},
+		{"id": 3, "type": "output", "name": "eDP-1", "focus": [31, 30], "nodes": [
+			{"id": 30, "type": "workspace", "name": "2", "focus": [300], "nodes": [
+				{"id": 300, "type": "con", "name": "Slack", "app_id": "Slack", "pid": 800}
+			]},
+			{"id": 31, "type": "workspace", "name": "1", "focus": [311, 310], "nodes": [
+				{"id": 310, "type": "con", "name": "GitHub — Mozilla Firefox", "app_id": "firefox", "pid": 700},
+				{"id": 311, "type": "con", "name": "zsh", "focused": true, "pid": %d, "window": %d,
+				 "window_properties": {"class": "XTerm"}}
+			]}
+		]}
+	]}`, termPID, termWindow)
+}
+
+func TestI3SkipsOwnTerminal(t *testing.T) {
+	sock := serveI3(t, i3Tree(os.Getpid(), 0))
+	win, err := NewI3(sock).ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Window{Title: "GitHub — Mozilla Firefox", AppID: "firefox", PID: 700}
+	if win != want {
+		t.Errorf("ActiveWindow() = %+v, want %+v", win, want)
+	}
+}
+
+func TestI3SkipsWindowID(t *testing.T) {
+	// i3 has no PIDs in its tree; terminals name their window in WINDOWID.
+	t.Setenv("WINDOWID", "4194307")
+	sock := serveI3(t, i3Tree(0, 4194307))
+	win, err := NewI3(sock).ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if win.AppID != "firefox" {
+		t.Errorf("ActiveWindow() = %+v, want firefox", win)
+	}
+}
+
+func TestI3FocusedWindow(t *testing.T) {
+	if ownPIDs()[600] {
+		t.Skip("pid 600 runs the test")
+	}
+	// Launched from a hotkey, the focused window is not ours.
+	t.Setenv("WINDOWID", "")
+	sock := serveI3(t, i3Tree(600, 0))
+	win, err := NewI3(sock).ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Window{Title: "zsh", AppID: "XTerm", PID: 600}
+	if win != want {
+		t.Errorf("ActiveWindow() = %+v, want %+v", win, want)
+	}
+}
+
+func TestI3NoWindows(t *testing.T) {
+	sock := serveI3(t, `{"id": 1, "type": "root", "focus": [3], "nodes": [
+		{"id": 3, "type": "output", "focus": [31], "nodes": [
+			{"id": 31, "type": "workspace", "name": "1", "focused": true, "nodes": []}
+		]}
+	]}`)
+	if _, err := NewI3(sock).ActiveWindow(); err == nil {
+		t.Error("ActiveWindow() succeeded on an empty tree")
+	}
+}
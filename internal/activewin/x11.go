@@ -0,0 +1,124 @@
+package activewin
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// X11 reads _NET_ACTIVE_WINDOW, _NET_CLIENT_LIST_STACKING and the
+// properties of the listed windows using xprop.
+type X11 struct{}
+
+func NewX11() Provider { return &X11{} }
+
+// ActiveWindow goes through the active window and then the others from the
+// top of the stack down, which approximates focus order.
+func (p *X11) ActiveWindow() (Window, error) {
+	out, err := exec.Command("xprop", "-root", "_NET_ACTIVE_WINDOW", "_NET_CLIENT_LIST_STACKING").Output()
+	if err != nil {
+		return Window{}, fmt.Errorf("xprop failed: %w", err)
+	}
+	// _NET_ACTIVE_WINDOW(WINDOW): window id # 0x1e00003
+	// _NET_CLIENT_LIST_STACKING(WINDOW): window id # 0x1c00007, 0x1e00003
+	var active string
+	var stacking []string
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(line, "# ")
+		if !ok {
+			continue
+		}
+		ids := strings.Split(value, ", ")
+		switch {
+		case strings.HasPrefix(key, "_NET_ACTIVE_WINDOW"):
+			active = strings.TrimSpace(ids[0])
+		case strings.HasPrefix(key, "_NET_CLIENT_LIST_STACKING"):
+			stacking = ids
+		}
+	}
+	ids := []string{}
+	if active != "" && active != "0x0" {
+		ids = append(ids, active)
+	}
+	for i := len(stacking) - 1; i >= 0; i-- {
+		if id := strings.TrimSpace(stacking[i]); id != active {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return Window{}, fmt.Errorf("no focused window")
+	}
+
+	var first Window
+	for i, id := range ids {
+		w, normal, err := x11Window(id)
+		if err != nil {
+			return Window{}, err
+		}
+		if i == 0 {
+			first = w
+		}
+		xid, _ := strconv.ParseUint(strings.TrimPrefix(id, "0x"), 16, 64)
+		if normal && !ownWindow(w, xid) {
+			return w, nil
+		}
+	}
+	return first, nil
+}
+
+// x11Window reads the name, class and PID of a window, and whether it is an
+// application window rather than a panel or the desktop.
+func x11Window(id string) (Window, bool, error) {
+	out, err := exec.Command("xprop", "-id", id, "_NET_WM_NAME", "WM_CLASS", "_NET_WM_PID", "_NET_WM_WINDOW_TYPE").Output()
+	if err != nil {
+		return Window{}, false, fmt.Errorf("xprop failed: %w", err)
+	}
+	var w Window
+	normal := true
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		values := quotedValues(value)
+		switch {
+		case strings.HasPrefix(key, "_NET_WM_NAME") && len(values) > 0:
+			w.Title = values[0]
+		case strings.HasPrefix(key, "WM_CLASS") && len(values) > 0:
+			// WM_CLASS is "instance", "class"; the class is the app name.
+			w.AppID = values[len(values)-1]
+		case strings.HasPrefix(key, "_NET_WM_PID"):
+			w.PID, _ = strconv.Atoi(strings.TrimSpace(value))
+		case strings.HasPrefix(key, "_NET_WM_WINDOW_TYPE"):
+			normal = !strings.Contains(value, "_NET_WM_WINDOW_TYPE_DOCK") &&
+				!strings.Contains(value, "_NET_WM_WINDOW_TYPE_DESKTOP")
+		}
+	}
+	return w, normal, nil
+}
+
+// quotedValues extracts the double-quoted strings from an xprop value.
+func quotedValues(s string) []string {
+	var values []string
+	for {
+		start := strings.IndexByte(s, '"')
+		if start < 0 {
+			return values
+		}
+		s = s[start+1:]
+		var b strings.Builder
+		i := 0
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			b.WriteByte(s[i])
+		}
+		values = append(values, b.String())
+		if i >= len(s) {
+			return values
+		}
+		s = s[i+1:]
+	}
+}
@@ -0,0 +1,181 @@
+// Package audit produces a local vault health report: weak, reused and old
+// passwords, and logins without two-factor codes. Passwords are only held in
+// memory while they are scored and hashed; they are never part of a report.
+package audit
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unicode/utf16"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	"golang.org/x/crypto/md4"
+)
+
+// Kind is the category of a finding.
+type Kind string
+
+const (
+	KindBreached Kind = "breached"
+	KindWeak     Kind = "weak"
+	KindReused   Kind = "reused"
+	KindOld      Kind = "old"
+	KindNoTotp   Kind = "no-totp"
+)
+
+// Title is the human readable heading for findings of this kind.
+func (k Kind) Title() string {
+	switch k {
+	case KindBreached:
+		return "Breached passwords"
+	case KindWeak:
+		return "Weak passwords"
+	case KindReused:
+		return "Reused passwords"
+	case KindOld:
+		return "Old passwords"
+	case KindNoTotp:
+		return "Logins without TOTP"
+	}
+	return string(k)
+}
+
+// Kinds lists the finding kinds in report order.
+var Kinds = []Kind{KindBreached, KindWeak, KindReused, KindOld, KindNoTotp}
+
+// Entry is what the audit knows about one login. The password is reduced to
+// a strength score and hashes when the entry is collected.
+type Entry struct {
+	Item  bwpkg.Item
+	Score Score
+	Hash  [sha256.Size]byte
+	// SHA1 and NTLM are the hashes used by the Pwned Passwords files.
+	SHA1 [sha1.Size]byte
+	NTLM [md4.Size]byte
+}
+
+// Finding is one problem with one item.
+type Finding struct {
+	Kind   Kind
+	Item   bwpkg.Item
+	Detail string
+}
+
+// Options configure the checks.
+type Options struct {
+	// MaxAge flags passwords that have not changed for longer than this.
+	MaxAge time.Duration
+	// Breaches, if set, is checked for every password.
+	Breaches *BreachDB
+}
+
+// Collect fetches all items with their secrets and reduces every login
+// password to an Entry.
+func Collect(mgr bwpkg.Manager) ([]Entry, error) {
+	raw, err := mgr.GetItems()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(raw))
+	for _, r := range raw {
+		item := bwpkg.ItemFromMap(r)
+		if item.Type != bwpkg.TypeLogin || !item.HasPassword {
+			continue
+		}
+		pw := bwpkg.LoginPassword(r)
+		entries = append(entries, Entry{
+			Item:  item,
+			Score: Strength(pw),
+			Hash:  sha256.Sum256([]byte(pw)),
+			SHA1:  sha1.Sum([]byte(pw)),
+			NTLM:  ntlm(pw),
+		})
+	}
+	return entries, nil
+}
+
+// Check runs all checks and returns the findings grouped by kind, in the
+// order of Kinds.
+func Check(entries []Entry, opts Options) ([]Finding, error) {
+	var findings []Finding
+
+	if opts.Breaches != nil {
+		for _, e := range entries {
+			hash := e.SHA1[:]
+			if opts.Breaches.IsNTLM() {
+				hash = e.NTLM[:]
+			}
+			count, err := opts.Breaches.Count(hash)
+			if err != nil {
+				return nil, fmt.Errorf("breach lookup failed: %w", err)
+			}
+			if count > 0 {
+				findings = append(findings, Finding{Kind: KindBreached, Item: e.Item, Detail: fmt.Sprintf("seen %d time(s) in breaches", count)})
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if e.Score <= Weak {
+			findings = append(findings, Finding{Kind: KindWeak, Item: e.Item, Detail: "strength: " + e.Score.String()})
+		}
+	}
+
+	groups := map[[sha256.Size]byte][]Entry{}
+	var order [][sha256.Size]byte
+	for _, e := range entries {
+		if _, ok := groups[e.Hash]; !ok {
+			order = append(order, e.Hash)
+		}
+		groups[e.Hash] = append(groups[e.Hash], e)
+	}
+	for _, h := range order {
+		group := groups[h]
+		if len(group) < 2 {
+			continue
+		}
+		for _, e := range group {
+			findings = append(findings, Finding{Kind: KindReused, Item: e.Item, Detail: fmt.Sprintf("shared with %d other item(s)", len(group)-1)})
+		}
+	}
+
+	if opts.MaxAge > 0 {
+		now := time.Now()
+		for _, e := range entries {
+			changed := e.Item.PasswordRevisionDate
+			if changed.IsZero() {
+				changed = e.Item.RevisionDate
+			}
+			if changed.IsZero() || now.Sub(changed) <= opts.MaxAge {
+				continue
+			}
+			days := int(now.Sub(changed).Hours() / 24)
+			findings = append(findings, Finding{Kind: KindOld, Item: e.Item, Detail: fmt.Sprintf("unchanged for %d days", days)})
+		}
+	}
+
+	for _, e := range entries {
+		if !e.Item.HasTotp {
+			findings = append(findings, Finding{Kind: KindNoTotp, Item: e.Item, Detail: "no TOTP configured"})
+		}
+	}
+
+	return findings, nil
+}
+
+// ntlm returns the NTLM hash of a password: MD4 over its UTF-16LE encoding.
+func ntlm(pw string) [md4.Size]byte {
+	units := utf16.Encode([]rune(pw))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	h := md4.New()
+	h.Write(b)
+	var sum [md4.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
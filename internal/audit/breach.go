@@ -0,0 +1,267 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// BreachDB looks up password hashes in a locally downloaded Pwned Passwords
+// file: one "HASH:COUNT" line per hash, sorted by hash. Both the SHA-1 and the
+// NTLM editions are supported; the edition is detected from the first line.
+//
+// Lookups binary-search the file directly. An optional index, written next to
+// the file by BuildBreachIndex, narrows each search to a single 16-bit hash
+// prefix so large files need only a few reads per lookup.
+type BreachDB struct {
+	f       *os.File
+	size    int64
+	hashLen int
+	index   []int64
+}
+
+const (
+	sha1HexLen = 40
+	ntlmHexLen = 32
+
+	breachIndexMagic   = "MNUBIDX1"
+	breachIndexBuckets = 1 << 16
+	// Below this many bytes the search falls back to a linear scan.
+	breachScanWindow = 64 * 1024
+)
+
+// OpenBreachDB opens a Pwned Passwords file and its index, if one exists and
+// matches the file.
+func OpenBreachDB(path string) (*BreachDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		f.Close()
+		return nil, fmt.Errorf("breach database %s is empty", path)
+	}
+	hash, _, ok := bytes.Cut(line, []byte(":"))
+	if !ok || (len(hash) != sha1HexLen && len(hash) != ntlmHexLen) {
+		f.Close()
+		return nil, fmt.Errorf("breach database %s: expected HASH:COUNT lines with SHA-1 or NTLM hashes", path)
+	}
+	db := &BreachDB{f: f, size: st.Size(), hashLen: len(hash)}
+	if index, err := readBreachIndex(path+".idx", st); err == nil {
+		db.index = index
+	}
+	return db, nil
+}
+
+// Close closes the underlying file.
+func (db *BreachDB) Close() error { return db.f.Close() }
+
+// IsNTLM reports whether the file holds NTLM rather than SHA-1 hashes.
+func (db *BreachDB) IsNTLM() bool { return db.hashLen == ntlmHexLen }
+
+// Indexed reports whether an index is in use.
+func (db *BreachDB) Indexed() bool { return db.index != nil }
+
+// Count returns how often the hash was seen in breaches, or 0 if it was not.
+func (db *BreachDB) Count(hash []byte) (int, error) {
+	if len(hash)*2 != db.hashLen {
+		return 0, fmt.Errorf("hash length %d does not match the breach database", len(hash))
+	}
+	target := []byte(hex.EncodeToString(hash))
+	toUpper(target)
+
+	lo, hi := int64(0), db.size
+	if db.index != nil {
+		bucket := binary.BigEndian.Uint16(hash)
+		lo, hi = db.index[bucket], db.index[int(bucket)+1]
+	}
+
+	// Invariant: lo is the start of a line whose hash is < target (or the
+	// start of the range) and every line starting at or after hi is >= target.
+	for hi-lo > breachScanWindow {
+		mid := lo + (hi-lo)/2
+		start, lineHash, err := db.lineAfter(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			break
+		}
+		if bytes.Compare(lineHash, target) < 0 {
+			lo = start
+		} else {
+			hi = start
+		}
+	}
+	return db.scan(lo, hi, target)
+}
+
+// lineAfter returns the offset and hash of the first line starting after off.
+func (db *BreachDB) lineAfter(off int64) (int64, []byte, error) {
+	r := bufio.NewReader(io.NewSectionReader(db.f, off, db.size-off))
+	skipped, err := r.ReadBytes('\n')
+	if err == io.EOF {
+		return db.size, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	line, err := r.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		return db.size, nil, nil
+	}
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	}
+	hash, _, _ := bytes.Cut(line, []byte(":"))
+	toUpper(hash)
+	return off + int64(len(skipped)), hash, nil
+}
+
+// scan reads lines in [lo, hi] looking for target.
+func (db *BreachDB) scan(lo, hi int64, target []byte) (int, error) {
+	r := bufio.NewReader(io.NewSectionReader(db.f, lo, db.size-lo))
+	pos := lo
+	for pos <= hi {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			pos += int64(len(line))
+			hash, count, _ := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+			toUpper(hash)
+			switch c := bytes.Compare(hash, target); {
+			case c == 0:
+				n, err := strconv.Atoi(string(count))
+				if err != nil {
+					return 1, nil
+				}
+				return n, nil
+			case c > 0:
+				return 0, nil
+			}
+		}
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// BuildBreachIndex writes <path>.idx holding the offset of the first line for
+// every 16-bit hash prefix. It is about 512 KiB regardless of the file size.
+func BuildBreachIndex(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	index := make([]int64, breachIndexBuckets+1)
+	next := 0
+	r := bufio.NewReaderSize(f, 1<<20)
+	var pos int64
+	for {
+		line, err := r.ReadSlice('\n')
+		if len(line) >= 4 {
+			prefix, perr := strconv.ParseUint(string(line[:4]), 16, 16)
+			if perr != nil {
+				return fmt.Errorf("breach database %s: malformed line at offset %d", path, pos)
+			}
+			for next <= int(prefix) {
+				index[next] = pos
+				next++
+			}
+		}
+		pos += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return fmt.Errorf("breach database %s: line too long at offset %d", path, pos)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for ; next <= breachIndexBuckets; next++ {
+		index[next] = pos
+	}
+
+	tmp := path + ".idx.tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	w.WriteString(breachIndexMagic)
+	binary.Write(w, binary.BigEndian, st.Size())
+	binary.Write(w, binary.BigEndian, st.ModTime().UnixNano())
+	if err := binary.Write(w, binary.BigEndian, index); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path+".idx")
+}
+
+// readBreachIndex loads an index, rejecting it if the data file changed.
+func readBreachIndex(path string, data os.FileInfo) ([]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(breachIndexMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != breachIndexMagic {
+		return nil, fmt.Errorf("not a breach index")
+	}
+	var size, mtime int64
+	binary.Read(r, binary.BigEndian, &size)
+	if err := binary.Read(r, binary.BigEndian, &mtime); err != nil {
+		return nil, err
+	}
+	if size != data.Size() || mtime != data.ModTime().UnixNano() {
+		return nil, fmt.Errorf("breach index is stale")
+	}
+	index := make([]int64, breachIndexBuckets+1)
+	if err := binary.Read(r, binary.BigEndian, index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func toUpper(b []byte) {
+	for i, c := range b {
+		if 'a' <= c && c <= 'f' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+}
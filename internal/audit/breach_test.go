@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBreachFixture writes a sorted Pwned Passwords style file holding the
+// given hashes plus enough filler to make Count bisect: a few thousand random
+// hashes, and a few thousand more sharing each given hash's 16-bit prefix so
+// the indexed search has to bisect within the bucket as well.
+func writeBreachFixture(t *testing.T, hexLen int, hashes map[string]int) string {
+	t.Helper()
+	counts := make(map[string]int, len(hashes))
+	for h, n := range hashes {
+		counts[h] = n
+	}
+	filler := func(i int) string {
+		sum := sha1.Sum([]byte(fmt.Sprintf("filler %d", i)))
+		return strings.ToUpper(hex.EncodeToString(sum[:]))[:hexLen]
+	}
+	i := 0
+	for range 3000 {
+		counts[filler(i)] = i%50 + 1
+		i++
+	}
+	for h := range hashes {
+		for range 2000 {
+			counts[h[:4]+filler(i)[4:]] = i%50 + 1
+			i++
+		}
+	}
+
+	keys := make([]string, 0, len(counts))
+	for h := range counts {
+		keys = append(keys, h)
+	}
+	slices.Sort(keys)
+	var b strings.Builder
+	for _, h := range keys {
+		fmt.Fprintf(&b, "%s:%d\r\n", h, counts[h])
+	}
+	if b.Len() < 2*breachScanWindow {
+		t.Fatalf("fixture is only %d bytes", b.Len())
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachDB(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ntlm bool
+		hash func(string) []byte
+	}{
+		{"sha1", false, func(pw string) []byte { s := sha1.Sum([]byte(pw)); return s[:] }},
+		{"ntlm", true, func(pw string) []byte { s := ntlm(pw); return s[:] }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hexOf := func(pw string) string { return strings.ToUpper(hex.EncodeToString(tt.hash(pw))) }
+			hits := map[string]int{"password": 9545824, "hunter2": 17043, "Tr0ub4dor&3": 84}
+			hashes := make(map[string]int)
+			for pw, n := range hits {
+				hashes[hexOf(pw)] = n
+			}
+			path := writeBreachFixture(t, len(hexOf("")), hashes)
+
+			check := func(db *BreachDB) {
+				t.Helper()
+				if db.IsNTLM() != tt.ntlm {
+					t.Errorf("IsNTLM() = %v, want %v", db.IsNTLM(), tt.ntlm)
+				}
+				for pw, want := range hits {
+					got, err := db.Count(tt.hash(pw))
+					if err != nil || got != want {
+						t.Errorf("Count(%q) = %d, %v, want %d", pw, got, err, want)
+					}
+					// A hash in the same crowded bucket that isn't listed.
+					miss := tt.hash(pw)
+					miss[len(miss)-1] ^= 0xff
+					if got, err := db.Count(miss); err != nil || got != 0 {
+						t.Errorf("Count(%q with last byte flipped) = %d, %v, want 0", pw, got, err)
+					}
+				}
+				for _, pw := range []string{"not in the fixture", ""} {
+					if got, err := db.Count(tt.hash(pw)); err != nil || got != 0 {
+						t.Errorf("Count(%q) = %d, %v, want 0", pw, got, err)
+					}
+				}
+				if _, err := db.Count(make([]byte, 3)); err == nil {
+					t.Error("Count accepted a hash of the wrong length")
+				}
+			}
+
+			db, err := OpenBreachDB(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if db.Indexed() {
+				t.Error("Indexed() = true before BuildBreachIndex")
+			}
+			check(db)
+			db.Close()
+
+			if err := BuildBreachIndex(path); err != nil {
+				t.Fatal(err)
+			}
+			db, err = OpenBreachDB(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !db.Indexed() {
+				t.Error("Indexed() = false after BuildBreachIndex")
+			}
+			check(db)
+			db.Close()
+
+			// An index for an older copy of the file is ignored.
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatal(err)
+			}
+			db, err = OpenBreachDB(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if db.Indexed() {
+				t.Error("Indexed() = true with a stale index")
+			}
+			check(db)
+		})
+	}
+}
+
+func TestBreachDBReadError(t *testing.T) {
+	sum := sha1.Sum([]byte("password"))
+	path := writeBreachFixture(t, sha1HexLen, map[string]int{strings.ToUpper(hex.EncodeToString(sum[:])): 1})
+	db, err := OpenBreachDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, _, err := db.lineAfter(db.size / 2); err == nil {
+		t.Error("lineAfter on a closed file returned no error")
+	}
+	if got, err := db.Count(sum[:]); err == nil {
+		t.Errorf("Count on a closed file = %d, nil, want an error", got)
+	}
+}
+
+func TestOpenBreachDBRejectsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty":    "",
+		"no count": strings.Repeat("A", sha1HexLen) + "\n",
+		"bad hash": "ABCDEF:12\n",
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-"))
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if db, err := OpenBreachDB(path); err == nil {
+			db.Close()
+			t.Errorf("OpenBreachDB accepted a file with %s", name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"embed"
+	"strings"
+)
+
+// The ranked dictionaries, most common entries first: leaked passwords,
+// English words, first names and surnames.
+//
+//go:embed dict/*.txt
+var dictFiles embed.FS
+
+var dictNames = []string{"passwords", "english", "names", "surnames"}
+
+// rankedWords maps a lowercase word to its lowest rank in any dictionary,
+// starting at 1. A word's rank is the number of guesses an attacker trying
+// that dictionary in order needs to reach it.
+var rankedWords = map[string]int{}
+
+// maxWordLen is the length of the longest dictionary entry in runes.
+var maxWordLen int
+
+func init() {
+	for _, name := range dictNames {
+		data, err := dictFiles.ReadFile("dict/" + name + ".txt")
+		if err != nil {
+			panic(err)
+		}
+		rank := 0
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			for _, word := range strings.Fields(line) {
+				rank++
+				if r, ok := rankedWords[word]; !ok || rank < r {
+					rankedWords[word] = rank
+				}
+				maxWordLen = max(maxWordLen, len([]rune(word)))
+			}
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package audit
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// match is a run of the password, runes[i:j+1], and the guesses an attacker
+// trying that kind of pattern needs to find it.
+type match struct {
+	i, j    int
+	guesses float64
+}
+
+// omnimatch returns all matches in the password.
+func omnimatch(runes []rune) []match {
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length; match case-sensitively.
+		lower = runes
+	}
+	var matches []match
+	matches = append(matches, dictionaryMatches(runes, lower)...)
+	matches = append(matches, reverseDictionaryMatches(runes, lower)...)
+	matches = append(matches, l33tMatches(runes, lower)...)
+	matches = append(matches, spatialMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+	matches = append(matches, dateMatches(runes)...)
+	return matches
+}
+
+// dictionaryMatches finds the dictionary words of two or more letters in
+// lower, which is the lowercased runes.
+func dictionaryMatches(runes, lower []rune) []match {
+	var matches []match
+	for i := range lower {
+		for j := i + 1; j < len(lower) && j-i < maxWordLen; j++ {
+			rank, ok := rankedWords[string(lower[i:j+1])]
+			if !ok {
+				continue
+			}
+			g := float64(rank) * uppercaseVariations(runes[i:j+1])
+			matches = append(matches, match{i, j, g})
+		}
+	}
+	return matches
+}
+
+// reverseDictionaryMatches finds dictionary words spelled backwards, which
+// take twice the guesses.
+func reverseDictionaryMatches(runes, lower []rune) []match {
+	n := len(runes)
+	reversed := make([]rune, n)
+	reversedLower := make([]rune, n)
+	for i := range runes {
+		reversed[n-1-i] = runes[i]
+		reversedLower[n-1-i] = lower[i]
+	}
+	var matches []match
+	for _, m := range dictionaryMatches(reversed, reversedLower) {
+		matches = append(matches, match{n - 1 - m.j, n - 1 - m.i, 2 * m.guesses})
+	}
+	return matches
+}
+
+// l33tTable lists the letters each substitution may stand for.
+var l33tTable = map[rune][]rune{
+	'4': {'a'}, '@': {'a'}, '8': {'b'}, '(': {'c'}, '{': {'c'}, '[': {'c'},
+	'<': {'c'}, '3': {'e'}, '6': {'g'}, '9': {'g'}, '1': {'i', 'l'},
+	'!': {'i'}, '|': {'i', 'l'}, '7': {'l', 't'}, '0': {'o'}, '$': {'s'},
+	'5': {'s'}, '+': {'t'}, '%': {'x'}, '2': {'z'},
+}
+
+// maxL33tSubs caps the substitution tables tried for one password.
+const maxL33tSubs = 64
+
+// l33tMatches finds dictionary words with letters replaced by look-alike
+// digits and symbols, as in p@ssw0rd.
+func l33tMatches(runes, lower []rune) []match {
+	var present []rune
+	seen := map[rune]bool{}
+	for _, r := range lower {
+		if _, ok := l33tTable[r]; ok && !seen[r] {
+			seen[r] = true
+			present = append(present, r)
+		}
+	}
+	if len(present) == 0 {
+		return nil
+	}
+	// Every combination of meanings for the substitutions present.
+	subs := []map[rune]rune{{}}
+	for _, r := range present {
+		var next []map[rune]rune
+		for _, sub := range subs {
+			for _, letter := range l33tTable[r] {
+				s := make(map[rune]rune, len(sub)+1)
+				for k, v := range sub {
+					s[k] = v
+				}
+				s[r] = letter
+				next = append(next, s)
+			}
+		}
+		subs = next
+		if len(subs) > maxL33tSubs {
+			subs = subs[:maxL33tSubs]
+		}
+	}
+
+	var matches []match
+	for _, sub := range subs {
+		unsubbed := make([]rune, len(lower))
+		for i, r := range lower {
+			if letter, ok := sub[r]; ok {
+				unsubbed[i] = letter
+			} else {
+				unsubbed[i] = r
+			}
+		}
+		for _, m := range dictionaryMatches(runes, unsubbed) {
+			token := lower[m.i : m.j+1]
+			variations := l33tVariations(token, sub)
+			if variations == 1 {
+				continue // no substitution in this word
+			}
+			m.guesses *= variations
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// l33tVariations is how many ways the substitutions used in token could
+// have been applied to the word, or 1 if none were.
+func l33tVariations(token []rune, sub map[rune]rune) float64 {
+	variations := 1.0
+	for subbed, letter := range sub {
+		var s, u int
+		for _, r := range token {
+			switch r {
+			case subbed:
+				s++
+			case letter:
+				u++
+			}
+		}
+		switch {
+		case s == 0:
+		case u == 0:
+			variations *= 2
+		default:
+			possibilities := 0.0
+			for i := 1; i <= min(s, u); i++ {
+				possibilities += nCk(s+u, i)
+			}
+			variations *= possibilities
+		}
+	}
+	return variations
+}
+
+// A keyboard layout for spatial matching: keys with their position, the
+// number of keys and the average number of neighbours.
+type keyboard struct {
+	keys    map[rune]keyPos
+	starts  float64
+	degree  float64
+	slanted bool
+}
+
+type keyPos struct {
+	x, y    int
+	shifted bool
+}
+
+var (
+	qwerty = newKeyboard(true, []string{
+		"`1234567890-=", " qwertyuiop[]\\", " asdfghjkl;'", " zxcvbnm,./",
+	}, []string{
+		"~!@#$%^&*()_+", " QWERTYUIOP{}|", " ASDFGHJKL:\"", " ZXCVBNM<>?",
+	})
+	keypad = newKeyboard(false, []string{" /*-", "789+", "456", "123", " 0."}, nil)
+)
+
+func newKeyboard(slanted bool, rows, shiftedRows []string) keyboard {
+	kb := keyboard{keys: map[rune]keyPos{}, slanted: slanted}
+	add := func(rows []string, shifted bool) {
+		for y, row := range rows {
+			for x, r := range []rune(row) {
+				if r != ' ' {
+					kb.keys[r] = keyPos{x, y, shifted}
+				}
+			}
+		}
+	}
+	add(rows, false)
+	add(shiftedRows, true)
+	neighbours := 0
+	unshifted := 0
+	for _, p := range kb.keys {
+		if p.shifted {
+			continue
+		}
+		unshifted++
+		for _, other := range kb.keys {
+			if !other.shifted && kb.direction(p, other) >= 0 {
+				neighbours++
+			}
+		}
+	}
+	kb.starts = float64(unshifted)
+	kb.degree = float64(neighbours) / float64(unshifted)
+	return kb
+}
+
+// direction returns which neighbour of a b is, or -1 if it is none. On a
+// slanted keyboard each row is offset by half a key, so a key has six
+// neighbours: two in its row and two each in the rows above and below.
+func (kb keyboard) direction(a, b keyPos) int {
+	dx, dy := b.x-a.x, b.y-a.y
+	if kb.slanted {
+		switch {
+		case dy == 0 && dx == -1:
+			return 0
+		case dy == 0 && dx == 1:
+			return 1
+		case dy == -1 && dx == 0:
+			return 2
+		case dy == -1 && dx == 1:
+			return 3
+		case dy == 1 && dx == -1:
+			return 4
+		case dy == 1 && dx == 0:
+			return 5
+		}
+		return -1
+	}
+	if (dx == 0 && dy == 0) || dx < -1 || dx > 1 || dy < -1 || dy > 1 {
+		return -1
+	}
+	return (dy+1)*3 + dx + 1
+}
+
+// spatialMatches finds walks of three or more adjacent keys, as in qwerty
+// or 1qaz.
+func spatialMatches(runes []rune) []match {
+	var matches []match
+	for _, kb := range []keyboard{qwerty, keypad} {
+		for i := 0; i < len(runes)-2; {
+			p, ok := kb.keys[runes[i]]
+			if !ok {
+				i++
+				continue
+			}
+			j, turns, shifted := i, 0, 0
+			if p.shifted {
+				shifted++
+			}
+			lastDir := -1
+			for j+1 < len(runes) {
+				q, ok := kb.keys[runes[j+1]]
+				if !ok {
+					break
+				}
+				dir := kb.direction(p, q)
+				if dir < 0 {
+					break
+				}
+				if dir != lastDir {
+					turns++
+					lastDir = dir
+				}
+				if q.shifted {
+					shifted++
+				}
+				p = q
+				j++
+			}
+			if j-i >= 2 {
+				matches = append(matches, match{i, j, kb.guesses(j-i+1, turns, shifted)})
+				i = j
+			} else {
+				i++
+			}
+		}
+	}
+	return matches
+}
+
+func (kb keyboard) guesses(length, turns, shifted int) float64 {
+	g := 0.0
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= min(turns, i-1); j++ {
+			g += nCk(i-1, j-1) * kb.starts * math.Pow(kb.degree, float64(j))
+		}
+	}
+	if shifted > 0 {
+		unshifted := length - shifted
+		if unshifted == 0 {
+			g *= 2
+		} else {
+			variations := 0.0
+			for i := 1; i <= min(shifted, unshifted); i++ {
+				variations += nCk(shifted+unshifted, i)
+			}
+			g *= variations
+		}
+	}
+	return g
+}
+
+// repeatMatches finds runs of a repeated character or string, as in aaaa or
+// abcabc. Cracking rules repeat every candidate as a matter of course, so a
+// run costs no more guesses than the part repeated, however long it gets.
+func repeatMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i < len(runes)-1; {
+		bestEnd, bestBase := -1, 0
+		for base := 1; i+2*base <= len(runes); base++ {
+			end := i + base
+			for end+base <= len(runes) && string(runes[end:end+base]) == string(runes[i:i+base]) {
+				end += base
+			}
+			if end > i+base && end > bestEnd {
+				bestEnd, bestBase = end, base
+			}
+		}
+		if bestEnd < 0 {
+			i++
+			continue
+		}
+		g := guesses(string(runes[i : i+bestBase]))
+		matches = append(matches, match{i, bestEnd - 1, g})
+		i = bestEnd
+	}
+	return matches
+}
+
+// sequenceMatches finds runs of three or more characters with a constant
+// step of up to five, as in abcd, 13579 or zyx.
+func sequenceMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i < len(runes)-2; {
+		delta := runes[i+1] - runes[i]
+		j := i + 1
+		if delta != 0 && delta >= -5 && delta <= 5 {
+			for j+1 < len(runes) && runes[j+1]-runes[j] == delta {
+				j++
+			}
+		}
+		if j-i < 2 {
+			i++
+			continue
+		}
+		var base float64
+		first := runes[i]
+		switch {
+		case strings.ContainsRune("aAzZ019", first):
+			base = 4
+		case unicode.IsDigit(first):
+			base = 10
+		default:
+			base = 26
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		matches = append(matches, match{i, j, base * float64(j-i+1)})
+		i = j
+	}
+	return matches
+}
+
+// minYearSpace is the least number of years an attacker is assumed to try
+// around the current one.
+const minYearSpace = 20
+
+func yearSpace(year int) float64 {
+	return max(math.Abs(float64(year-time.Now().Year())), minYearSpace)
+}
+
+// yearMatches finds years from 1900 to 2099.
+func yearMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i+4 <= len(runes); i++ {
+		token := string(runes[i : i+4])
+		if !(strings.HasPrefix(token, "19") || strings.HasPrefix(token, "20")) {
+			continue
+		}
+		year, err := strconv.Atoi(token)
+		if err != nil {
+			continue
+		}
+		matches = append(matches, match{i, i + 3, yearSpace(year)})
+	}
+	return matches
+}
+
+// dateSplits are the ways a run of 4 to 8 digits without separators splits
+// into day, month and year, by the indices the second and third part start.
+var dateSplits = map[int][][2]int{
+	4: {{1, 2}, {2, 3}},
+	5: {{1, 3}, {2, 3}},
+	6: {{1, 2}, {2, 4}, {4, 5}},
+	7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+	8: {{2, 4}, {4, 6}},
+}
+
+const dateSeparators = " /\\_.-"
+
+// dateMatches finds dates such as 25121990, 1990-12-25 or 3/4/97. A date
+// costs a guess per day in the years around it, four times as many with
+// separators.
+func dateMatches(runes []rune) []match {
+	var matches []match
+	for i := range runes {
+		for j := i + 3; j < len(runes) && j-i < 10; j++ {
+			token := string(runes[i : j+1])
+			var candidates [][3]string
+			if sep := strings.IndexAny(token, dateSeparators); sep >= 0 {
+				parts := strings.Split(token, string(token[sep]))
+				if len(parts) == 3 && strings.IndexAny(parts[1]+parts[2], dateSeparators) < 0 {
+					candidates = append(candidates, [3]string{parts[0], parts[1], parts[2]})
+				}
+			} else {
+				for _, split := range dateSplits[len(token)] {
+					candidates = append(candidates, [3]string{token[:split[0]], token[split[0]:split[1]], token[split[1]:]})
+				}
+			}
+			year, ok := 0, false
+			for _, c := range candidates {
+				if y, valid := dateYear(c); valid && (!ok || yearSpace(y) < yearSpace(year)) {
+					year, ok = y, true
+				}
+			}
+			if !ok {
+				continue
+			}
+			g := yearSpace(year) * 365
+			if strings.IndexAny(token, dateSeparators) >= 0 {
+				g *= 4
+			}
+			matches = append(matches, match{i, j, g})
+		}
+	}
+	return matches
+}
+
+// dateYear returns the year of parts read as day, month and year in any
+// order with the year first or last; two-digit years are 19xx or 20xx.
+func dateYear(parts [3]string) (int, bool) {
+	var nums [3]int
+	for k, p := range parts {
+		if p == "" || len(p) > 4 {
+			return 0, false
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		nums[k] = n
+	}
+	for _, order := range [][3]int{{2, 0, 1}, {0, 1, 2}} { // year last, year first
+		year, a, b := nums[order[0]], nums[order[1]], nums[order[2]]
+		if len(parts[order[1]]) > 2 || len(parts[order[2]]) > 2 {
+			continue
+		}
+		if !((a >= 1 && a <= 31 && b >= 1 && b <= 12) || (b >= 1 && b <= 31 && a >= 1 && a <= 12)) {
+			continue
+		}
+		switch len(parts[order[0]]) {
+		case 4:
+			if year >= 1000 && year <= 2050 {
+				return year, true
+			}
+		case 2:
+			if year > 50 {
+				return 1900 + year, true
+			}
+			return 2000 + year, true
+		}
+	}
+	return 0, false
+}
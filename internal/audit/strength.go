@@ -0,0 +1,194 @@
+package audit
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Score is a zxcvbn-style strength score from 0 (trivially guessable) to 4
+// (very unguessable).
+type Score int
+
+const (
+	VeryWeak Score = iota
+	Weak
+	Fair
+	Strong
+	VeryStrong
+)
+
+func (s Score) String() string {
+	switch s {
+	case VeryWeak:
+		return "very weak"
+	case Weak:
+		return "weak"
+	case Fair:
+		return "fair"
+	case Strong:
+		return "strong"
+	}
+	return "very strong"
+}
+
+// Strength estimates how hard a password is to guess, following zxcvbn:
+// the password is split into the sequence of dictionary words, keyboard
+// walks, repeats, sequences, years, dates and brute-forced runs that an
+// attacker would need the fewest guesses for, and the guesses are mapped to
+// zxcvbn's score thresholds.
+func Strength(password string) Score {
+	g := guesses(password)
+	switch {
+	case g < 1e3+5:
+		return VeryWeak
+	case g < 1e6+5:
+		return Weak
+	case g < 1e8+5:
+		return Fair
+	case g < 1e10+5:
+		return Strong
+	}
+	return VeryStrong
+}
+
+const (
+	// bruteforceCardinality is the guesses per brute-forced character.
+	bruteforceCardinality = 10
+	// Matches shorter than the password need at least this many guesses, so
+	// that a single character or pair is not a cheaper "match" than itself.
+	minSubmatchGuessesSingleChar = 10
+	minSubmatchGuessesMultiChar  = 50
+	// minGuessesBeforeGrowingSequence penalizes every additional match in
+	// the sequence, so splitting a password in many pieces does not pay.
+	minGuessesBeforeGrowingSequence = 10000
+)
+
+// guesses returns the fewest guesses needed for password over all ways to
+// split it into matches and brute-forced runs.
+func guesses(password string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 1
+	}
+	byEnd := make([][]match, n)
+	for _, m := range omnimatch(runes) {
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// best[k][l] is the best way to cover runes[:k+1] with l matches.
+	type step struct {
+		product float64 // product of the match guesses
+		total   float64
+		bf      bool // last match is brute force
+	}
+	best := make([]map[int]step, n)
+	for k := range best {
+		best[k] = map[int]step{}
+	}
+	update := func(k, l int, product float64, bf bool) {
+		total := factorial(l)*product + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		for other, s := range best[k] {
+			if other <= l && s.total <= total {
+				return
+			}
+		}
+		best[k][l] = step{product: product, total: total, bf: bf}
+	}
+	floor := func(i, j int, g float64) float64 {
+		if j-i+1 == n {
+			return max(g, 1)
+		}
+		if i == j {
+			return max(g, minSubmatchGuessesSingleChar)
+		}
+		return max(g, minSubmatchGuessesMultiChar)
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range byEnd[k] {
+			g := floor(m.i, m.j, m.guesses)
+			if m.i == 0 {
+				update(k, 1, g, false)
+				continue
+			}
+			for l, s := range best[m.i-1] {
+				update(k, l+1, s.product*g, false)
+			}
+		}
+		// Brute force runs[i:k+1], never right after another brute-forced
+		// run: a single longer run is always cheaper.
+		update(k, 1, floor(0, k, bruteforceGuesses(k+1)), true)
+		for i := 1; i <= k; i++ {
+			g := floor(i, k, bruteforceGuesses(k-i+1))
+			for l, s := range best[i-1] {
+				if !s.bf {
+					update(k, l+1, s.product*g, true)
+				}
+			}
+		}
+	}
+
+	result := math.Inf(1)
+	for _, s := range best[n-1] {
+		result = min(result, s.total)
+	}
+	return result
+}
+
+func bruteforceGuesses(length int) float64 {
+	g := math.Pow(bruteforceCardinality, float64(length))
+	if length == 1 {
+		return max(g, minSubmatchGuessesSingleChar+1)
+	}
+	return max(g, minSubmatchGuessesMultiChar+1)
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+func nCk(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n - k + d)
+		r /= float64(d)
+	}
+	return r
+}
+
+// uppercaseVariations is how many ways the letters of a word could have
+// been capitalized: all lowercase, first letter only, last letter only and
+// all caps are tried first.
+func uppercaseVariations(token []rune) float64 {
+	var upper, lower int
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	first, _ := utf8.DecodeRuneInString(string(token))
+	last := token[len(token)-1]
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(first) || unicode.IsUpper(last))) {
+		return 2
+	}
+	variations := 0.0
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += nCk(upper+lower, i)
+	}
+	return variations
+}
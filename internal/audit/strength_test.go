@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrengthWeak(t *testing.T) {
+	for _, pw := range []string{
+		"",
+		"password",
+		"P@ssw0rd!",
+		"Sunshine2024",
+		"iloveyou2",
+		"Football!",
+		"michael1985",
+		"monkeydragon",
+		"qwertyuiop",
+		"1qaz2wsx3edc",
+		"zxcvbnm123",
+		"aaaaaaaaaa",
+		"abcabcabcabc",
+		"abcdefgh",
+		"25/12/1990",
+	} {
+		if got := Strength(pw); got > Weak {
+			t.Errorf("Strength(%q) = %v, want weak or worse", pw, got)
+		}
+	}
+}
+
+func TestStrengthDictionaryWords(t *testing.T) {
+	// Two common words are no match for an attacker with a dictionary,
+	// however long the result.
+	for _, pw := range []string{"Elephantgrass", "grasselephant", "El3phantGrass"} {
+		if got := Strength(pw); got > Fair {
+			t.Errorf("Strength(%q) = %v, want fair or worse", pw, got)
+		}
+	}
+}
+
+func TestStrengthKnownPasswords(t *testing.T) {
+	// Tr0ub4dor&3 is a dictionary word with textbook substitutions and a
+	// digit suffix; zxcvbn puts it at a score of 2.
+	for _, pw := range []string{"Tr0ub4dor&3", "troubador", "Troubadour1!"} {
+		if got := Strength(pw); got > Fair {
+			t.Errorf("Strength(%q) = %v, want fair or worse", pw, got)
+		}
+	}
+}
+
+func TestStrengthRepeats(t *testing.T) {
+	// Repeating a pattern costs an attacker no more than the pattern itself,
+	// no matter how many times it is repeated.
+	for _, n := range []int{2, 8, 128} {
+		for _, pw := range []string{"password", "abcdefgh", "qwertyui", "passw0rd"} {
+			if got := Strength(strings.Repeat(pw, n)); got > Weak {
+				t.Errorf("Strength(%q × %d) = %v, want weak or worse", pw, n, got)
+			}
+		}
+		pw := "x7#Kq9!m"
+		if got := Strength(strings.Repeat(pw, n)); got > Fair {
+			t.Errorf("Strength(%q × %d) = %v, want fair or worse", pw, n, got)
+		}
+	}
+}
+
+func TestStrengthStrong(t *testing.T) {
+	for _, pw := range []string{
+		"x7#Kq9!mZ2vL",
+		"tPz8%wq3Lr!b",
+		"kfjdhsyeownb",
+		"correct horse battery staple",
+		"CorrectHorseBatteryStaple",
+		"Gl4cier-Quokka-Tundra-88",
+	} {
+		if got := Strength(pw); got != VeryStrong {
+			t.Errorf("Strength(%q) = %v, want very strong", pw, got)
+		}
+	}
+}
+
+func TestL33tVariations(t *testing.T) {
+	sub := map[rune]rune{'@': 'a', '0': 'o'}
+	tests := []struct {
+		token string
+		want  float64
+	}{
+		{"password", 1},
+		{"p@ssword", 2},
+		{"p@ssw0rd", 4},
+		{"@a", 2}, // either a could be the substituted one
+	}
+	for _, tt := range tests {
+		if got := l33tVariations([]rune(tt.token), sub); got != tt.want {
+			t.Errorf("l33tVariations(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestUppercaseVariations(t *testing.T) {
+	tests := []struct {
+		token string
+		want  float64
+	}{
+		{"password", 1},
+		{"Password", 2},
+		{"passworD", 2},
+		{"PASSWORD", 2},
+		{"PaSsword", 36}, // C(8,1) + C(8,2)
+	}
+	for _, tt := range tests {
+		if got := uppercaseVariations([]rune(tt.token)); got != tt.want {
+			t.Errorf("uppercaseVariations(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
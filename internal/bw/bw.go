@@ -2,17 +2,22 @@ package bw
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/netbrain/mnu/internal/debugflag"
 	"github.com/netbrain/mnu/internal/keychain"
+	"github.com/netbrain/mnu/internal/serve"
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
 )
@@ -22,11 +27,50 @@ type Manager interface {
 	IsInstalled() bool
 	IsLoggedIn() (bool, error)
 	GetItems() ([]map[string]interface{}, error)
+	GetItem(id string) (map[string]interface{}, error)
+	GetFolders() ([]map[string]interface{}, error)
+	GetAttachment(itemID, attachmentID string) ([]byte, error)
+	CreateItem(item map[string]interface{}) (string, error)
+	EditItem(id string, item map[string]interface{}) error
+	DeleteItem(id string) error
+	CreateFolder(name string) (string, error)
 	GetPassword(id string) (string, error)
 	GetTotp(id string) (string, error)
 	Unlock(password string) (string, error)
+	Lock() error
 }
 
+// SessionUnlocker is implemented by managers that can resume a vault session
+// from a previously issued session key instead of the master password. Only
+// the bw CLI works this way; a running bw serve keeps its own unlock state.
+type SessionUnlocker interface {
+	UnlockWithSession(sessionKey string) error
+}
+
+// StatusChecker is implemented by managers whose IsLoggedIn may answer from
+// a stored session key without asking Bitwarden. VaultUnlocked asks.
+type StatusChecker interface {
+	VaultUnlocked() (bool, error)
+}
+
+// VaultUnlocked reports whether the vault is unlocked right now, for
+// long-running commands that must notice when it locks. IsLoggedIn alone
+// trusts a session key that `bw lock` may have invalidated since.
+func VaultUnlocked(mgr Manager) (bool, error) {
+	loggedIn, err := mgr.IsLoggedIn()
+	if err != nil || !loggedIn {
+		return false, err
+	}
+	if sc, ok := mgr.(StatusChecker); ok {
+		return sc.VaultUnlocked()
+	}
+	return true, nil
+}
+
+// ErrSessionInvalid is returned by UnlockWithSession when bw no longer
+// accepts the session key, e.g. after `bw lock`.
+var ErrSessionInvalid = fmt.Errorf("session key is no longer valid")
+
 // Process (bw CLI) implementation
 
 type ProcessManager struct{}
@@ -43,6 +87,9 @@ func (b *ProcessManager) IsLoggedIn() (bool, error) {
 		log.Println("Checking for session key in keychain...")
 	}
 	sessionKey, err := keychain.GetSessionKey()
+	if errors.Is(err, keychain.ErrSessionExpired) {
+		return false, expire(b)
+	}
 	if err == nil && sessionKey != "" {
 		if debugflag.Enabled {
 			log.Println("Found session key.")
@@ -65,6 +112,12 @@ func (b *ProcessManager) IsLoggedIn() (bool, error) {
 	if debugflag.Enabled {
 		log.Println("BW_SESSION environment variable not set. Checking bw status...")
 	}
+	return b.VaultUnlocked()
+}
+
+// VaultUnlocked asks `bw status` whether the session in BW_SESSION, if
+// any, still unlocks the vault, forgetting the stored session key if not.
+func (b *ProcessManager) VaultUnlocked() (bool, error) {
 	cmd := exec.Command("bw", "status")
 	out, err := cmd.Output()
 	if err != nil {
@@ -89,7 +142,15 @@ func (b *ProcessManager) IsLoggedIn() (bool, error) {
 }
 
 func (b *ProcessManager) GetItems() ([]map[string]interface{}, error) {
-	cmd := exec.Command("bw", "list", "items")
+	return b.list("items")
+}
+
+func (b *ProcessManager) GetFolders() ([]map[string]interface{}, error) {
+	return b.list("folders")
+}
+
+func (b *ProcessManager) list(object string) ([]map[string]interface{}, error) {
+	cmd := exec.Command("bw", "list", object)
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -97,11 +158,70 @@ func (b *ProcessManager) GetItems() ([]map[string]interface{}, error) {
 	if len(out) == 0 || !json.Valid(out) {
 		return []map[string]interface{}{}, nil
 	}
-	var items []map[string]interface{}
-	if err := json.Unmarshal(out, &items); err != nil {
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(out, &objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (b *ProcessManager) GetItem(id string) (map[string]interface{}, error) {
+	out, err := exec.Command("bw", "get", "item", id).Output()
+	if err != nil {
+		return nil, err
+	}
+	var item map[string]interface{}
+	if err := json.Unmarshal(out, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (b *ProcessManager) GetAttachment(itemID, attachmentID string) ([]byte, error) {
+	return exec.Command("bw", "get", "attachment", attachmentID, "--itemid", itemID, "--raw").Output()
+}
+
+// CreateItem creates an item and returns its ID. The item is passed to bw on
+// stdin, never on the command line.
+func (b *ProcessManager) CreateItem(item map[string]interface{}) (string, error) {
+	return b.create(item, "item")
+}
+
+func (b *ProcessManager) EditItem(id string, item map[string]interface{}) error {
+	_, err := b.write(item, "edit", "item", id)
+	return err
+}
+
+// DeleteItem moves an item to the trash.
+func (b *ProcessManager) DeleteItem(id string) error {
+	return exec.Command("bw", "delete", "item", id).Run()
+}
+
+// CreateFolder creates a folder and returns its ID.
+func (b *ProcessManager) CreateFolder(name string) (string, error) {
+	return b.create(map[string]interface{}{"name": name}, "folder")
+}
+
+func (b *ProcessManager) create(object map[string]interface{}, kind string) (string, error) {
+	out, err := b.write(object, "create", kind)
+	if err != nil {
+		return "", err
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(out, &created); err != nil {
+		return "", err
+	}
+	return str(created["id"]), nil
+}
+
+func (b *ProcessManager) write(object map[string]interface{}, args ...string) ([]byte, error) {
+	body, err := json.Marshal(object)
+	if err != nil {
 		return nil, err
 	}
-	return items, nil
+	cmd := exec.Command("bw", args...)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(body))
+	return cmd.Output()
 }
 
 func (b *ProcessManager) GetPassword(id string) (string, error) {
@@ -132,20 +252,83 @@ func (b *ProcessManager) Unlock(password string) (string, error) {
 	return sessionKey, nil
 }
 
+// Lock locks the bw CLI, invalidating every session key it issued.
+func (b *ProcessManager) Lock() error {
+	if err := exec.Command("bw", "lock").Run(); err != nil {
+		return err
+	}
+	os.Unsetenv("BW_SESSION")
+	return nil
+}
+
+// UnlockWithSession checks the session key against bw and stores it like
+// Unlock does.
+func (b *ProcessManager) UnlockWithSession(sessionKey string) error {
+	cmd := exec.Command("bw", "status")
+	cmd.Env = append(os.Environ(), "BW_SESSION="+sessionKey)
+	out, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(out, &status); err != nil {
+		return err
+	}
+	if status.Status != "unlocked" {
+		return ErrSessionInvalid
+	}
+	os.Setenv("BW_SESSION", sessionKey)
+	return keychain.SetSessionKey(sessionKey)
+}
+
+// expire locks the vault and deletes the stored session key after it
+// outlived session_ttl. It returns keychain.ErrSessionExpired, or the lock
+// failure, for IsLoggedIn to pass on.
+func expire(m Manager) error {
+	if err := m.Lock(); err != nil {
+		return fmt.Errorf("failed to lock expired session: %w", err)
+	}
+	keychain.DeleteSessionKey()
+	return keychain.ErrSessionExpired
+}
+
 // API implementation
 
-type APIManager struct{ apiUrl string }
+// apiBase is the base URL of API requests; the client dials the proxy
+// socket whatever the host.
+const apiBase = "http://bw"
 
-func NewAPIManager(apiUrl string) Manager { return &APIManager{apiUrl: apiUrl} }
+type APIManager struct {
+	client *http.Client
+	token  string
+}
+
+// NewAPIManager talks to bw serve through the mnu proxy listening on socket,
+// authenticating with token.
+func NewAPIManager(socket, token string) Manager {
+	return &APIManager{client: serve.UnixClient(socket), token: token}
+}
+
+func (b *APIManager) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.client.Do(req)
+}
 
 func (b *APIManager) IsInstalled() bool { return true }
 
 func (b *APIManager) IsLoggedIn() (bool, error) {
-	req, err := http.NewRequest("GET", b.apiUrl+"/status", nil)
+	// bw serve keeps its own unlock state, so the stored key only matters
+	// for when it was issued.
+	if _, err := keychain.GetSessionKey(); errors.Is(err, keychain.ErrSessionExpired) {
+		return false, expire(b)
+	}
+	req, err := http.NewRequest("GET", apiBase+"/status", nil)
 	if err != nil {
 		return false, err
 	}
-	resp, err := (&http.Client{}).Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return false, err
 	}
@@ -168,11 +351,19 @@ func (b *APIManager) IsLoggedIn() (bool, error) {
 }
 
 func (b *APIManager) GetItems() ([]map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", b.apiUrl+"/list/object/items", nil)
+	return b.list("items")
+}
+
+func (b *APIManager) GetFolders() ([]map[string]interface{}, error) {
+	return b.list("folders")
+}
+
+func (b *APIManager) list(object string) ([]map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", apiBase+"/list/object/"+object, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := (&http.Client{}).Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -183,10 +374,10 @@ func (b *APIManager) GetItems() ([]map[string]interface{}, error) {
 	}
 	resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 	if debugflag.Enabled {
-		log.Printf("GetItems response status: %s", resp.Status)
+		log.Printf("list %s response status: %s", object, resp.Status)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get items failed: %s", resp.Status)
+		return nil, fmt.Errorf("get %s failed: %s", object, resp.Status)
 	}
 	var response struct {
 		Success bool `json:"success"`
@@ -199,17 +390,17 @@ func (b *APIManager) GetItems() ([]map[string]interface{}, error) {
 		return nil, err
 	}
 	if !response.Success {
-		return nil, fmt.Errorf("get items failed: %s", response.Data.Object)
+		return nil, fmt.Errorf("get %s failed: %s", object, response.Data.Object)
 	}
 	return response.Data.Data, nil
 }
 
 func (b *APIManager) getItem(id string) (map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", b.apiUrl+"/object/item/"+id, nil)
+	req, err := http.NewRequest("GET", apiBase+"/object/item/"+id, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := (&http.Client{}).Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -241,6 +432,93 @@ func (b *APIManager) getItem(id string) (map[string]interface{}, error) {
 	return item, nil
 }
 
+func (b *APIManager) GetItem(id string) (map[string]interface{}, error) {
+	item, err := b.getItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := item["data"].(map[string]interface{}); ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("item not found")
+}
+
+func (b *APIManager) GetAttachment(itemID, attachmentID string) ([]byte, error) {
+	req, err := http.NewRequest("GET", apiBase+"/object/attachment/"+url.PathEscape(attachmentID)+"?itemid="+url.QueryEscape(itemID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get attachment failed: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (b *APIManager) CreateItem(item map[string]interface{}) (string, error) {
+	created, err := b.write("POST", "/object/item", item)
+	if err != nil {
+		return "", err
+	}
+	return str(created["id"]), nil
+}
+
+func (b *APIManager) EditItem(id string, item map[string]interface{}) error {
+	_, err := b.write("PUT", "/object/item/"+url.PathEscape(id), item)
+	return err
+}
+
+// DeleteItem moves an item to the trash.
+func (b *APIManager) DeleteItem(id string) error {
+	_, err := b.write("DELETE", "/object/item/"+url.PathEscape(id), nil)
+	return err
+}
+
+// CreateFolder creates a folder and returns its ID.
+func (b *APIManager) CreateFolder(name string) (string, error) {
+	created, err := b.write("POST", "/object/folder", map[string]interface{}{"name": name})
+	if err != nil {
+		return "", err
+	}
+	return str(created["id"]), nil
+}
+
+func (b *APIManager) write(method, path string, object map[string]interface{}) (map[string]interface{}, error) {
+	var body []byte
+	if object != nil {
+		var err error
+		if body, err = json.Marshal(object); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, apiBase+path, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var response struct {
+		Success bool                   `json:"success"`
+		Message string                 `json:"message"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%s %s failed: %s", method, path, response.Message)
+	}
+	return response.Data, nil
+}
+
 func (b *APIManager) GetPassword(id string) (string, error) {
 	if debugflag.Enabled {
 		log.Printf("Calling getItem for ID: %s", id)
@@ -296,12 +574,12 @@ func (b *APIManager) Unlock(password string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	req, err := http.NewRequest("POST", b.apiUrl+"/unlock", bytes.NewBuffer(body))
+	req, err := http.NewRequest("POST", apiBase+"/unlock", bytes.NewBuffer(body))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := (&http.Client{}).Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -325,3 +603,75 @@ func (b *APIManager) Unlock(password string) (string, error) {
 	}
 	return sessionKey, nil
 }
+
+// Lock locks the vault held by bw serve.
+func (b *APIManager) Lock() error {
+	_, err := b.write("POST", "/lock", nil)
+	return err
+}
+
+// Daemon implementation
+
+// ItemLister is implemented by managers that can list items without
+// fetching their secrets, which is much faster than GetItems.
+type ItemLister interface {
+	ListItems(query string) ([]map[string]interface{}, error)
+}
+
+// ListItems returns the raw items whose name or username matches query, or
+// all items for an empty query. Secrets may be redacted, so only use the
+// result for display and lookup, and fetch secrets with GetField.
+func ListItems(mgr Manager, query string) ([]map[string]interface{}, error) {
+	if l, ok := mgr.(ItemLister); ok {
+		return l.ListItems(query)
+	}
+	raw, err := mgr.GetItems()
+	if err != nil || query == "" {
+		return raw, err
+	}
+	var matches []map[string]interface{}
+	for _, r := range raw {
+		if ItemFromMap(r).Matches(query) {
+			matches = append(matches, r)
+		}
+	}
+	return matches, nil
+}
+
+// DaemonManager talks to a running mnu-bw serve. Items are listed and
+// fetched through the daemon, which keeps their metadata in memory;
+// everything else goes to bw serve through the daemon's proxy.
+type DaemonManager struct {
+	*APIManager
+}
+
+// NewDaemonManager talks to the mnu-bw serve advertising ep.
+func NewDaemonManager(ep serve.Endpoint) Manager {
+	return &DaemonManager{APIManager: &APIManager{client: serve.UnixClient(ep.Socket), token: ep.Token}}
+}
+
+// ListItems returns the daemon's items with their secrets redacted.
+func (b *DaemonManager) ListItems(query string) ([]map[string]interface{}, error) {
+	req := serve.Request{Command: serve.CmdList}
+	if query != "" {
+		req = serve.Request{Command: serve.CmdSearch, Query: query}
+	}
+	resp, err := serve.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// GetItem returns an item with its secrets, which the daemon fetches on
+// demand.
+func (b *DaemonManager) GetItem(id string) (map[string]interface{}, error) {
+	resp, err := serve.Do(serve.Request{Command: serve.CmdGet, ID: id})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Item == nil {
+		return nil, fmt.Errorf("item not found")
+	}
+	return resp.Item, nil
+}
@@ -0,0 +1,387 @@
+package bw
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/netbrain/mnu/internal/urimatch"
+)
+
+var (
+	ErrNotFound  = errors.New("no matching item")
+	ErrAmbiguous = errors.New("query matches more than one item")
+)
+
+// Item types as stored in the type field.
+const (
+	TypeLogin      = 1
+	TypeSecureNote = 2
+	TypeCard       = 3
+	TypeIdentity   = 4
+	TypeSSHKey     = 5
+)
+
+// Item is the non-secret metadata of a vault item.
+type Item struct {
+	ID          string
+	Type        int
+	Name        string
+	Username    string
+	URI         string
+	URIs        []LoginURI
+	FolderID    string
+	HasTotp     bool
+	HasPassword bool
+
+	// RevisionDate is when the item last changed; PasswordRevisionDate when
+	// its password last changed (zero if never).
+	RevisionDate         time.Time
+	PasswordRevisionDate time.Time
+}
+
+// LoginURI is one login URI together with its Bitwarden match strategy.
+type LoginURI struct {
+	URI   string
+	Match urimatch.Strategy
+}
+
+// MatchesURL reports whether any of the item's URIs match url.
+func (i Item) MatchesURL(url string) bool {
+	for _, u := range i.URIs {
+		if urimatch.Match(u.URI, u.Match, url) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the item matches a search query the same way the
+// TUI filters its list: a case-insensitive substring of name and username.
+func (i Item) Matches(query string) bool {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(i.Name+" "+i.Username), q)
+}
+
+// ItemFromMap extracts item metadata from a raw item as returned by either
+// the bw CLI or bw serve. Secrets are only checked for presence.
+func ItemFromMap(m map[string]interface{}) Item {
+	id := getStringDeep(m, "id")
+	if id == "" {
+		id = getStringDeep(m, "data", "id")
+	}
+	name := getStringDeep(m, "name")
+	if name == "" {
+		name = getStringDeep(m, "data", "name")
+	}
+	username := getStringDeep(m, "login", "username")
+	if username == "" {
+		username = getStringDeep(m, "data", "login", "username")
+	}
+	totp := getStringDeep(m, "login", "totp")
+	if totp == "" {
+		totp = getStringDeep(m, "data", "login", "totp")
+	}
+	pw := LoginPassword(m)
+	folderID := getStringDeep(m, "folderId")
+	if folderID == "" {
+		folderID = getStringDeep(m, "data", "folderId")
+	}
+	typ, _ := m["type"].(float64)
+	if typ == 0 {
+		typ, _ = getMap(m, "data")["type"].(float64)
+	}
+	return Item{
+		ID:          id,
+		Type:        int(typ),
+		Name:        name,
+		Username:    username,
+		URI:         firstURIFromItem(m),
+		URIs:        urisFromItem(m),
+		FolderID:    folderID,
+		HasTotp:     strings.TrimSpace(totp) != "",
+		HasPassword: strings.TrimSpace(pw) != "",
+
+		RevisionDate:         parseDate(getStringDeep(m, "revisionDate"), getStringDeep(m, "data", "revisionDate")),
+		PasswordRevisionDate: parseDate(getStringDeep(m, "login", "passwordRevisionDate"), getStringDeep(m, "data", "login", "passwordRevisionDate")),
+	}
+}
+
+// LoginPassword returns the login password contained in a raw item, if the
+// item was fetched with secrets.
+func LoginPassword(m map[string]interface{}) string {
+	pw := getStringDeep(m, "login", "password")
+	if pw == "" {
+		pw = getStringDeep(m, "data", "login", "password")
+	}
+	return pw
+}
+
+// parseDate parses the first non-empty RFC 3339 timestamp.
+func parseDate(values ...string) time.Time {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ItemsFromMaps converts a raw item list into item metadata.
+func ItemsFromMaps(raw []map[string]interface{}) []Item {
+	items := make([]Item, 0, len(raw))
+	for _, r := range raw {
+		items = append(items, ItemFromMap(r))
+	}
+	return items
+}
+
+// FilterURL returns the items with a URI matching url, preserving order.
+func FilterURL(items []Item, url string) []Item {
+	out := make([]Item, 0, len(items))
+	for _, it := range items {
+		if it.MatchesURL(url) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// Filter returns the items matching query, preserving order.
+func Filter(items []Item, query string) []Item {
+	out := make([]Item, 0, len(items))
+	for _, it := range items {
+		if it.Matches(query) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// Resolve picks exactly one item for query. An exact ID wins, then a single
+// search match, then a single case-insensitive exact name among the matches.
+func Resolve(items []Item, query string) (Item, error) {
+	q := strings.TrimSpace(query)
+	for _, it := range items {
+		if it.ID == q {
+			return it, nil
+		}
+	}
+	matches := Filter(items, q)
+	switch len(matches) {
+	case 0:
+		return Item{}, ErrNotFound
+	case 1:
+		return matches[0], nil
+	}
+	var exact []Item
+	for _, it := range matches {
+		if strings.EqualFold(it.Name, q) {
+			exact = append(exact, it)
+		}
+	}
+	if len(exact) == 1 {
+		return exact[0], nil
+	}
+	return Item{}, ErrAmbiguous
+}
+
+// GetField returns a single field of an item. Supported fields are password,
+// username, totp, uri, notes and field:<name> for custom fields.
+func GetField(mgr Manager, item Item, field string) (string, error) {
+	switch field {
+	case "password":
+		if !item.HasPassword {
+			return "", fmt.Errorf("no password for this item")
+		}
+		return mgr.GetPassword(item.ID)
+	case "totp":
+		if !item.HasTotp {
+			return "", fmt.Errorf("no totp for this item")
+		}
+		return mgr.GetTotp(item.ID)
+	case "username":
+		if item.Username == "" {
+			return "", fmt.Errorf("no username for this item")
+		}
+		return item.Username, nil
+	case "uri":
+		if item.URI == "" {
+			return "", fmt.Errorf("no URI for this item")
+		}
+		return item.URI, nil
+	case "notes":
+		raw, err := mgr.GetItem(item.ID)
+		if err != nil {
+			return "", err
+		}
+		notes := getStringDeep(raw, "notes")
+		if notes == "" {
+			return "", fmt.Errorf("no notes for this item")
+		}
+		return notes, nil
+	}
+	if name, ok := strings.CutPrefix(field, "field:"); ok && name != "" {
+		raw, err := mgr.GetItem(item.ID)
+		if err != nil {
+			return "", err
+		}
+		if fields, ok := raw["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				if fm, ok := f.(map[string]interface{}); ok && str(fm["name"]) == name {
+					return str(fm["value"]), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("custom field %q not found", name)
+	}
+	return "", fmt.Errorf("unknown field: %s", field)
+}
+
+func str(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	switch t := v.(type) {
+	case string:
+		return t
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func getMap(m map[string]interface{}, keys ...string) map[string]interface{} {
+	cur := m
+	for _, k := range keys {
+		v, ok := cur[k]
+		if !ok {
+			return nil
+		}
+		mv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = mv
+	}
+	return cur
+}
+
+func getStringDeep(m map[string]interface{}, keys ...string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	// try top-level
+	if v, ok := m[keys[0]]; ok && len(keys) == 1 {
+		return str(v)
+	}
+	// try deep path
+	mm := getMap(m, keys[:len(keys)-1]...)
+	if mm == nil {
+		return ""
+	}
+	return str(mm[keys[len(keys)-1]])
+}
+
+// firstURIFromItem tries to find the first URL/URI from common Bitwarden item shapes.
+func firstURIFromItem(m map[string]interface{}) string {
+	// Try login.uris (array of objects with uri field)
+	if login, ok := m["login"].(map[string]interface{}); ok {
+		if s := firstURIFromLogin(login); s != "" {
+			return s
+		}
+	}
+	// Try data.login.uris
+	if data, ok := m["data"].(map[string]interface{}); ok {
+		if login, ok := data["login"].(map[string]interface{}); ok {
+			if s := firstURIFromLogin(login); s != "" {
+				return s
+			}
+		}
+	}
+	// Fall back to possible direct fields
+	if s := strings.TrimSpace(getStringDeep(m, "login", "uri")); s != "" {
+		return s
+	}
+	if s := strings.TrimSpace(getStringDeep(m, "data", "login", "uri")); s != "" {
+		return s
+	}
+	return ""
+}
+
+// urisFromItem returns every URI stored on a login item.
+func urisFromItem(m map[string]interface{}) []LoginURI {
+	login := getMap(m, "login")
+	if login == nil {
+		login = getMap(m, "data", "login")
+	}
+	if login == nil {
+		return nil
+	}
+	var uris []LoginURI
+	if vv, ok := login["uris"].([]interface{}); ok {
+		for _, it := range vv {
+			u := LoginURI{Match: urimatch.Domain}
+			if mp, ok := it.(map[string]interface{}); ok {
+				u.URI = strings.TrimSpace(str(mp["uri"]))
+				u.Match = urimatch.ParseStrategy(mp["match"])
+			} else if v, ok := it.(string); ok {
+				u.URI = strings.TrimSpace(v)
+			}
+			if u.URI != "" {
+				uris = append(uris, u)
+			}
+		}
+	}
+	return uris
+}
+
+// FolderNames maps folder IDs to names from a raw folder list.
+func FolderNames(raw []map[string]interface{}) map[string]string {
+	names := make(map[string]string, len(raw))
+	for _, f := range raw {
+		if id := getStringDeep(f, "id"); id != "" {
+			names[id] = getStringDeep(f, "name")
+		}
+	}
+	return names
+}
+
+func firstURIFromLogin(login map[string]interface{}) string {
+	if v, ok := login["uris"]; ok {
+		switch vv := v.(type) {
+		case []interface{}:
+			for _, it := range vv {
+				// item can be a map with uri key, or a string
+				if mp, ok := it.(map[string]interface{}); ok {
+					s := strings.TrimSpace(str(mp["uri"]))
+					if s != "" {
+						return s
+					}
+				} else if s, ok := it.(string); ok {
+					s = strings.TrimSpace(s)
+					if s != "" {
+						return s
+					}
+				}
+			}
+		case map[string]interface{}:
+			s := strings.TrimSpace(str(vv["uri"]))
+			if s != "" {
+				return s
+			}
+		case string:
+			s := strings.TrimSpace(vv)
+			if s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
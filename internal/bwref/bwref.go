@@ -0,0 +1,115 @@
+// Package bwref parses and resolves bw:// secret references.
+//
+// A reference names an item and one of its fields:
+//
+//	bw://<item>/<field>
+//
+// <item> is an item ID or a search query resolved like `mnu-bw get` (an exact
+// ID, a single match, or a single exact name). <field> is one of password,
+// username, totp, uri, notes, or field/<name> for a custom field. Both parts
+// are percent-decoded, so "bw://My%20Bank/field/PIN%20code" is valid.
+package bwref
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+)
+
+const Scheme = "bw://"
+
+// Ref is a parsed reference.
+type Ref struct {
+	Item string
+	// Field uses the bw.GetField syntax (field:<name> for custom fields).
+	Field string
+}
+
+// IsRef reports whether s looks like a reference.
+func IsRef(s string) bool { return strings.HasPrefix(s, Scheme) }
+
+// Parse parses a bw:// reference.
+func Parse(s string) (Ref, error) {
+	rest, ok := strings.CutPrefix(s, Scheme)
+	if !ok {
+		return Ref{}, fmt.Errorf("invalid reference %q: must start with %s", s, Scheme)
+	}
+	item, field, ok := strings.Cut(rest, "/")
+	if !ok || item == "" || field == "" {
+		return Ref{}, fmt.Errorf("invalid reference %q: expected %s<item>/<field>", s, Scheme)
+	}
+	item, err := url.PathUnescape(item)
+	if err != nil {
+		return Ref{}, fmt.Errorf("invalid reference %q: %w", s, err)
+	}
+	if name, ok := strings.CutPrefix(field, "field/"); ok {
+		if field, err = url.PathUnescape(name); err != nil {
+			return Ref{}, fmt.Errorf("invalid reference %q: %w", s, err)
+		}
+		field = "field/" + field
+	}
+	ref, err := New(item, field)
+	if err != nil {
+		return Ref{}, fmt.Errorf("invalid reference %q: %w", s, err)
+	}
+	return ref, nil
+}
+
+// New builds a reference from an item and a field name. Custom fields may be
+// written as field/<name> or field:<name>.
+func New(item, field string) (Ref, error) {
+	if item == "" {
+		return Ref{}, fmt.Errorf("empty item")
+	}
+	switch field {
+	case "password", "username", "totp", "uri", "notes":
+		return Ref{Item: item, Field: field}, nil
+	}
+	for _, prefix := range []string{"field/", "field:"} {
+		if name, ok := strings.CutPrefix(field, prefix); ok && name != "" {
+			return Ref{Item: item, Field: "field:" + name}, nil
+		}
+	}
+	return Ref{}, fmt.Errorf("unknown field %q", field)
+}
+
+func (r Ref) String() string {
+	field := r.Field
+	if name, ok := strings.CutPrefix(field, "field:"); ok {
+		field = "field/" + url.PathEscape(name)
+	}
+	return Scheme + url.PathEscape(r.Item) + "/" + field
+}
+
+// Resolver resolves references against a single snapshot of the item list.
+type Resolver struct {
+	mgr   bwpkg.Manager
+	items []bwpkg.Item
+}
+
+// NewResolver loads the item list once for any number of lookups.
+func NewResolver(mgr bwpkg.Manager) (*Resolver, error) {
+	raw, err := mgr.GetItems()
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{mgr: mgr, items: bwpkg.ItemsFromMaps(raw)}, nil
+}
+
+// Resolve returns the secret value a reference points to.
+func (r *Resolver) Resolve(ref Ref) (string, error) {
+	item, err := bwpkg.Resolve(r.items, ref.Item)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	value, err := bwpkg.GetField(r.mgr, item, ref.Field)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	if ref.Field == "password" || ref.Field == "totp" {
+		value = strings.TrimSpace(value)
+	}
+	return value, nil
+}
@@ -0,0 +1,103 @@
+package bwref
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Ref
+		// canonical is what String gives back, if not in itself.
+		canonical string
+	}{
+		{in: "bw://github/password", want: Ref{"github", "password"}},
+		{in: "bw://github/username", want: Ref{"github", "username"}},
+		{in: "bw://github/totp", want: Ref{"github", "totp"}},
+		{in: "bw://github/uri", want: Ref{"github", "uri"}},
+		{in: "bw://github/notes", want: Ref{"github", "notes"}},
+		{in: "bw://0f3c1a2e-7b1d-4c52-9a3e-2f1b8c0d9e11/password", want: Ref{"0f3c1a2e-7b1d-4c52-9a3e-2f1b8c0d9e11", "password"}},
+		{in: "bw://My%20Bank/field/PIN%20code", want: Ref{"My Bank", "field:PIN code"}},
+		{in: "bw://db/field/API", want: Ref{"db", "field:API"}},
+		{in: "bw://work%2Fvpn/password", want: Ref{"work/vpn", "password"}},
+		{in: "bw://db/field/a%2Fb", want: Ref{"db", "field:a/b"}},
+		{in: "bw://db/field/100%25", want: Ref{"db", "field:100%"}},
+		{in: "bw://caf%C3%A9/password", want: Ref{"café", "password"}, canonical: "bw://caf%C3%A9/password"},
+		{in: "bw://My Bank/password", want: Ref{"My Bank", "password"}, canonical: "bw://My%20Bank/password"},
+		{in: "bw://db/field:API", want: Ref{"db", "field:API"}, canonical: "bw://db/field/API"},
+	} {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		want := tt.canonical
+		if want == "" {
+			want = tt.in
+		}
+		if s := got.String(); s != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, s, want)
+		}
+		if again, err := Parse(got.String()); err != nil || again != got {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", got.String(), again, err, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"github/password",
+		"op://vault/github/password",
+		"BW://github/password",
+		"bw://",
+		"bw://github",
+		"bw://github/",
+		"bw:///password",
+		"bw://github/secret",
+		"bw://github/field",
+		"bw://github/field/",
+		"bw://git%zzhub/password",
+		"bw://db/field/bad%zz",
+		"bw://%/password",
+	} {
+		if ref, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", in, ref)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, tt := range []struct {
+		item, field string
+		want        Ref
+	}{
+		{"github", "password", Ref{"github", "password"}},
+		{"db", "field/API key", Ref{"db", "field:API key"}},
+		{"db", "field:API key", Ref{"db", "field:API key"}},
+	} {
+		got, err := New(tt.item, tt.field)
+		if err != nil || got != tt.want {
+			t.Errorf("New(%q, %q) = %+v, %v, want %+v", tt.item, tt.field, got, err, tt.want)
+		}
+	}
+	for _, tt := range [][2]string{{"", "password"}, {"github", ""}, {"github", "field:"}, {"github", "Password"}} {
+		if ref, err := New(tt[0], tt[1]); err == nil {
+			t.Errorf("New(%q, %q) = %+v, want an error", tt[0], tt[1], ref)
+		}
+	}
+}
+
+func TestIsRef(t *testing.T) {
+	for in, want := range map[string]bool{
+		"bw://github/password": true,
+		"bw://":                true,
+		"github":               false,
+		"https://bw.example":   false,
+	} {
+		if got := IsRef(in); got != want {
+			t.Errorf("IsRef(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/atotto/clipboard"
+)
+
+// Backend is a clipboard the clearer writes a secret to, reads back to
+// leave content the user copied since alone, and clears.
+type Backend interface {
+	Write(text []byte) error
+	// Read returns what is on the clipboard, or ErrUnreadable when
+	// Capabilities().Read is false.
+	Read() ([]byte, error)
+	Clear() error
+	Capabilities() Capabilities
+}
+
+// Capabilities describe what a backend supports beyond writing.
+type Capabilities struct {
+	// Read means the clipboard can be read back, so a clearer can tell
+	// whether the user copied something else since.
+	Read bool
+	// PasteOnce and Primary mean Options.PasteOnce and Options.Primary are
+	// honoured.
+	PasteOnce bool
+	Primary   bool
+	// Hints means clipboard managers are told that the content is secret.
+	Hints bool
+}
+
+// ErrUnreadable is returned by Backend.Read when the clipboard can only be
+// written, as with OSC 52.
+var ErrUnreadable = errors.New("clipboard cannot be read back")
+
+// Backend names for Options.Backend.
+const (
+	BackendAuto    = "auto"
+	BackendX11     = "x11"
+	BackendWayland = "wayland"
+	BackendOSC52   = "osc52"
+	BackendTmux    = "tmux"
+	BackendCommand = "command"
+)
+
+// Options select and adjust the backend.
+type Options struct {
+	// Backend is one of the Backend* names; empty means BackendAuto.
+	Backend string
+	// PasteOnce clears the clipboard after it was pasted once (Wayland).
+	PasteOnce bool
+	// Primary uses the primary selection instead of the clipboard (Wayland).
+	Primary bool
+	// Command configures BackendCommand.
+	Command Command
+}
+
+// New returns the backend named by opts.Backend, detecting one for the
+// current session by default.
+func New(opts Options) (Backend, error) {
+	name := opts.Backend
+	if name == "" || name == BackendAuto {
+		name = Detect()
+	}
+	switch name {
+	case BackendX11:
+		return x11Backend{}, nil
+	case BackendWayland:
+		return &waylandBackend{opts: opts}, nil
+	case BackendOSC52:
+		return osc52Backend{}, nil
+	case BackendTmux:
+		return tmuxBackend{}, nil
+	case BackendCommand:
+		if opts.Command.Write == "" {
+			return nil, errors.New("clipboard_command needs a write command")
+		}
+		return commandBackend{opts.Command}, nil
+	}
+	return nil, fmt.Errorf("unknown clipboard backend %q", name)
+}
+
+// Detect names the backend for the current session: Wayland or X11 when
+// their display is set, OSC 52 over SSH, the native clipboard on macOS and
+// Windows, the tmux buffer inside tmux and OSC 52 otherwise.
+func Detect() string {
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return BackendWayland
+	case os.Getenv("DISPLAY") != "":
+		return BackendX11
+	case os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "":
+		return BackendOSC52
+	case runtime.GOOS == "darwin" || runtime.GOOS == "windows":
+		return BackendX11
+	case os.Getenv("TMUX") != "":
+		return BackendTmux
+	}
+	return BackendOSC52
+}
+
+// x11Backend uses xclip or xsel on X11; on macOS and Windows the same
+// library uses the native clipboard.
+type x11Backend struct{}
+
+func (x11Backend) Write(text []byte) error { return clipboard.WriteAll(string(text)) }
+
+func (x11Backend) Read() ([]byte, error) {
+	text, err := clipboard.ReadAll()
+	return []byte(text), err
+}
+
+func (x11Backend) Clear() error { return clipboard.WriteAll("") }
+
+func (x11Backend) Capabilities() Capabilities { return Capabilities{Read: true} }
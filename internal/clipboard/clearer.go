@@ -0,0 +1,62 @@
+package clipboard
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// Outcome says how Hold ended.
+type Outcome int
+
+const (
+	// Cleared means the clipboard was cleared at the timeout.
+	Cleared Outcome = iota
+	// ClearedEarly means ClearNow arrived before the timeout.
+	ClearedEarly
+	// Cancelled means a newer copy took over; the clipboard is left as is.
+	Cancelled
+	// Changed means the user copied something else since, which is left
+	// alone.
+	Changed
+)
+
+// Hold writes content to b and clears it after timeout, or at once when
+// ClearNow arrives on signals; any other signal cancels. Content the user
+// copied since is left alone when b can read the clipboard back. content is
+// wiped once written.
+func Hold(b Backend, content []byte, timeout time.Duration, signals <-chan string) (Outcome, error) {
+	origHash := sha256.Sum256(content)
+	err := b.Write(content)
+	for i := range content {
+		content[i] = 0
+	}
+	if err != nil {
+		return Cleared, fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+
+	outcome := Cleared
+	select {
+	case <-time.After(timeout):
+	case msg := <-signals:
+		if msg != ClearNow {
+			return Cancelled, nil
+		}
+		outcome = ClearedEarly
+	}
+
+	if b.Capabilities().Read {
+		current, err := b.Read()
+		if err != nil {
+			return outcome, fmt.Errorf("failed to read clipboard for sanity check: %w", err)
+		}
+		curHash := sha256.Sum256(current)
+		if curHash != origHash {
+			return Changed, nil
+		}
+	}
+	if err := b.Clear(); err != nil {
+		return outcome, fmt.Errorf("failed to clear clipboard: %w", err)
+	}
+	return outcome, nil
+}
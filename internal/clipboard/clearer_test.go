@@ -0,0 +1,112 @@
+package clipboard
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memory is a clipboard that only exists in memory. With unreadable set it
+// cannot read the clipboard back; with copied set, someone else copies that
+// right after every Write.
+type memory struct {
+	mu         sync.Mutex
+	text       []byte
+	unreadable bool
+	copied     string
+}
+
+func (m *memory) Write(text []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.text = append([]byte(nil), text...)
+	if m.copied != "" {
+		m.text = []byte(m.copied)
+	}
+	return nil
+}
+
+func (m *memory) Read() ([]byte, error) {
+	if m.unreadable {
+		return nil, ErrUnreadable
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]byte(nil), m.text...), nil
+}
+
+func (m *memory) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.text {
+		m.text[i] = 0
+	}
+	m.text = nil
+	return nil
+}
+
+func (m *memory) Capabilities() Capabilities { return Capabilities{Read: !m.unreadable} }
+
+func TestHold(t *testing.T) {
+	tests := []struct {
+		name       string
+		unreadable bool
+		copied     string
+		timeout    time.Duration
+		signal     string
+		want       Outcome
+		left       string
+	}{
+		{name: "timeout", timeout: time.Millisecond, want: Cleared},
+		{name: "clear now", timeout: time.Hour, signal: ClearNow, want: ClearedEarly},
+		{name: "cancel", timeout: time.Hour, signal: "copy", want: Cancelled, left: "secret"},
+		{name: "changed at timeout", copied: "other", timeout: time.Millisecond, want: Changed, left: "other"},
+		{name: "changed before clear now", copied: "other", timeout: time.Hour, signal: ClearNow, want: Changed, left: "other"},
+		{name: "unreadable", unreadable: true, timeout: time.Millisecond, want: Cleared},
+		{name: "unreadable changed", unreadable: true, copied: "other", timeout: time.Hour, signal: ClearNow, want: ClearedEarly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &memory{unreadable: tt.unreadable, copied: tt.copied}
+			signals := make(chan string, 1)
+			if tt.signal != "" {
+				signals <- tt.signal
+			}
+			got, err := Hold(m, []byte("secret"), tt.timeout, signals)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("outcome = %d, want %d", got, tt.want)
+			}
+			if left := string(m.text); left != tt.left {
+				t.Errorf("clipboard = %q, want %q", left, tt.left)
+			}
+		})
+	}
+}
+
+func TestHoldWipesContent(t *testing.T) {
+	content := []byte("secret")
+	if _, err := Hold(&memory{}, content, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "\x00\x00\x00\x00\x00\x00" {
+		t.Errorf("content = %q, want it wiped", content)
+	}
+}
+
+type failingBackend struct{ memory }
+
+func (*failingBackend) Write([]byte) error { return errors.New("no display") }
+
+func TestHoldWriteError(t *testing.T) {
+	content := []byte("secret")
+	if _, err := Hold(&failingBackend{}, content, time.Hour, nil); err == nil {
+		t.Fatal("Hold succeeded with a failing backend")
+	}
+	if string(content) == "secret" {
+		t.Error("content not wiped after a failed write")
+	}
+}
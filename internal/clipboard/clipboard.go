@@ -16,6 +16,10 @@ import (
 
 const uniqueIDFileName = "clipboard_clearer.id"
 
+// ClearNow is the message that makes a pending clearer clear the clipboard
+// at once instead of at its timeout. Anything else cancels it.
+const ClearNow = "clear"
+
 // Copy copies the given text and clears it after the given duration.
 func Copy(text string, clearAfter time.Duration) error {
 	b := []byte(text)
@@ -37,16 +41,7 @@ func CopyBytes(content []byte, clearAfter time.Duration) error {
 	uniqueIDFilePath := filepath.Join(configDir, uniqueIDFileName)
 
 	// Cancel previous clearer if exists
-	if _, err := os.Stat(uniqueIDFilePath); err == nil {
-		if prevUniqueIDBytes, err := ioutil.ReadFile(uniqueIDFilePath); err == nil {
-			prevUniqueID := string(prevUniqueIDBytes)
-			fifoPath := filepath.Join(configDir, "clipboard_clearer_"+prevUniqueID+".fifo")
-			if fifo, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_NONBLOCK, 0600); err == nil {
-				fmt.Fprintf(fifo, "cancel")
-				fifo.Close()
-			}
-		}
-	}
+	signalClearer(configDir, "cancel")
 
 	// New clearer
 	newUniqueID := uuid.New().String()
@@ -57,6 +52,10 @@ func CopyBytes(content []byte, clearAfter time.Duration) error {
 		newUniqueID,
 	)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	// The clearer's new session has no terminal to write OSC 52 to.
+	if tty := terminalPath(); tty != "" {
+		cmd.Env = append(os.Environ(), ttyEnv+"="+tty)
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -80,3 +79,28 @@ func CopyBytes(content []byte, clearAfter time.Duration) error {
 	}
 	return nil
 }
+
+// ClearPending makes the pending clearer, if any, clear the clipboard now.
+// As at its timeout, content the user copied since is left alone.
+func ClearPending() error {
+	configDir, err := util.GetConfigDir()
+	if err != nil {
+		return fmt.Errorf("failed to get config directory: %w", err)
+	}
+	signalClearer(configDir, ClearNow)
+	return nil
+}
+
+// signalClearer writes msg to the FIFO of the most recent clearer, if it is
+// still waiting.
+func signalClearer(configDir, msg string) {
+	prevUniqueIDBytes, err := ioutil.ReadFile(filepath.Join(configDir, uniqueIDFileName))
+	if err != nil {
+		return
+	}
+	fifoPath := filepath.Join(configDir, "clipboard_clearer_"+string(prevUniqueIDBytes)+".fifo")
+	if fifo, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_NONBLOCK, 0600); err == nil {
+		fmt.Fprint(fifo, msg)
+		fifo.Close()
+	}
+}
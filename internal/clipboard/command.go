@@ -0,0 +1,44 @@
+package clipboard
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// Command is a set of shell commands run with `sh -c` that make up
+// BackendCommand. Write gets the text on stdin, Read prints the clipboard.
+// Without Read the clipboard is cleared even if the user copied something
+// else since; without Clear, clearing writes an empty text.
+type Command struct {
+	Write string
+	Read  string
+	Clear string
+}
+
+type commandBackend struct {
+	cmd Command
+}
+
+func (b commandBackend) Write(text []byte) error {
+	cmd := exec.Command("sh", "-c", b.cmd.Write)
+	cmd.Stdin = bytes.NewReader(text)
+	return cmd.Run()
+}
+
+func (b commandBackend) Read() ([]byte, error) {
+	if b.cmd.Read == "" {
+		return nil, ErrUnreadable
+	}
+	return exec.Command("sh", "-c", b.cmd.Read).Output()
+}
+
+func (b commandBackend) Clear() error {
+	if b.cmd.Clear == "" {
+		return b.Write(nil)
+	}
+	return exec.Command("sh", "-c", b.cmd.Clear).Run()
+}
+
+func (b commandBackend) Capabilities() Capabilities {
+	return Capabilities{Read: b.cmd.Read != ""}
+}
@@ -0,0 +1,98 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ttyEnv names the terminal OSC 52 sequences are written to. Copy sets it
+// for the clearer, which runs in its own session without a controlling
+// terminal; users may set it to pick another terminal.
+const ttyEnv = "MNU_TTY"
+
+// Terminal multiplexers whose passthrough OSC 52 sequences need to get to the
+// outer terminal.
+const (
+	muxNone   = ""
+	muxTmux   = "tmux"
+	muxScreen = "screen"
+)
+
+// screenChunk is how much of a sequence goes into one DCS string; GNU screen
+// drops longer ones.
+const screenChunk = 76
+
+// osc52Sequence returns the escape sequence that sets the terminal's
+// clipboard to text, wrapped for mux. An empty text clears the clipboard.
+func osc52Sequence(text []byte, mux string) []byte {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString(text) + "\x07"
+	switch mux {
+	case muxTmux:
+		// tmux passes DCS content on with every ESC doubled.
+		return []byte("\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\")
+	case muxScreen:
+		var b strings.Builder
+		for len(seq) > 0 {
+			n := min(len(seq), screenChunk)
+			b.WriteString("\x1bP" + seq[:n] + "\x1b\\")
+			seq = seq[n:]
+		}
+		return []byte(b.String())
+	}
+	return []byte(seq)
+}
+
+// multiplexer returns the terminal multiplexer mnu runs in, if any.
+func multiplexer() string {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return muxTmux
+	case os.Getenv("STY") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		return muxScreen
+	}
+	return muxNone
+}
+
+// osc52Backend writes to the clipboard of the terminal named by MNU_TTY, or
+// of the controlling terminal. It cannot read the clipboard back.
+type osc52Backend struct{}
+
+func (osc52Backend) Read() ([]byte, error) { return nil, ErrUnreadable }
+
+// Clear writes an empty clipboard.
+func (b osc52Backend) Clear() error { return b.Write(nil) }
+
+func (osc52Backend) Capabilities() Capabilities { return Capabilities{} }
+
+func (osc52Backend) Write(text []byte) error {
+	path := os.Getenv(ttyEnv)
+	if path == "" {
+		path = "/dev/tty"
+	}
+	tty, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	_, err = tty.Write(osc52Sequence(text, multiplexer()))
+	return err
+}
+
+// terminalPath returns the device of the terminal on stdin, stdout or
+// stderr, for a clearer that has none.
+func terminalPath() string {
+	if path := os.Getenv(ttyEnv); path != "" {
+		return path
+	}
+	for fd := 0; fd <= 2; fd++ {
+		path, err := os.Readlink(filepath.Join("/proc/self/fd", strconv.Itoa(fd)))
+		if err == nil && (strings.HasPrefix(path, "/dev/pts/") || strings.HasPrefix(path, "/dev/tty")) {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestOSC52Sequence(t *testing.T) {
+	long := strings.Repeat("secret", 20) // 120 bytes, 160 in base64
+	longSeq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(long)) + "\x07"
+
+	tests := []struct {
+		name string
+		text string
+		mux  string
+		want string
+	}{
+		{"plain", "hunter2", muxNone, "\x1b]52;c;aHVudGVyMg==\x07"},
+		{"empty clears", "", muxNone, "\x1b]52;c;\x07"},
+		{"tmux doubles ESC", "hunter2", muxTmux, "\x1bPtmux;\x1b\x1b]52;c;aHVudGVyMg==\x07\x1b\\"},
+		{"tmux empty", "", muxTmux, "\x1bPtmux;\x1b\x1b]52;c;\x07\x1b\\"},
+		{"screen short", "hunter2", muxScreen, "\x1bP\x1b]52;c;aHVudGVyMg==\x07\x1b\\"},
+		{"screen chunks", long, muxScreen,
+			"\x1bP" + longSeq[:76] + "\x1b\\" +
+				"\x1bP" + longSeq[76:152] + "\x1b\\" +
+				"\x1bP" + longSeq[152:] + "\x1b\\"},
+		{"screen empty", "", muxScreen, "\x1bP\x1b]52;c;\x07\x1b\\"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(osc52Sequence([]byte(tt.text), tt.mux)); got != tt.want {
+				t.Errorf("osc52Sequence(%q, %q) = %q, want %q", tt.text, tt.mux, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiplexer(t *testing.T) {
+	tests := []struct {
+		tmux, sty, term string
+		want            string
+	}{
+		{"/tmp/tmux-1000/default,1234,0", "", "tmux-256color", muxTmux},
+		{"", "1234.pts-0.host", "screen", muxScreen},
+		{"", "", "screen-256color", muxScreen},
+		{"", "", "xterm-256color", muxNone},
+	}
+	for _, tt := range tests {
+		t.Setenv("TMUX", tt.tmux)
+		t.Setenv("STY", tt.sty)
+		t.Setenv("TERM", tt.term)
+		if got := multiplexer(); got != tt.want {
+			t.Errorf("multiplexer() with TMUX=%q STY=%q TERM=%q = %q, want %q", tt.tmux, tt.sty, tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package clipboard
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// tmuxBuffer is the paste buffer copies go to, pasted with
+// `tmux paste-buffer -b mnu` or picked from `choose-buffer`.
+const tmuxBuffer = "mnu"
+
+// tmuxBackend keeps copies in a tmux paste buffer, for terminals without a
+// clipboard of their own.
+type tmuxBackend struct{}
+
+func (tmuxBackend) Write(text []byte) error {
+	cmd := exec.Command("tmux", "load-buffer", "-b", tmuxBuffer, "-")
+	cmd.Stdin = bytes.NewReader(text)
+	return cmd.Run()
+}
+
+func (tmuxBackend) Read() ([]byte, error) {
+	return exec.Command("tmux", "save-buffer", "-b", tmuxBuffer, "-").Output()
+}
+
+func (tmuxBackend) Clear() error {
+	return exec.Command("tmux", "delete-buffer", "-b", tmuxBuffer).Run()
+}
+
+func (tmuxBackend) Capabilities() Capabilities { return Capabilities{Read: true} }
@@ -0,0 +1,323 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// The data-control protocols mnu can own a selection with, preferred first.
+// Compositors without either (e.g. GNOME) get wl-copy, which offers a
+// single MIME type and thus cannot add the password manager hints.
+const (
+	extDataControl = "ext_data_control_manager_v1"
+	wlrDataControl = "zwlr_data_control_manager_v1"
+)
+
+var errNoDataControl = errors.New("compositor does not support data control")
+
+// textTypes are offered for the copied text.
+var textTypes = []string{"text/plain;charset=utf-8", "text/plain", "UTF8_STRING", "STRING", "TEXT"}
+
+// sensitiveHints are offered next to the text, so that clipboard managers
+// and history tools like Klipper and cliphist do not record it.
+var sensitiveHints = map[string]string{
+	"x-kde-passwordManagerHint":      "secret",
+	"org.nspasteboard.ConcealedType": "",
+}
+
+// How a waylandBackend gets to the selection.
+const (
+	pathUnknown = iota
+	pathDataControl
+	pathWlCopy
+)
+
+// waylandBackend owns the selection itself through data control, falling
+// back to wl-copy and wl-paste.
+type waylandBackend struct {
+	opts Options
+	sel  *wlSelection
+	path int
+}
+
+// Write does not pass PasteOnce on to wl-copy: without the hints a
+// clipboard manager reads the text at once, which would be its one paste.
+func (b *waylandBackend) Write(text []byte) error {
+	sel, err := ownSelection(text, b.opts)
+	if err == nil {
+		b.sel = sel
+		b.path = pathDataControl
+		return nil
+	}
+	if !errors.Is(err, errNoDataControl) {
+		return err
+	}
+	b.path = pathWlCopy
+	args := []string{"--type", textTypes[0]}
+	if b.opts.Primary {
+		args = append(args, "--primary")
+	}
+	cmd := exec.Command("wl-copy", args...)
+	cmd.Stdin = bytes.NewReader(text)
+	return cmd.Run()
+}
+
+// Read returns the text while mnu still owns the selection and nothing once
+// it was pasted (with PasteOnce) or replaced.
+func (b *waylandBackend) Read() ([]byte, error) {
+	if b.sel != nil {
+		return b.sel.content(), nil
+	}
+	args := []string{"--no-newline"}
+	if b.opts.Primary {
+		args = append(args, "--primary")
+	}
+	return exec.Command("wl-paste", args...).Output()
+}
+
+func (b *waylandBackend) Clear() error {
+	if b.sel != nil {
+		return b.sel.clear()
+	}
+	args := []string{"--clear"}
+	if b.opts.Primary {
+		args = append(args, "--primary")
+	}
+	return exec.Command("wl-copy", args...).Run()
+}
+
+// Capabilities reports what the path Write took supports, asking the
+// compositor before the first Write. With wl-copy there are no hints and no
+// paste-once.
+func (b *waylandBackend) Capabilities() Capabilities {
+	if b.path == pathUnknown {
+		b.path = pathWlCopy
+		if hasDataControl(b.opts) {
+			b.path = pathDataControl
+		}
+	}
+	if b.path == pathWlCopy {
+		return Capabilities{Read: true, Primary: true}
+	}
+	return Capabilities{Read: true, PasteOnce: true, Primary: true, Hints: true}
+}
+
+// wlSelection is a selection owned through data control. It lives as long
+// as the connection: the compositor drops it when the process exits.
+type wlSelection struct {
+	conn         *wlConn
+	device       uint32
+	source       uint32
+	setSelection uint16 // set_selection or set_primary_selection
+	pasteOnce    bool
+	done         chan struct{} // closed when serve returns
+
+	mu    sync.Mutex
+	text  []byte
+	owned bool
+}
+
+// ownSelection offers text, along with sensitiveHints, as the clipboard or
+// primary selection and keeps serving it to pasting clients.
+func ownSelection(text []byte, opts Options) (*wlSelection, error) {
+	w, err := wlDial()
+	if err != nil {
+		return nil, err
+	}
+	registry, globals, err := wlGlobals(w)
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	iface, version := dataControlManager(globals, opts)
+	if iface == "" {
+		w.close()
+		return nil, errNoDataControl
+	}
+	bind := func(iface string, version uint32) uint32 {
+		id := w.newID()
+		w.request(registry, 0, globals[iface].name, iface, version, id)
+		return id
+	}
+	manager := bind(iface, version)
+	seat := bind("wl_seat", 1)
+
+	s := &wlSelection{
+		conn:      w,
+		pasteOnce: opts.PasteOnce,
+		done:      make(chan struct{}),
+		text:      append([]byte(nil), text...),
+		owned:     true,
+	}
+	if opts.Primary {
+		s.setSelection = 2
+	}
+	s.device = w.newID()
+	w.request(manager, 1, s.device, seat)
+	s.source = w.newID()
+	w.request(manager, 0, s.source)
+	for _, mime := range textTypes {
+		w.request(s.source, 0, mime)
+	}
+	for mime := range sensitiveHints {
+		w.request(s.source, 0, mime)
+	}
+	w.request(s.device, s.setSelection, s.source)
+	if err := w.roundtrip(s.handle); err != nil {
+		s.wipe()
+		w.close()
+		return nil, err
+	}
+	go s.serve()
+	return s, nil
+}
+
+type wlGlobal struct{ name, version uint32 }
+
+// wlGlobals binds the registry and returns it with the globals the
+// compositor announced, by interface.
+func wlGlobals(w *wlConn) (uint32, map[string]wlGlobal, error) {
+	globals := map[string]wlGlobal{}
+	registry := w.newID()
+	w.request(wlDisplay, 1, registry)
+	err := w.roundtrip(func(ev wlEvent) {
+		if ev.sender != registry || ev.opcode != 0 {
+			return
+		}
+		name, iface, version := ev.uint(), ev.str(), ev.uint()
+		if _, seen := globals[iface]; !seen {
+			globals[iface] = wlGlobal{name, version}
+		}
+	})
+	return registry, globals, err
+}
+
+// dataControlManager picks the data-control protocol and version to bind,
+// or returns "" if there is none that can do what opts ask for.
+func dataControlManager(globals map[string]wlGlobal, opts Options) (string, uint32) {
+	if globals["wl_seat"].version == 0 {
+		return "", 0
+	}
+	switch ext, wlr := globals[extDataControl], globals[wlrDataControl]; {
+	case ext.version >= 1:
+		return extDataControl, 1
+	case wlr.version >= 2 || (wlr.version == 1 && !opts.Primary):
+		// Version 2 added the primary selection.
+		return wlrDataControl, min(wlr.version, 2)
+	}
+	return "", 0
+}
+
+// hasDataControl reports whether the compositor lets mnu own the selection.
+func hasDataControl(opts Options) bool {
+	w, err := wlDial()
+	if err != nil {
+		return false
+	}
+	defer w.close()
+	_, globals, err := wlGlobals(w)
+	if err != nil {
+		return false
+	}
+	iface, _ := dataControlManager(globals, opts)
+	return iface != ""
+}
+
+func (s *wlSelection) serve() {
+	defer close(s.done)
+	defer s.conn.close()
+	for {
+		ev, err := s.conn.next()
+		if err != nil {
+			return
+		}
+		if err := s.conn.dispatch(ev, s.handle); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers the events of the data source: send asks for the content
+// of one of the offered types, cancelled means another client took over.
+func (s *wlSelection) handle(ev wlEvent) {
+	if ev.sender != s.source {
+		return
+	}
+	switch ev.opcode {
+	case 0: // send
+		mime := ev.str()
+		f, err := s.conn.takeFD()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		data := s.text
+		hint, isHint := sensitiveHints[mime]
+		if isHint {
+			data = []byte(hint)
+		}
+		_, _ = f.Write(data)
+		f.Close()
+		pasted := !isHint && s.owned && s.pasteOnce
+		s.mu.Unlock()
+		if pasted {
+			s.release()
+		}
+	case 1: // cancelled
+		s.mu.Lock()
+		s.owned = false
+		s.mu.Unlock()
+		s.conn.request(s.source, 1)
+	}
+}
+
+func (s *wlSelection) content() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.owned {
+		return nil
+	}
+	return append([]byte(nil), s.text...)
+}
+
+// release clears the selection if it is still ours; clearing someone
+// else's would lose what the user copied since.
+func (s *wlSelection) release() bool {
+	s.mu.Lock()
+	owned := s.owned
+	s.owned = false
+	s.mu.Unlock()
+	if owned {
+		s.conn.request(s.device, s.setSelection, uint32(0))
+	}
+	return owned
+}
+
+// clear releases the selection, waits for the compositor to process that
+// and disconnects.
+func (s *wlSelection) clear() error {
+	if s.release() {
+		select {
+		case <-s.conn.sync():
+		case <-s.done:
+		case <-time.After(time.Second):
+		}
+	}
+	err := s.conn.failed()
+	// serve cleans up once the connection is gone.
+	s.conn.conn.Close()
+	<-s.done
+	s.wipe()
+	return err
+}
+
+func (s *wlSelection) wipe() {
+	s.mu.Lock()
+	for i := range s.text {
+		s.text[i] = 0
+	}
+	s.mu.Unlock()
+}
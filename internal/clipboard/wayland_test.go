@@ -0,0 +1,40 @@
+package clipboard
+
+import "testing"
+
+func TestDataControlManager(t *testing.T) {
+	seat := wlGlobal{name: 1, version: 7}
+	tests := []struct {
+		name        string
+		globals     map[string]wlGlobal
+		primary     bool
+		wantIface   string
+		wantVersion uint32
+	}{
+		{"ext", map[string]wlGlobal{"wl_seat": seat, extDataControl: {2, 1}, wlrDataControl: {3, 2}}, true, extDataControl, 1},
+		{"wlr v2", map[string]wlGlobal{"wl_seat": seat, wlrDataControl: {3, 2}}, true, wlrDataControl, 2},
+		{"wlr v1", map[string]wlGlobal{"wl_seat": seat, wlrDataControl: {3, 1}}, false, wlrDataControl, 1},
+		{"wlr v1 primary", map[string]wlGlobal{"wl_seat": seat, wlrDataControl: {3, 1}}, true, "", 0},
+		{"no seat", map[string]wlGlobal{extDataControl: {2, 1}}, false, "", 0},
+		{"none", map[string]wlGlobal{"wl_seat": seat}, false, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iface, version := dataControlManager(tt.globals, Options{Primary: tt.primary})
+			if iface != tt.wantIface || version != tt.wantVersion {
+				t.Errorf("dataControlManager = %q %d, want %q %d", iface, version, tt.wantIface, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestWaylandCapabilities(t *testing.T) {
+	b := &waylandBackend{path: pathWlCopy}
+	if c := b.Capabilities(); c.Hints || c.PasteOnce || !c.Read {
+		t.Errorf("wl-copy capabilities = %+v, want Read without Hints or PasteOnce", c)
+	}
+	b.path = pathDataControl
+	if c := b.Capabilities(); !c.Hints || !c.PasteOnce {
+		t.Errorf("data control capabilities = %+v, want Hints and PasteOnce", c)
+	}
+}
@@ -0,0 +1,239 @@
+package clipboard
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// A minimal Wayland client, just enough to own a selection through the
+// data-control protocols. Requests only carry integers and strings; the only
+// file descriptors are the ones the compositor sends along with events.
+
+// wlDisplay is the object ID of wl_display.
+const wlDisplay = 1
+
+type wlConn struct {
+	conn *net.UnixConn
+
+	mu        sync.Mutex // guards the fields below and serializes requests
+	nextID    uint32
+	callbacks map[uint32]chan struct{}
+	err       error // first failed request
+
+	in  []byte
+	fds []int
+}
+
+type wlEvent struct {
+	sender uint32
+	opcode uint16
+	args   []byte
+}
+
+// wlDial connects to the compositor named by WAYLAND_DISPLAY.
+func wlDial() (*wlConn, error) {
+	path := os.Getenv("WAYLAND_DISPLAY")
+	if path == "" {
+		path = "wayland-0"
+	}
+	if !filepath.IsAbs(path) {
+		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		if runtimeDir == "" {
+			return nil, errors.New("XDG_RUNTIME_DIR is not set")
+		}
+		path = filepath.Join(runtimeDir, path)
+	}
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Wayland: %w", err)
+	}
+	return &wlConn{conn: conn, nextID: wlDisplay, callbacks: map[uint32]chan struct{}{}}, nil
+}
+
+func (w *wlConn) close() {
+	w.conn.Close()
+	for _, fd := range w.fds {
+		syscall.Close(fd)
+	}
+	w.fds = nil
+}
+
+func (w *wlConn) newID() uint32 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextID++
+	return w.nextID
+}
+
+// request sends a request with uint32 and string arguments. A failure is
+// kept and reported by the next roundtrip or failed.
+func (w *wlConn) request(object uint32, opcode uint16, args ...interface{}) {
+	var body []byte
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint32:
+			body = binary.NativeEndian.AppendUint32(body, v)
+		case string:
+			body = binary.NativeEndian.AppendUint32(body, uint32(len(v)+1))
+			body = append(body, v...)
+			body = append(body, 0)
+			for len(body)%4 != 0 {
+				body = append(body, 0)
+			}
+		}
+	}
+	msg := binary.NativeEndian.AppendUint32(nil, object)
+	msg = binary.NativeEndian.AppendUint32(msg, uint32(8+len(body))<<16|uint32(opcode))
+	msg = append(msg, body...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	if _, err := w.conn.Write(msg); err != nil {
+		w.err = err
+	}
+}
+
+func (w *wlConn) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// sync asks the compositor for a callback; the returned channel is closed
+// once it processed every request sent before.
+func (w *wlConn) sync() <-chan struct{} {
+	id := w.newID()
+	done := make(chan struct{})
+	w.mu.Lock()
+	w.callbacks[id] = done
+	w.mu.Unlock()
+	w.request(wlDisplay, 0, id)
+	return done
+}
+
+// next reads the next event, queueing the file descriptors that came with
+// it for takeFD.
+func (w *wlConn) next() (wlEvent, error) {
+	for {
+		if len(w.in) >= 8 {
+			header := binary.NativeEndian.Uint32(w.in[4:])
+			size := int(header >> 16)
+			if size < 8 {
+				return wlEvent{}, errors.New("invalid Wayland message")
+			}
+			if len(w.in) >= size {
+				ev := wlEvent{
+					sender: binary.NativeEndian.Uint32(w.in),
+					opcode: uint16(header),
+					args:   append([]byte(nil), w.in[8:size]...),
+				}
+				w.in = w.in[size:]
+				return ev, nil
+			}
+		}
+		buf := make([]byte, 4096)
+		oob := make([]byte, syscall.CmsgSpace(28*4))
+		n, oobn, _, _, err := w.conn.ReadMsgUnix(buf, oob)
+		if err != nil {
+			return wlEvent{}, err
+		}
+		if msgs, err := syscall.ParseSocketControlMessage(oob[:oobn]); err == nil {
+			for i := range msgs {
+				if fds, err := syscall.ParseUnixRights(&msgs[i]); err == nil {
+					w.fds = append(w.fds, fds...)
+				}
+			}
+		}
+		if n == 0 {
+			return wlEvent{}, io.EOF
+		}
+		w.in = append(w.in, buf[:n]...)
+	}
+}
+
+// takeFD returns the oldest file descriptor received and not taken yet.
+func (w *wlConn) takeFD() (*os.File, error) {
+	if len(w.fds) == 0 {
+		return nil, errors.New("missing file descriptor in Wayland event")
+	}
+	fd := w.fds[0]
+	w.fds = w.fds[1:]
+	return os.NewFile(uintptr(fd), "wayland"), nil
+}
+
+// dispatch handles wl_display events and callbacks and passes all other
+// events to handle.
+func (w *wlConn) dispatch(ev wlEvent, handle func(wlEvent)) error {
+	if ev.sender == wlDisplay {
+		if ev.opcode == 0 {
+			object, code, msg := ev.uint(), ev.uint(), ev.str()
+			return fmt.Errorf("Wayland error %d on object %d: %s", code, object, msg)
+		}
+		return nil // delete_id
+	}
+	w.mu.Lock()
+	done, ok := w.callbacks[ev.sender]
+	delete(w.callbacks, ev.sender)
+	w.mu.Unlock()
+	if ok {
+		close(done)
+		return nil
+	}
+	if handle != nil {
+		handle(ev)
+	}
+	return nil
+}
+
+// roundtrip dispatches events until the compositor processed every request
+// sent so far.
+func (w *wlConn) roundtrip(handle func(wlEvent)) error {
+	done := w.sync()
+	for {
+		if err := w.failed(); err != nil {
+			return err
+		}
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		ev, err := w.next()
+		if err != nil {
+			return err
+		}
+		if err := w.dispatch(ev, handle); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *wlEvent) uint() uint32 {
+	if len(e.args) < 4 {
+		return 0
+	}
+	v := binary.NativeEndian.Uint32(e.args)
+	e.args = e.args[4:]
+	return v
+}
+
+func (e *wlEvent) str() string {
+	n := int(e.uint())
+	padded := (n + 3) &^ 3
+	if n == 0 || padded > len(e.args) {
+		return ""
+	}
+	s := string(e.args[:n-1])
+	e.args = e.args[padded:]
+	return s
+}
@@ -11,8 +11,48 @@ import (
 )
 
 type Config struct {
-	ClipboardTimeout time.Duration `mapstructure:"clipboard_timeout"`
-	ApiMode          bool          `mapstructure:"api_mode"`
+	ClipboardTimeout       time.Duration    `mapstructure:"clipboard_timeout"`
+	ClipboardBackend       string           `mapstructure:"clipboard_backend"`
+	ClipboardCommand       ClipboardCommand `mapstructure:"clipboard_command"`
+	ClipboardPasteOnce     bool             `mapstructure:"clipboard_paste_once"`
+	ClipboardSelection     string           `mapstructure:"clipboard_selection"`
+	ApiMode                bool             `mapstructure:"api_mode"`
+	AuditMaxAgeDays        int              `mapstructure:"audit_max_age_days"`
+	BreachDB               string           `mapstructure:"breach_db"`
+	GitCredentialStore     bool             `mapstructure:"git_credential_store"`
+	GitCredentialFolder    string           `mapstructure:"git_credential_folder"`
+	AskpassRules           []AskpassRule    `mapstructure:"askpass_rules"`
+	SecretServiceFolder    string           `mapstructure:"secret_service_folder"`
+	DockerCredentialFolder string           `mapstructure:"docker_credential_folder"`
+	PinUnlock              bool             `mapstructure:"pin_unlock"`
+	PinTTL                 time.Duration    `mapstructure:"pin_ttl"`
+	PinMaxAttempts         int              `mapstructure:"pin_max_attempts"`
+	LockOnEvents           bool             `mapstructure:"lock_on_events"`
+
+	SessionStore             string        `mapstructure:"session_store"`
+	SessionTTL               time.Duration `mapstructure:"session_ttl"`
+	SessionPlaintextFallback bool          `mapstructure:"session_plaintext_fallback"`
+	KernelKeyring            string        `mapstructure:"kernel_keyring"`
+	KernelKeyringTimeout     time.Duration `mapstructure:"kernel_keyring_timeout"`
+	SessionGPGRecipient      string        `mapstructure:"session_gpg_recipient"`
+	SessionAgeRecipient      string        `mapstructure:"session_age_recipient"`
+	SessionAgeIdentity       string        `mapstructure:"session_age_identity"`
+}
+
+// ClipboardCommand holds the shell commands of the command clipboard
+// backend: write gets the text on stdin, read prints the clipboard and clear
+// empties it. Only write is required.
+type ClipboardCommand struct {
+	Write string `mapstructure:"write"`
+	Read  string `mapstructure:"read"`
+	Clear string `mapstructure:"clear"`
+}
+
+// AskpassRule answers askpass and pinentry prompts matching a regular
+// expression with the secret a bw:// reference points to.
+type AskpassRule struct {
+	Prompt string `mapstructure:"prompt"`
+	Ref    string `mapstructure:"ref"`
 }
 
 func Load() (*Config, error) {
@@ -26,7 +66,18 @@ func Load() (*Config, error) {
 	v.AddConfigPath(".")
 
 	v.SetDefault("clipboard_timeout", 15*time.Second)
+	v.SetDefault("clipboard_backend", "auto")
+	v.SetDefault("clipboard_selection", "clipboard")
 	v.SetDefault("api_mode", true)
+	v.SetDefault("audit_max_age_days", 365)
+	v.SetDefault("secret_service_folder", "Secret Service")
+	v.SetDefault("docker_credential_folder", "Docker registries")
+	v.SetDefault("git_credential_folder", "Git credentials")
+	v.SetDefault("pin_ttl", 12*time.Hour)
+	v.SetDefault("pin_max_attempts", 3)
+	v.SetDefault("lock_on_events", true)
+	v.SetDefault("session_store", "secret-service")
+	v.SetDefault("kernel_keyring", "user")
 
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
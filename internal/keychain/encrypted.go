@@ -0,0 +1,111 @@
+package keychain
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/netbrain/mnu/internal/util"
+)
+
+// encryptedStore keeps the session key in a file encrypted by an external
+// tool. encrypt reads the key on stdin and writes the ciphertext to the
+// path given as its last argument; decrypt prints the key.
+type encryptedStore struct {
+	file    string
+	encrypt func(out string) *exec.Cmd
+	decrypt func(in string) *exec.Cmd
+}
+
+func newGPGStore(recipient string) Store {
+	return encryptedStore{
+		file: "session.gpg",
+		encrypt: func(out string) *exec.Cmd {
+			return exec.Command("gpg", "--batch", "--yes", "--quiet", "--encrypt", "--recipient", recipient, "--output", out)
+		},
+		decrypt: func(in string) *exec.Cmd {
+			return exec.Command("gpg", "--batch", "--quiet", "--decrypt", in)
+		},
+	}
+}
+
+// newAgeStore takes an age or SSH public key, or a recipients file.
+func newAgeStore(recipient, identity string) Store {
+	flag := "-R"
+	if strings.HasPrefix(recipient, "age1") || strings.HasPrefix(recipient, "ssh-") {
+		flag = "-r"
+	}
+	return encryptedStore{
+		file: "session.age",
+		encrypt: func(out string) *exec.Cmd {
+			return exec.Command("age", "--encrypt", flag, recipient, "-o", out)
+		},
+		decrypt: func(in string) *exec.Cmd {
+			return exec.Command("age", "--decrypt", "-i", identity, in)
+		},
+	}
+}
+
+func (s encryptedStore) path() (string, error) {
+	dir, err := util.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, s.file), nil
+}
+
+func (s encryptedStore) Get() (string, error) {
+	path, err := s.path()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", ErrSessionKeyNotFound
+	}
+	var stderr bytes.Buffer
+	cmd := s.decrypt(path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	if len(out) == 0 {
+		return "", ErrSessionKeyNotFound
+	}
+	return string(out), nil
+}
+
+// Set encrypts into a temporary file first so a failed run never leaves a
+// truncated file behind.
+func (s encryptedStore) Set(key string) error {
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+	var stderr bytes.Buffer
+	cmd := s.encrypt(tmp)
+	cmd.Stdin = strings.NewReader(key)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("%s: %w: %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	_ = os.Chmod(tmp, 0600)
+	return os.Rename(tmp, path)
+}
+
+func (s encryptedStore) Delete() error {
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package keychain
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/netbrain/mnu/internal/util"
+)
+
+// fileStore keeps the session key in plain text in ~/.config/mnu/session.
+// Only used when chosen explicitly or as the opt-in fallback.
+type fileStore struct{}
+
+func getSessionKeyPath() (string, error) {
+	configDir, err := util.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "session"), nil
+}
+
+func (fileStore) Get() (string, error) {
+	path, err := getSessionKeyPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+		return "", ErrSessionKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (fileStore) Set(key string) error {
+	path, err := getSessionKeyPath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(key), 0600)
+}
+
+func (fileStore) Delete() error {
+	path, err := getSessionKeyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
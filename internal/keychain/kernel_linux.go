@@ -0,0 +1,109 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Permissions for the key: everything for possessors, and view, read, write,
+// search and setattr for the owning user, so other processes of the same
+// user (which may not possess the keyring) can find it.
+const kernelKeyPerm = 0x3f000000 | 0x003f0000
+
+// kernelStore keeps the session key in the Linux kernel keyring as a "user"
+// key, which never touches the disk and goes away on reboot (user keyring)
+// or logout (session keyring).
+type kernelStore struct {
+	ring    int
+	timeout time.Duration
+}
+
+func newKernelStore(ring string, timeout time.Duration) (Store, error) {
+	s := kernelStore{timeout: timeout}
+	switch ring {
+	case "user", "":
+		s.ring = unix.KEY_SPEC_USER_KEYRING
+	case "session":
+		s.ring = unix.KEY_SPEC_SESSION_KEYRING
+	default:
+		return nil, fmt.Errorf("unknown kernel_keyring %q (want user or session)", ring)
+	}
+	return s, nil
+}
+
+func (s kernelStore) description() (string, error) {
+	user, err := currentUser()
+	if err != nil {
+		return "", err
+	}
+	return ServiceName + ":session:" + user, nil
+}
+
+func (s kernelStore) find() (int, error) {
+	desc, err := s.description()
+	if err != nil {
+		return 0, err
+	}
+	id, err := unix.KeyctlSearch(s.ring, "user", desc, 0)
+	if errors.Is(err, unix.ENOKEY) || errors.Is(err, unix.EKEYEXPIRED) || errors.Is(err, unix.EKEYREVOKED) {
+		return 0, ErrSessionKeyNotFound
+	}
+	return id, err
+}
+
+func (s kernelStore) Get() (string, error) {
+	id, err := s.find()
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, 512)
+	for {
+		n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EKEYEXPIRED) || errors.Is(err, unix.EKEYREVOKED) {
+				return "", ErrSessionKeyNotFound
+			}
+			return "", err
+		}
+		if n <= len(buf) {
+			return string(buf[:n]), nil
+		}
+		buf = make([]byte, n)
+	}
+}
+
+func (s kernelStore) Set(key string) error {
+	desc, err := s.description()
+	if err != nil {
+		return err
+	}
+	id, err := unix.AddKey("user", desc, []byte(key), s.ring)
+	if err != nil {
+		return err
+	}
+	if err := unix.KeyctlSetperm(id, kernelKeyPerm); err != nil {
+		return err
+	}
+	if s.timeout > 0 {
+		secs := int((s.timeout + time.Second - 1) / time.Second)
+		if _, err := unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, secs, 0, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s kernelStore) Delete() error {
+	id, err := s.find()
+	if errors.Is(err, ErrSessionKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = unix.KeyctlInt(unix.KEYCTL_INVALIDATE, id, 0, 0, 0)
+	return err
+}
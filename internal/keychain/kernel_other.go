@@ -0,0 +1,12 @@
+//go:build !linux
+
+package keychain
+
+import (
+	"errors"
+	"time"
+)
+
+func newKernelStore(ring string, timeout time.Duration) (Store, error) {
+	return nil, errors.New("session_store kernel is only available on Linux")
+}
@@ -1,94 +1,224 @@
 package keychain
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/zalando/go-keyring"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
 )
 
-const serviceName = "mnu"
+// ServiceName is the service the session key is stored under.
+const ServiceName = "mnu"
+
+var ErrSessionKeyNotFound = fmt.Errorf("session key not found")
+
+// ErrSessionExpired is returned by GetSessionKey once the key is older than
+// session_ttl. The key is left in place; callers lock the vault and then
+// call DeleteSessionKey.
+var ErrSessionExpired = fmt.Errorf("session expired")
+
+// Store keeps the Bitwarden session key. Get returns ErrSessionKeyNotFound
+// when there is none.
+type Store interface {
+	Get() (string, error)
+	Set(key string) error
+	Delete() error
+}
+
+// Backend names accepted in the session_store config key.
+const (
+	BackendSecretService = "secret-service"
+	BackendKernel        = "kernel"
+	BackendGPG           = "gpg"
+	BackendAge           = "age"
+	BackendMemory        = "memory"
+	BackendFile          = "file"
+)
+
+var (
+	mu     sync.Mutex
+	active Store
+	ttl    time.Duration
+)
+
+// envelope is what actually goes into the store: the key together with
+// when it was issued and, with session_ttl set, when it stops being valid.
+type envelope struct {
+	Key     string    `json:"key"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// New returns the store configured by session_store. With
+// session_plaintext_fallback set, a failing store falls back to the
+// plaintext session file.
+func New(cfg *cfgpkg.Config) (Store, error) {
+	var s Store
+	switch cfg.SessionStore {
+	case BackendSecretService, "":
+		s = keyringStore{}
+	case BackendKernel:
+		ks, err := newKernelStore(cfg.KernelKeyring, cfg.KernelKeyringTimeout)
+		if err != nil {
+			return nil, err
+		}
+		s = ks
+	case BackendGPG:
+		if cfg.SessionGPGRecipient == "" {
+			return nil, fmt.Errorf("session_store gpg needs session_gpg_recipient")
+		}
+		s = newGPGStore(cfg.SessionGPGRecipient)
+	case BackendAge:
+		if cfg.SessionAgeRecipient == "" || cfg.SessionAgeIdentity == "" {
+			return nil, fmt.Errorf("session_store age needs session_age_recipient and session_age_identity")
+		}
+		s = newAgeStore(cfg.SessionAgeRecipient, cfg.SessionAgeIdentity)
+	case BackendMemory:
+		s = memoryStore{}
+	case BackendFile:
+		return fileStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown session_store %q", cfg.SessionStore)
+	}
+	if cfg.SessionPlaintextFallback {
+		s = fallbackStore{primary: s}
+	}
+	return s, nil
+}
+
+// Use replaces the store used by the package-level functions.
+func Use(s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+	active = s
+}
+
+// current returns the active store and session TTL, building both from the
+// config on first use.
+func current() (Store, time.Duration, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		cfg, err := cfgpkg.Load()
+		if err != nil {
+			return nil, 0, err
+		}
+		s, err := New(cfg)
+		if err != nil {
+			return nil, 0, err
+		}
+		active = s
+		ttl = cfg.SessionTTL
+	}
+	return active, ttl, nil
+}
 
 func SetSessionKey(password string) error {
-	user := os.Getenv("USER")
-	if user == "" {
-		return fmt.Errorf("USER environment variable not set")
+	s, ttl, err := current()
+	if err != nil {
+		return err
+	}
+	env := envelope{Key: password, Created: time.Now()}
+	if ttl > 0 {
+		env.Expires = env.Created.Add(ttl)
 	}
-	err := keyring.Set(serviceName, user, password)
+	data, err := json.Marshal(env)
 	if err != nil {
-		// fall back to file
-		path, e := getSessionKeyPath()
-		if e != nil {
-			return e
+		return err
+	}
+	if err := s.Set(string(data)); err != nil {
+		return fmt.Errorf("failed to store session key: %w", err)
+	}
+	// Drop a plaintext copy left behind by older versions.
+	if _, isFile := s.(fileStore); !isFile {
+		if _, isFallback := s.(fallbackStore); !isFallback {
+			_ = fileStore{}.Delete()
 		}
-		return os.WriteFile(path, []byte(password), 0600)
 	}
 	return nil
 }
 
-var ErrSessionKeyNotFound = fmt.Errorf("session key not found")
-
 func GetSessionKey() (string, error) {
-	user := os.Getenv("USER")
-	if user == "" {
-		return "", fmt.Errorf("USER environment variable not set")
+	s, ttl, err := current()
+	if err != nil {
+		return "", err
 	}
-	password, err := keyring.Get(serviceName, user)
+	raw, err := s.Get()
 	if err != nil {
-		path, e := getSessionKeyPath()
-		if e != nil {
-			return "", e
-		}
-		data, e := os.ReadFile(path)
-		if e != nil {
-			if os.IsNotExist(e) {
-				return "", ErrSessionKeyNotFound
-			}
-			return "", e
+		return "", err
+	}
+	var env envelope
+	if !strings.HasPrefix(raw, "{") || json.Unmarshal([]byte(raw), &env) != nil || env.Key == "" {
+		// A bare key from an older version; its age is unknown, so it
+		// cannot satisfy a TTL.
+		if ttl > 0 {
+			return "", ErrSessionExpired
 		}
-		if len(data) == 0 {
-			return "", ErrSessionKeyNotFound
+		return raw, nil
+	}
+	expires := env.Expires
+	if expires.IsZero() && ttl > 0 {
+		// Stored before session_ttl was set; it still may not outlive it.
+		if env.Created.IsZero() {
+			return "", ErrSessionExpired
 		}
-		return string(data), nil
+		expires = env.Created.Add(ttl)
+	}
+	if !expires.IsZero() && !time.Now().Before(expires) {
+		return "", ErrSessionExpired
 	}
-	return password, nil
+	return env.Key, nil
 }
 
 func DeleteSessionKey() error {
-	user := os.Getenv("USER")
-	if user == "" {
-		return fmt.Errorf("USER environment variable not set")
-	}
-	err := keyring.Delete(serviceName, user)
-	if err != nil {
-		log.Printf("Warning: could not delete session key from keyring: %v", err)
-	}
-
-	path, err := getSessionKeyPath()
+	s, _, err := current()
 	if err != nil {
 		return err
 	}
+	if err := s.Delete(); err != nil {
+		log.Printf("Warning: could not delete session key: %v", err)
+	}
+	// Also remove the plaintext file older versions fell back to.
+	return fileStore{}.Delete()
+}
 
-	if _, err := os.Stat(path); err == nil {
-		err = os.Remove(path)
-		if err != nil {
-			return err
-		}
+// fallbackStore writes to the plaintext file when primary fails. It is only
+// used when session_plaintext_fallback is set.
+type fallbackStore struct{ primary Store }
+
+func (s fallbackStore) Get() (string, error) {
+	key, err := s.primary.Get()
+	if err == nil {
+		return key, nil
 	}
+	return fileStore{}.Get()
+}
 
+func (s fallbackStore) Set(key string) error {
+	if err := s.primary.Set(key); err != nil {
+		log.Printf("Warning: session store failed (%v); writing the session key to a plaintext file", err)
+		return fileStore{}.Set(key)
+	}
 	return nil
 }
 
-func getSessionKeyPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+func (s fallbackStore) Delete() error {
+	err := s.primary.Delete()
+	if ferr := (fileStore{}).Delete(); ferr != nil {
+		return ferr
 	}
-	configDir := filepath.Join(home, ".config", "mnu")
-	if err := os.MkdirAll(configDir, 0700); err != nil {
-		return "", err
+	return err
+}
+
+func currentUser() (string, error) {
+	user := os.Getenv("USER")
+	if user == "" {
+		return "", fmt.Errorf("USER environment variable not set")
 	}
-	return filepath.Join(configDir, "session"), nil
+	return user, nil
 }
@@ -0,0 +1,133 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testStore is a Store over a string held by the test; a nil key means none.
+type testStore struct{ key *string }
+
+func (s testStore) Get() (string, error) {
+	if s.key == nil {
+		return "", ErrSessionKeyNotFound
+	}
+	return *s.key, nil
+}
+
+func (s testStore) Set(key string) error { *s.key = key; return nil }
+
+func (s testStore) Delete() error { *s.key = ""; return nil }
+
+// useStore makes the package functions use an in-memory store holding raw
+// (nil for none) with the given session TTL. HOME points at a temp dir since
+// the functions also clean up the plaintext session file.
+func useStore(t *testing.T, raw *string, sessionTTL time.Duration) testStore {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	mu.Lock()
+	prevStore, prevTTL := active, ttl
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		active, ttl = prevStore, prevTTL
+		mu.Unlock()
+	})
+	s := testStore{key: raw}
+	Use(s)
+	mu.Lock()
+	ttl = sessionTTL
+	mu.Unlock()
+	return s
+}
+
+func envelopeJSON(t *testing.T, env envelope) *string {
+	t.Helper()
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	return &s
+}
+
+func TestSetSessionKey(t *testing.T) {
+	for _, sessionTTL := range []time.Duration{0, time.Hour} {
+		raw := new(string)
+		useStore(t, raw, sessionTTL)
+		before := time.Now()
+		if err := SetSessionKey("c2Vzc2lvbg=="); err != nil {
+			t.Fatal(err)
+		}
+		var env envelope
+		if err := json.Unmarshal([]byte(*raw), &env); err != nil {
+			t.Fatalf("stored %q: %v", *raw, err)
+		}
+		if env.Key != "c2Vzc2lvbg==" || env.Created.Before(before) {
+			t.Errorf("ttl %v: stored %+v", sessionTTL, env)
+		}
+		if sessionTTL == 0 && !env.Expires.IsZero() {
+			t.Errorf("no ttl: stored expiry %v", env.Expires)
+		}
+		if sessionTTL > 0 && !env.Expires.Equal(env.Created.Add(sessionTTL)) {
+			t.Errorf("ttl %v: expires %v, created %v", sessionTTL, env.Expires, env.Created)
+		}
+		if got, err := GetSessionKey(); err != nil || got != "c2Vzc2lvbg==" {
+			t.Errorf("ttl %v: GetSessionKey = %q, %v", sessionTTL, got, err)
+		}
+	}
+}
+
+func TestGetSessionKey(t *testing.T) {
+	now := time.Now()
+	bare := "bare-legacy-key"
+	for _, tt := range []struct {
+		name       string
+		raw        *string
+		sessionTTL time.Duration
+		want       string
+		err        error
+	}{
+		{"no key", nil, 0, "", ErrSessionKeyNotFound},
+		{"bare key without ttl", &bare, 0, bare, nil},
+		{"bare key with ttl", &bare, time.Hour, "", ErrSessionExpired},
+		{"not yet expired", envelopeJSON(t, envelope{Key: "k", Created: now, Expires: now.Add(time.Minute)}), time.Hour, "k", nil},
+		{"expired", envelopeJSON(t, envelope{Key: "k", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)}), time.Hour, "", ErrSessionExpired},
+		// The expiry recorded with the key wins over the current setting.
+		{"expired after ttl was removed", envelopeJSON(t, envelope{Key: "k", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)}), 0, "", ErrSessionExpired},
+		{"zero expiry without ttl", envelopeJSON(t, envelope{Key: "k", Created: now.Add(-48 * time.Hour)}), 0, "k", nil},
+		{"zero expiry within ttl", envelopeJSON(t, envelope{Key: "k", Created: now.Add(-time.Minute)}), time.Hour, "k", nil},
+		{"zero expiry past ttl", envelopeJSON(t, envelope{Key: "k", Created: now.Add(-2 * time.Hour)}), time.Hour, "", ErrSessionExpired},
+		{"zero expiry and creation with ttl", envelopeJSON(t, envelope{Key: "k"}), time.Hour, "", ErrSessionExpired},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useStore(t, tt.raw, tt.sessionTTL)
+			got, err := GetSessionKey()
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("GetSessionKey = %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+			// An expired key stays until the caller has locked the vault.
+			if errors.Is(err, ErrSessionExpired) {
+				if raw, _ := s.Get(); raw == "" {
+					t.Error("expired key was removed from the store")
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteSessionKey(t *testing.T) {
+	raw := new(string)
+	useStore(t, raw, 0)
+	if err := SetSessionKey("k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteSessionKey(); err != nil {
+		t.Fatal(err)
+	}
+	if *raw != "" {
+		t.Errorf("store still holds %q", *raw)
+	}
+}
@@ -0,0 +1,42 @@
+package keychain
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringStore keeps the session key in the Secret Service (or the platform
+// keychain) through go-keyring.
+type keyringStore struct{}
+
+func (keyringStore) Get() (string, error) {
+	user, err := currentUser()
+	if err != nil {
+		return "", err
+	}
+	key, err := keyring.Get(ServiceName, user)
+	if errors.Is(err, keyring.ErrNotFound) || (err == nil && key == "") {
+		return "", ErrSessionKeyNotFound
+	}
+	return key, err
+}
+
+func (keyringStore) Set(key string) error {
+	user, err := currentUser()
+	if err != nil {
+		return err
+	}
+	return keyring.Set(ServiceName, user, key)
+}
+
+func (keyringStore) Delete() error {
+	user, err := currentUser()
+	if err != nil {
+		return err
+	}
+	if err := keyring.Delete(ServiceName, user); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	return nil
+}
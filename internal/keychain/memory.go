@@ -0,0 +1,24 @@
+package keychain
+
+import "github.com/netbrain/mnu/internal/serve"
+
+// memoryStore keeps the session key only in the memory of the running
+// `mnu-bw serve` daemon, so it is gone when the daemon stops.
+type memoryStore struct{}
+
+func (memoryStore) Get() (string, error) {
+	key, err := serve.GetSession()
+	if err == serve.ErrNoSession || err == serve.ErrNotRunning {
+		return "", ErrSessionKeyNotFound
+	}
+	return key, err
+}
+
+func (memoryStore) Set(key string) error { return serve.SetSession(key) }
+
+func (memoryStore) Delete() error {
+	if err := serve.DeleteSession(); err != nil && err != serve.ErrNotRunning {
+		return err
+	}
+	return nil
+}
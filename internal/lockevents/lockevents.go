@@ -0,0 +1,190 @@
+// Package lockevents reports when the user walks away from their session:
+// suspend, screen lock and logout, as announced on D-Bus by logind and by
+// screensavers.
+package lockevents
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	login1Name    = "org.freedesktop.login1"
+	login1Path    = "/org/freedesktop/login1"
+	login1Manager = "org.freedesktop.login1.Manager"
+	login1Session = "org.freedesktop.login1.Session"
+)
+
+// screensavers lists the interfaces whose ActiveChanged(true) means the
+// screen locked.
+var screensavers = []string{
+	"org.freedesktop.ScreenSaver",
+	"org.gnome.ScreenSaver",
+	"org.cinnamon.ScreenSaver",
+	"org.mate.ScreenSaver",
+}
+
+// Reasons passed to the callback of Watch.
+const (
+	Suspend    = "suspend"
+	ScreenLock = "screen lock"
+	Logout     = "logout"
+)
+
+// Watch calls onEvent with one of the reasons above whenever the system is
+// about to sleep, one of the user's sessions or screensavers locks, or the
+// user's last session ends. It returns once stop is closed, or at once when
+// neither the system nor the session bus can be reached. Either bus alone
+// is enough.
+func Watch(stop <-chan struct{}, onEvent func(reason string)) error {
+	// Each connection closes its own channel when it goes away, so they
+	// cannot share one. A nil channel never delivers.
+	var systemSignals, sessionSignals chan *dbus.Signal
+	var errs []error
+
+	system, err := dbus.ConnectSystemBus()
+	if err == nil {
+		defer system.Close()
+		systemSignals = make(chan *dbus.Signal, 16)
+		err = watchLogind(system, systemSignals)
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("system bus: %w", err))
+	}
+	session, err := dbus.ConnectSessionBus()
+	if err == nil {
+		defer session.Close()
+		sessionSignals = make(chan *dbus.Signal, 16)
+		err = watchScreensavers(session, sessionSignals)
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("session bus: %w", err))
+	}
+	if len(errs) == 2 {
+		return errors.Join(errs...)
+	}
+
+	for {
+		var sig *dbus.Signal
+		var ok bool
+		select {
+		case <-stop:
+			return nil
+		case sig, ok = <-systemSignals:
+			if !ok {
+				systemSignals = nil
+				continue
+			}
+		case sig, ok = <-sessionSignals:
+			if !ok {
+				sessionSignals = nil
+				continue
+			}
+		}
+		if reason := classify(system, sig); reason != "" {
+			onEvent(reason)
+		}
+	}
+}
+
+func watchLogind(conn *dbus.Conn, signals chan<- *dbus.Signal) error {
+	matches := [][]dbus.MatchOption{
+		{dbus.WithMatchInterface(login1Manager), dbus.WithMatchMember("PrepareForSleep")},
+		{dbus.WithMatchInterface(login1Manager), dbus.WithMatchMember("SessionRemoved")},
+		{dbus.WithMatchInterface(login1Session), dbus.WithMatchMember("Lock")},
+	}
+	for _, m := range matches {
+		m = append(m, dbus.WithMatchSender(login1Name))
+		if err := conn.AddMatchSignal(m...); err != nil {
+			return err
+		}
+	}
+	conn.Signal(signals)
+	return nil
+}
+
+func watchScreensavers(conn *dbus.Conn, signals chan<- *dbus.Signal) error {
+	for _, iface := range screensavers {
+		if err := conn.AddMatchSignal(dbus.WithMatchInterface(iface), dbus.WithMatchMember("ActiveChanged")); err != nil {
+			return err
+		}
+	}
+	conn.Signal(signals)
+	return nil
+}
+
+// classify maps a signal to a reason, or "" when it does not concern us.
+func classify(system *dbus.Conn, sig *dbus.Signal) string {
+	switch sig.Name {
+	case login1Manager + ".PrepareForSleep":
+		if starting, _ := firstBool(sig); starting {
+			return Suspend
+		}
+	case login1Session + ".Lock":
+		if ownSession(system, sig.Path) {
+			return ScreenLock
+		}
+	case login1Manager + ".SessionRemoved":
+		if !hasSessions(system) {
+			return Logout
+		}
+	default:
+		for _, iface := range screensavers {
+			if sig.Name == iface+".ActiveChanged" {
+				if active, _ := firstBool(sig); active {
+					return ScreenLock
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func firstBool(sig *dbus.Signal) (bool, bool) {
+	if len(sig.Body) == 0 {
+		return false, false
+	}
+	b, ok := sig.Body[0].(bool)
+	return b, ok
+}
+
+// ownSession reports whether the session at path belongs to the current
+// user. When logind cannot tell, it errs on the side of locking.
+func ownSession(conn *dbus.Conn, path dbus.ObjectPath) bool {
+	v, err := conn.Object(login1Name, path).GetProperty(login1Session + ".User")
+	if err != nil {
+		return true
+	}
+	var user struct {
+		UID  uint32
+		Path dbus.ObjectPath
+	}
+	if err := dbus.Store([]interface{}{v.Value()}, &user); err != nil {
+		return true
+	}
+	return int(user.UID) == os.Getuid()
+}
+
+// hasSessions reports whether the current user still has a session. When
+// logind cannot tell, it errs on the side of locking.
+func hasSessions(conn *dbus.Conn) bool {
+	var sessions []struct {
+		ID   string
+		UID  uint32
+		User string
+		Seat string
+		Path dbus.ObjectPath
+	}
+	if err := conn.Object(login1Name, login1Path).Call(login1Manager+".ListSessions", 0).Store(&sessions); err != nil {
+		return false
+	}
+	for _, s := range sessions {
+		if int(s.UID) == os.Getuid() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package lockevents
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestClassify(t *testing.T) {
+	signal := func(name string, body ...interface{}) *dbus.Signal {
+		return &dbus.Signal{Name: name, Path: "/", Body: body}
+	}
+	type test struct {
+		name string
+		sig  *dbus.Signal
+		want string
+	}
+	tests := []test{
+		{"going to sleep", signal(login1Manager+".PrepareForSleep", true), Suspend},
+		{"waking up", signal(login1Manager+".PrepareForSleep", false), ""},
+		{"sleep without a body", signal(login1Manager + ".PrepareForSleep"), ""},
+		{"sleep with a non-bool body", signal(login1Manager+".PrepareForSleep", "true"), ""},
+		{"unrelated member", signal("org.freedesktop.ScreenSaver.WakeUpScreen", true), ""},
+		{"unrelated interface", signal("org.example.ScreenSaver.ActiveChanged", true), ""},
+	}
+	for _, iface := range screensavers {
+		tests = append(tests,
+			test{iface + " activated", signal(iface+".ActiveChanged", true), ScreenLock},
+			test{iface + " deactivated", signal(iface+".ActiveChanged", false), ""},
+		)
+	}
+	for _, tt := range tests {
+		// None of these consult logind, so no system bus is needed.
+		if got := classify(nil, tt.sig); got != tt.want {
+			t.Errorf("%s: classify = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a dbus-daemon of its own for the test and returns its
+// address.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(strings.Replace(busConfig, "%s", filepath.Join(dir, "bus"), 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("dbus-daemon", "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon printed no address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+type logindSession struct {
+	ID   string
+	UID  uint32
+	User string
+	Seat string
+	Path dbus.ObjectPath
+}
+
+// fakeLogind answers the calls classify makes to logind.
+type fakeLogind struct {
+	mu       sync.Mutex
+	sessions []logindSession
+}
+
+func (l *fakeLogind) ListSessions() ([]logindSession, *dbus.Error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sessions, nil
+}
+
+// sessionProps serves the User property of one session.
+type sessionProps struct {
+	uid  uint32
+	path dbus.ObjectPath
+}
+
+func (p sessionProps) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	if iface != login1Session || name != "User" {
+		return dbus.Variant{}, dbus.MakeFailedError(os.ErrNotExist)
+	}
+	return dbus.MakeVariant(struct {
+		UID  uint32
+		Path dbus.ObjectPath
+	}{p.uid, "/org/freedesktop/login1/user/_" + p.path}), nil
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWatchOverDBus(t *testing.T) {
+	// One daemon stands in for both the system and the session bus.
+	addr := privateBus(t)
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", addr)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", addr)
+
+	uid := uint32(os.Getuid())
+	const (
+		ownPath   = dbus.ObjectPath(login1Path + "/session/c1")
+		otherPath = dbus.ObjectPath(login1Path + "/session/c2")
+	)
+	logind := &fakeLogind{sessions: []logindSession{
+		{"c1", uid, "me", "seat0", ownPath},
+		{"c2", uid + 1, "other", "seat0", otherPath},
+	}}
+	login1 := connect(t, addr)
+	if reply, err := login1.RequestName(login1Name, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("RequestName(%s) = %v, %v", login1Name, reply, err)
+	}
+	for _, export := range []struct {
+		v     interface{}
+		path  dbus.ObjectPath
+		iface string
+	}{
+		{logind, login1Path, login1Manager},
+		{sessionProps{uid, ownPath}, ownPath, "org.freedesktop.DBus.Properties"},
+		{sessionProps{uid + 1, otherPath}, otherPath, "org.freedesktop.DBus.Properties"},
+	} {
+		if err := login1.Export(export.v, export.path, export.iface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	screensaver := connect(t, addr)
+
+	events := make(chan string, 16)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- Watch(stop, func(reason string) { events <- reason }) }()
+	t.Cleanup(func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Errorf("Watch: %v", err)
+		}
+	})
+
+	emit := func(conn *dbus.Conn, path dbus.ObjectPath, name string, body ...interface{}) {
+		t.Helper()
+		if err := conn.Emit(path, name, body...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := func() string {
+		t.Helper()
+		select {
+		case reason := <-events:
+			return reason
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event within 5s")
+			return ""
+		}
+	}
+
+	// Watch subscribes in the background; signal until both buses are
+	// heard, then drop the surplus.
+	waitFor := func(conn *dbus.Conn, path dbus.ObjectPath, name string, want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			emit(conn, path, name, true)
+			select {
+			case reason := <-events:
+				if reason != want {
+					t.Fatalf("got %q while waiting for %q", reason, want)
+				}
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+		t.Fatalf("Watch never reported %q", want)
+	}
+	waitFor(login1, login1Path, login1Manager+".PrepareForSleep", Suspend)
+	waitFor(screensaver, "/org/freedesktop/ScreenSaver", "org.freedesktop.ScreenSaver.ActiveChanged", ScreenLock)
+	time.Sleep(100 * time.Millisecond)
+	for len(events) > 0 {
+		<-events
+	}
+
+	// Each step sends signals that must be ignored followed by one that
+	// must not; the next event has to come from the latter.
+	type step struct {
+		name    string
+		signals func()
+		want    string
+	}
+	steps := []step{
+		{"suspend", func() {
+			emit(login1, login1Path, login1Manager+".PrepareForSleep", false)
+			emit(login1, login1Path, login1Manager+".PrepareForSleep", true)
+		}, Suspend},
+		{"session lock", func() {
+			emit(login1, otherPath, login1Session+".Lock")
+			emit(login1, ownPath, login1Session+".Lock")
+		}, ScreenLock},
+		// Watch asks logind for the sessions left only once it handles the
+		// signal, so the sessions change after the first one is through.
+		{"another user's session ends", func() {
+			emit(login1, login1Path, login1Manager+".SessionRemoved", "c2", otherPath)
+			emit(login1, login1Path, login1Manager+".PrepareForSleep", true)
+		}, Suspend},
+		{"logout", func() {
+			logind.mu.Lock()
+			logind.sessions = logind.sessions[1:]
+			logind.mu.Unlock()
+			emit(login1, login1Path, login1Manager+".SessionRemoved", "c1", ownPath)
+		}, Logout},
+		{"logind signal from another sender", func() {
+			emit(screensaver, login1Path, login1Manager+".PrepareForSleep", true)
+			emit(login1, ownPath, login1Session+".Lock")
+		}, ScreenLock},
+	}
+	for _, iface := range screensavers {
+		path := dbus.ObjectPath("/" + strings.ReplaceAll(iface, ".", "/"))
+		steps = append(steps, step{iface, func() {
+			emit(screensaver, path, iface+".ActiveChanged", false)
+			emit(screensaver, path, iface+".ActiveChanged", true)
+		}, ScreenLock})
+	}
+	for _, st := range steps {
+		st.signals()
+		if got := next(); got != st.want {
+			t.Errorf("%s: got %q, want %q", st.name, got, st.want)
+		}
+	}
+	select {
+	case reason := <-events:
+		t.Errorf("unexpected trailing event %q", reason)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,245 @@
+// Package pin wraps a Bitwarden session key with a key derived from a short
+// PIN, so an expired or deleted session can be resumed without the master
+// password.
+//
+// The wrapped key lives in ~/.config/mnu/pin.json. It is wiped after too many
+// wrong PINs and ignored (and wiped) once it expires. Anyone able to copy the
+// file can attack the PIN offline at Argon2id speed, so the expiry is what
+// bounds the exposure; the master password remains the real secret.
+package pin
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/netbrain/mnu/internal/util"
+	"golang.org/x/crypto/argon2"
+)
+
+// MinLength is the shortest PIN accepted by Set.
+const MinLength = 4
+
+// Argon2id parameters for new files; the ones used are stored in the file.
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+// Bounds on the Argon2id parameters read from a file. Zero time or threads
+// make argon2 panic and a huge memory cost would exhaust RAM, so a file
+// outside them is treated as corrupt.
+const (
+	maxArgonTime    = 16
+	maxArgonMemory  = 1024 * 1024 // 1 GiB
+	maxArgonThreads = 64
+)
+
+// aad binds the ciphertext to this file format.
+var aad = []byte("mnu-pin-v1")
+
+var (
+	// ErrNoPIN means no PIN is set (or it expired or was wiped).
+	ErrNoPIN = errors.New("no PIN set")
+	// ErrTooShort is returned by Set for PINs shorter than MinLength.
+	ErrTooShort = fmt.Errorf("PIN must be at least %d characters", MinLength)
+	// ErrWiped means the last allowed attempt failed and the PIN is gone.
+	ErrWiped = errors.New("too many wrong PINs; PIN unlock disabled")
+)
+
+// WrongPINError is returned by Unlock for a wrong PIN with attempts left.
+type WrongPINError struct{ Remaining int }
+
+func (e *WrongPINError) Error() string {
+	return fmt.Sprintf("wrong PIN (%d attempt(s) left)", e.Remaining)
+}
+
+type file struct {
+	Salt        []byte    `json:"salt"`
+	Nonce       []byte    `json:"nonce"`
+	Ciphertext  []byte    `json:"ciphertext"`
+	Time        uint32    `json:"time"`
+	Memory      uint32    `json:"memory"`
+	Threads     uint8     `json:"threads"`
+	Expires     time.Time `json:"expires"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"maxAttempts"`
+}
+
+func path() (string, error) {
+	dir, err := util.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pin.json"), nil
+}
+
+// Available reports whether a PIN is set and has not expired. An expired
+// file is wiped.
+func Available() bool {
+	f, err := load()
+	if err != nil {
+		return false
+	}
+	if !time.Now().Before(f.Expires) {
+		Wipe()
+		return false
+	}
+	return true
+}
+
+// Set wraps sessionKey with pin. The PIN stops working after ttl or after
+// maxAttempts wrong tries in a row.
+func Set(pin, sessionKey string, ttl time.Duration, maxAttempts int) error {
+	if len(pin) < MinLength {
+		return ErrTooShort
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	f := file{
+		Salt:        make([]byte, 16),
+		Time:        argonTime,
+		Memory:      argonMemory,
+		Threads:     argonThreads,
+		Expires:     time.Now().Add(ttl),
+		MaxAttempts: maxAttempts,
+	}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := f.aead(pin)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, []byte(sessionKey), aad)
+	return save(f)
+}
+
+// Unlock returns the session key wrapped with pin. A wrong PIN counts as an
+// attempt; the last allowed one wipes the file and returns ErrWiped.
+func Unlock(pin string) (string, error) {
+	f, err := load()
+	if err != nil {
+		return "", err
+	}
+	if !time.Now().Before(f.Expires) {
+		Wipe()
+		return "", ErrNoPIN
+	}
+	aead, err := f.aead(pin)
+	if err != nil {
+		return "", err
+	}
+	key, err := aead.Open(nil, f.Nonce, f.Ciphertext, aad)
+	if err != nil {
+		f.Attempts++
+		if f.Attempts >= f.MaxAttempts {
+			Wipe()
+			return "", ErrWiped
+		}
+		if err := save(f); err != nil {
+			return "", err
+		}
+		return "", &WrongPINError{Remaining: f.MaxAttempts - f.Attempts}
+	}
+	if f.Attempts > 0 {
+		f.Attempts = 0
+		_ = save(f)
+	}
+	return string(key), nil
+}
+
+// Wipe removes the wrapped session key.
+func Wipe() error {
+	p, err := path()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f file) aead(pin string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(pin), f.Salt, f.Time, f.Memory, f.Threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func load() (file, error) {
+	var f file
+	p, err := path()
+	if err != nil {
+		return f, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return f, ErrNoPIN
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		Wipe()
+		return f, fmt.Errorf("invalid PIN file: %w", err)
+	}
+	if err := f.validate(); err != nil {
+		Wipe()
+		return f, fmt.Errorf("invalid PIN file: %w", err)
+	}
+	return f, nil
+}
+
+// validate checks what aead and Unlock rely on, so a corrupt or tampered
+// file cannot make them panic or allocate without bound.
+func (f file) validate() error {
+	switch {
+	case f.Time < 1 || f.Time > maxArgonTime:
+		return fmt.Errorf("argon2 time %d out of range", f.Time)
+	case f.Threads < 1 || f.Threads > maxArgonThreads:
+		return fmt.Errorf("argon2 threads %d out of range", f.Threads)
+	case f.Memory < 8*uint32(f.Threads) || f.Memory > maxArgonMemory:
+		return fmt.Errorf("argon2 memory %d KiB out of range", f.Memory)
+	case len(f.Salt) < 8:
+		return errors.New("salt too short")
+	case len(f.Nonce) != 12:
+		return errors.New("bad nonce length")
+	case f.MaxAttempts < 1:
+		return errors.New("no attempts allowed")
+	}
+	return nil
+}
+
+// save writes the file atomically so a crash cannot reset the attempt count
+// to a stale value or leave a truncated file behind.
+func save(f file) error {
+	p, err := path()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
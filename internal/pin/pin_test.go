@@ -0,0 +1,210 @@
+package pin
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// useTempConfig points the config directory at a fresh temp dir.
+// util.GetConfigDir derives it from HOME; XDG_CONFIG_HOME is set as well so
+// nothing reaches the real config should that change.
+func useTempConfig(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home+"/.config")
+	p, err := path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func readFile(t *testing.T, p string) file {
+	t.Helper()
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func writeFile(t *testing.T, p string, f file) {
+	t.Helper()
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func gone(p string) bool {
+	_, err := os.Stat(p)
+	return os.IsNotExist(err)
+}
+
+func TestRoundTrip(t *testing.T) {
+	p := useTempConfig(t)
+	if Available() {
+		t.Fatal("Available() before Set")
+	}
+	if _, err := Unlock("1234"); !errors.Is(err, ErrNoPIN) {
+		t.Fatalf("Unlock before Set: %v, want ErrNoPIN", err)
+	}
+	if err := Set("12", "session", time.Hour, 3); !errors.Is(err, ErrTooShort) {
+		t.Errorf("Set with a short PIN: %v, want ErrTooShort", err)
+	}
+
+	if err := Set("1234", "c2Vzc2lvbiBrZXk=", time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(p); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("pin.json: %v, %v, want mode 0600", fi, err)
+	}
+	if !Available() {
+		t.Fatal("Available() = false after Set")
+	}
+	got, err := Unlock("1234")
+	if err != nil || got != "c2Vzc2lvbiBrZXk=" {
+		t.Fatalf("Unlock = %q, %v", got, err)
+	}
+	// Unlocking does not use the PIN up.
+	if got, err := Unlock("1234"); err != nil || got != "c2Vzc2lvbiBrZXk=" {
+		t.Fatalf("second Unlock = %q, %v", got, err)
+	}
+
+	if err := Wipe(); err != nil {
+		t.Fatal(err)
+	}
+	if Available() || !gone(p) {
+		t.Error("PIN still available after Wipe")
+	}
+	if err := Wipe(); err != nil {
+		t.Errorf("Wipe without a file: %v", err)
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	p := useTempConfig(t)
+	if err := Set("1234", "session", time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+	wrong := func(want int) {
+		t.Helper()
+		var werr *WrongPINError
+		if _, err := Unlock("0000"); !errors.As(err, &werr) || werr.Remaining != want {
+			t.Fatalf("wrong PIN: %v, want %d attempt(s) left", err, want)
+		}
+	}
+
+	wrong(2)
+	wrong(1)
+	if got := readFile(t, p).Attempts; got != 2 {
+		t.Errorf("file records %d attempts, want 2", got)
+	}
+	// The right PIN resets the counter.
+	if _, err := Unlock("1234"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, p).Attempts; got != 0 {
+		t.Errorf("file records %d attempts after a good PIN, want 0", got)
+	}
+
+	wrong(2)
+	wrong(1)
+	if _, err := Unlock("0000"); !errors.Is(err, ErrWiped) {
+		t.Fatalf("last attempt: %v, want ErrWiped", err)
+	}
+	if !gone(p) {
+		t.Error("pin.json left behind after the last attempt")
+	}
+	if _, err := Unlock("1234"); !errors.Is(err, ErrNoPIN) {
+		t.Errorf("right PIN after wipe: %v, want ErrNoPIN", err)
+	}
+}
+
+func TestSingleAttempt(t *testing.T) {
+	p := useTempConfig(t)
+	// maxAttempts below one still allows one try.
+	if err := Set("1234", "session", time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unlock("0000"); !errors.Is(err, ErrWiped) || !gone(p) {
+		t.Errorf("wrong PIN with one attempt: %v, want ErrWiped and no file", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	p := useTempConfig(t)
+	if err := Set("1234", "session", time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+	f := readFile(t, p)
+	f.Expires = time.Now().Add(-time.Second)
+	writeFile(t, p, f)
+	if _, err := Unlock("1234"); !errors.Is(err, ErrNoPIN) {
+		t.Errorf("Unlock after expiry: %v, want ErrNoPIN", err)
+	}
+	if !gone(p) {
+		t.Error("expired pin.json left behind by Unlock")
+	}
+
+	if err := Set("1234", "session", -time.Second, 3); err != nil {
+		t.Fatal(err)
+	}
+	if Available() {
+		t.Error("Available() = true after expiry")
+	}
+	if !gone(p) {
+		t.Error("expired pin.json left behind by Available")
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	p := useTempConfig(t)
+	if err := Set("1234", "session", time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+	good := readFile(t, p)
+	for _, tt := range []struct {
+		name   string
+		modify func(f *file)
+	}{
+		{"zero time", func(f *file) { f.Time = 0 }},
+		{"huge time", func(f *file) { f.Time = 1 << 30 }},
+		{"zero threads", func(f *file) { f.Threads = 0 }},
+		{"huge threads", func(f *file) { f.Threads = 255 }},
+		{"zero memory", func(f *file) { f.Memory = 0 }},
+		{"memory below 8 KiB per thread", func(f *file) { f.Memory = 8*uint32(f.Threads) - 1 }},
+		{"huge memory", func(f *file) { f.Memory = 1 << 31 }},
+		{"no salt", func(f *file) { f.Salt = nil }},
+		{"short nonce", func(f *file) { f.Nonce = f.Nonce[:8] }},
+		{"no attempts", func(f *file) { f.MaxAttempts = 0 }},
+	} {
+		f := good
+		tt.modify(&f)
+		writeFile(t, p, f)
+		if _, err := Unlock("1234"); err == nil || errors.Is(err, ErrNoPIN) {
+			t.Errorf("%s: Unlock = %v, want an invalid file error", tt.name, err)
+		}
+		if !gone(p) {
+			t.Errorf("%s: file not wiped", tt.name)
+		}
+	}
+
+	if err := os.WriteFile(p, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if Available() || !gone(p) {
+		t.Error("corrupt JSON: Available() = true or file not wiped")
+	}
+}
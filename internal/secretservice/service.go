@@ -0,0 +1,588 @@
+// Package secretservice implements the freedesktop Secret Service API
+// (org.freedesktop.secrets) on top of a Bitwarden folder.
+//
+// The service exposes a single collection, also reachable as the "default"
+// alias. It never locks on its own: it runs while the vault is unlocked and
+// every object reports itself unlocked, so no prompts are ever needed.
+package secretservice
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/netbrain/mnu/internal/keychain"
+)
+
+const (
+	busName = "org.freedesktop.secrets"
+
+	servicePath      dbus.ObjectPath = "/org/freedesktop/secrets"
+	collectionPath   dbus.ObjectPath = "/org/freedesktop/secrets/collection/mnu"
+	defaultAliasPath dbus.ObjectPath = "/org/freedesktop/secrets/aliases/default"
+	sessionPrefix                    = "/org/freedesktop/secrets/session/s"
+
+	// noPrompt is returned wherever the spec allows a prompt object.
+	noPrompt dbus.ObjectPath = "/"
+
+	ifaceService    = "org.freedesktop.Secret.Service"
+	ifaceCollection = "org.freedesktop.Secret.Collection"
+	ifaceItem       = "org.freedesktop.Secret.Item"
+	ifaceSession    = "org.freedesktop.Secret.Session"
+	ifaceProperties = "org.freedesktop.DBus.Properties"
+
+	errNoSession        = "org.freedesktop.Secret.Error.NoSession"
+	errNoSuchObject     = "org.freedesktop.Secret.Error.NoSuchObject"
+	errNotSupported     = "org.freedesktop.DBus.Error.NotSupported"
+	errFailed           = "org.freedesktop.DBus.Error.Failed"
+	errInvalidArgs      = "org.freedesktop.DBus.Error.InvalidArgs"
+	errUnknownProperty  = "org.freedesktop.DBus.Error.UnknownProperty"
+	errPropertyReadOnly = "org.freedesktop.DBus.Error.PropertyReadOnly"
+)
+
+// ErrNameTaken is returned by Register when another provider, such as
+// gnome-keyring or KeePassXC, already owns the bus name.
+var ErrNameTaken = errors.New("another Secret Service provider owns " + busName)
+
+// Service serves a Store on a D-Bus connection.
+type Service struct {
+	conn  *dbus.Conn
+	store *Store
+	label string
+
+	mu          sync.Mutex
+	sessions    map[dbus.ObjectPath]*session
+	nextSession int
+	items       map[dbus.ObjectPath]string
+}
+
+// New returns a service for store; label is the collection's display name.
+func New(conn *dbus.Conn, store *Store, label string) *Service {
+	return &Service{
+		conn:     conn,
+		store:    store,
+		label:    label,
+		sessions: map[dbus.ObjectPath]*session{},
+		items:    map[dbus.ObjectPath]string{},
+	}
+}
+
+// Register exports all objects and claims the bus name. With replace set an
+// existing owner that allows replacement is taken over.
+func (s *Service) Register(replace bool) error {
+	if err := s.export(servicePath, ifaceService, serviceObject{s}, s.serviceProps()); err != nil {
+		return err
+	}
+	for _, p := range []dbus.ObjectPath{collectionPath, defaultAliasPath} {
+		if err := s.export(p, ifaceCollection, collectionObject{s}, s.collectionProps()); err != nil {
+			return err
+		}
+	}
+	items, err := s.store.Search(nil)
+	if err != nil {
+		return err
+	}
+	if _, err := s.itemPaths(items); err != nil {
+		return err
+	}
+	if err := s.watchClients(); err != nil {
+		return err
+	}
+
+	flags := dbus.NameFlagDoNotQueue
+	if replace {
+		flags |= dbus.NameFlagReplaceExisting
+	}
+	reply, err := s.conn.RequestName(busName, flags)
+	if err != nil {
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return ErrNameTaken
+	}
+	return nil
+}
+
+// watchClients closes the sessions of clients that leave the bus.
+func (s *Service) watchClients() error {
+	if err := s.conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+	); err != nil {
+		return err
+	}
+	ch := make(chan *dbus.Signal, 16)
+	s.conn.Signal(ch)
+	go func() {
+		for sig := range ch {
+			if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(sig.Body) != 3 {
+				continue
+			}
+			name, _ := sig.Body[0].(string)
+			newOwner, _ := sig.Body[2].(string)
+			if newOwner != "" || !strings.HasPrefix(name, ":") {
+				continue
+			}
+			s.mu.Lock()
+			for path, sess := range s.sessions {
+				if sess.owner == name {
+					s.closeSession(path)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// export publishes obj under iface at path, along with its properties and
+// introspection data.
+func (s *Service) export(path dbus.ObjectPath, iface string, obj interface{}, props properties) error {
+	if err := s.conn.Export(obj, path, iface); err != nil {
+		return err
+	}
+	if err := s.conn.Export(props, path, ifaceProperties); err != nil {
+		return err
+	}
+	var ps []introspect.Property
+	for name, v := range props.get() {
+		access := "read"
+		if props.writable[name] {
+			access = "readwrite"
+		}
+		ps = append(ps, introspect.Property{Name: name, Type: v.Signature().String(), Access: access})
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Name < ps[j].Name })
+	node := &introspect.Node{
+		Name: string(path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: iface, Methods: introspect.Methods(obj), Properties: ps},
+		},
+	}
+	return s.conn.Export(introspect.NewIntrospectable(node), path, "org.freedesktop.DBus.Introspectable")
+}
+
+func (s *Service) unexport(path dbus.ObjectPath, iface string) {
+	s.conn.Export(nil, path, iface)
+	s.conn.Export(nil, path, ifaceProperties)
+	s.conn.Export(nil, path, "org.freedesktop.DBus.Introspectable")
+}
+
+func itemPath(id string) dbus.ObjectPath {
+	return collectionPath + "/" + dbus.ObjectPath(strings.ReplaceAll(id, "-", "_"))
+}
+
+// itemPaths returns the object paths of items, exporting the ones seen for
+// the first time.
+func (s *Service) itemPaths(items []*Item) ([]dbus.ObjectPath, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := make([]dbus.ObjectPath, 0, len(items))
+	for _, it := range items {
+		p := itemPath(it.ID)
+		if _, ok := s.items[p]; !ok {
+			obj := itemObject{s: s, id: it.ID}
+			if err := s.export(p, ifaceItem, obj, s.itemProps(it.ID)); err != nil {
+				return nil, err
+			}
+			s.items[p] = it.ID
+		}
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return paths, nil
+}
+
+// item looks up the item exported at path.
+func (s *Service) item(path dbus.ObjectPath) (*Item, *dbus.Error) {
+	s.mu.Lock()
+	id, ok := s.items[path]
+	s.mu.Unlock()
+	if ok {
+		if it, ok := s.store.Get(id); ok {
+			return it, nil
+		}
+	}
+	return nil, dbus.NewError(errNoSuchObject, []interface{}{"no such item " + string(path)})
+}
+
+// session returns the caller's session at path.
+func (s *Service) session(sender dbus.Sender, path dbus.ObjectPath) (*session, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[path]
+	if !ok || sess.owner != string(sender) {
+		return nil, dbus.NewError(errNoSession, []interface{}{"no such session " + string(path)})
+	}
+	return sess, nil
+}
+
+// closeSession must be called with s.mu held.
+func (s *Service) closeSession(path dbus.ObjectPath) {
+	delete(s.sessions, path)
+	s.unexport(path, ifaceSession)
+}
+
+func (s *Service) emit(signal string, path dbus.ObjectPath) {
+	s.conn.Emit(collectionPath, ifaceCollection+"."+signal, path)
+}
+
+func failed(err error) *dbus.Error {
+	return dbus.NewError(errFailed, []interface{}{err.Error()})
+}
+
+// properties implements org.freedesktop.DBus.Properties for one interface.
+// Values are computed on every call, so they never go stale.
+type properties struct {
+	iface    string
+	get      func() map[string]dbus.Variant
+	writable map[string]bool
+	set      func(name string, v dbus.Variant) *dbus.Error
+}
+
+func (p properties) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	if v, ok := p.get()[name]; ok && iface == p.iface {
+		return v, nil
+	}
+	return dbus.Variant{}, dbus.NewError(errUnknownProperty, []interface{}{iface + "." + name})
+}
+
+func (p properties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	if iface != p.iface && iface != "" {
+		return map[string]dbus.Variant{}, nil
+	}
+	return p.get(), nil
+}
+
+func (p properties) Set(iface, name string, v dbus.Variant) *dbus.Error {
+	if iface != p.iface {
+		return dbus.NewError(errUnknownProperty, []interface{}{iface + "." + name})
+	}
+	if !p.writable[name] {
+		return dbus.NewError(errPropertyReadOnly, []interface{}{iface + "." + name})
+	}
+	return p.set(name, v)
+}
+
+func (s *Service) serviceProps() properties {
+	return properties{
+		iface: ifaceService,
+		get: func() map[string]dbus.Variant {
+			return map[string]dbus.Variant{
+				"Collections": dbus.MakeVariant([]dbus.ObjectPath{collectionPath}),
+			}
+		},
+	}
+}
+
+func (s *Service) collectionProps() properties {
+	return properties{
+		iface: ifaceCollection,
+		get: func() map[string]dbus.Variant {
+			items, _ := s.store.Search(nil)
+			paths, _ := s.itemPaths(items)
+			if paths == nil {
+				paths = []dbus.ObjectPath{}
+			}
+			return map[string]dbus.Variant{
+				"Items":    dbus.MakeVariant(paths),
+				"Label":    dbus.MakeVariant(s.label),
+				"Locked":   dbus.MakeVariant(false),
+				"Created":  dbus.MakeVariant(uint64(0)),
+				"Modified": dbus.MakeVariant(uint64(0)),
+			}
+		},
+	}
+}
+
+func (s *Service) itemProps(id string) properties {
+	return properties{
+		iface: ifaceItem,
+		get: func() map[string]dbus.Variant {
+			it, ok := s.store.Get(id)
+			if !ok {
+				it = &Item{}
+			}
+			attrs := it.Attributes
+			if attrs == nil {
+				attrs = map[string]string{}
+			}
+			return map[string]dbus.Variant{
+				"Locked":     dbus.MakeVariant(false),
+				"Attributes": dbus.MakeVariant(attrs),
+				"Label":      dbus.MakeVariant(it.Label),
+				"Created":    dbus.MakeVariant(unixTime(it.Created)),
+				"Modified":   dbus.MakeVariant(unixTime(it.Modified)),
+			}
+		},
+		writable: map[string]bool{"Attributes": true, "Label": true},
+		set: func(name string, v dbus.Variant) *dbus.Error {
+			var update func(*Item)
+			switch name {
+			case "Label":
+				label, ok := v.Value().(string)
+				if !ok {
+					return dbus.NewError(errInvalidArgs, []interface{}{"Label must be a string"})
+				}
+				update = func(it *Item) { it.Label = label }
+			case "Attributes":
+				attrs, ok := v.Value().(map[string]string)
+				if !ok {
+					return dbus.NewError(errInvalidArgs, []interface{}{"Attributes must be a{ss}"})
+				}
+				update = func(it *Item) { it.Attributes = attrs }
+			}
+			if err := s.store.Update(id, update); err != nil {
+				return failed(err)
+			}
+			s.emit("ItemChanged", itemPath(id))
+			return nil
+		},
+	}
+}
+
+func unixTime(t time.Time) uint64 {
+	if u := t.Unix(); u > 0 {
+		return uint64(u)
+	}
+	return 0
+}
+
+// serviceObject implements org.freedesktop.Secret.Service.
+type serviceObject struct{ s *Service }
+
+func (o serviceObject) OpenSession(sender dbus.Sender, algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	s := o.s
+	s.mu.Lock()
+	s.nextSession++
+	path := dbus.ObjectPath(sessionPrefix + strconv.Itoa(s.nextSession))
+	s.mu.Unlock()
+
+	sess := &session{path: path, owner: string(sender)}
+	output, err := sess.negotiate(algorithm, input)
+	if err != nil {
+		return dbus.MakeVariant(""), noPrompt, dbus.NewError(errNotSupported, []interface{}{err.Error()})
+	}
+	if err := s.conn.Export(sessionObject{s: s, path: path}, path, ifaceSession); err != nil {
+		return dbus.MakeVariant(""), noPrompt, failed(err)
+	}
+	s.mu.Lock()
+	s.sessions[path] = sess
+	s.mu.Unlock()
+	return output, path, nil
+}
+
+// CreateCollection hands back the one collection there is; the vault folder
+// is the only place secrets go.
+func (o serviceObject) CreateCollection(properties map[string]dbus.Variant, alias string) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	return collectionPath, noPrompt, nil
+}
+
+func (o serviceObject) SearchItems(attributes map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	items, err := o.s.store.Search(attributes)
+	if err != nil {
+		return nil, nil, failed(err)
+	}
+	paths, err := o.s.itemPaths(items)
+	if err != nil {
+		return nil, nil, failed(err)
+	}
+	return paths, []dbus.ObjectPath{}, nil
+}
+
+func (o serviceObject) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	return objects, noPrompt, nil
+}
+
+// Lock locks nothing: the service only lives while the vault is unlocked.
+func (o serviceObject) Lock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	return []dbus.ObjectPath{}, noPrompt, nil
+}
+
+func (o serviceObject) GetSecrets(sender dbus.Sender, items []dbus.ObjectPath, session dbus.ObjectPath) (map[dbus.ObjectPath]Secret, *dbus.Error) {
+	sess, derr := o.s.session(sender, session)
+	if derr != nil {
+		return nil, derr
+	}
+	out := map[dbus.ObjectPath]Secret{}
+	for _, p := range items {
+		it, derr := o.s.item(p)
+		if derr != nil {
+			continue
+		}
+		secret, err := sess.encrypt(it.Secret, it.ContentType)
+		if err != nil {
+			return nil, failed(err)
+		}
+		out[p] = secret
+	}
+	return out, nil
+}
+
+func (o serviceObject) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	if name == "default" {
+		return collectionPath, nil
+	}
+	return noPrompt, nil
+}
+
+func (o serviceObject) SetAlias(name string, collection dbus.ObjectPath) *dbus.Error {
+	if name == "default" && collection == collectionPath {
+		return nil
+	}
+	return dbus.NewError(errNotSupported, []interface{}{"aliases cannot be changed"})
+}
+
+// collectionObject implements org.freedesktop.Secret.Collection.
+type collectionObject struct{ s *Service }
+
+// Delete refuses: the collection is the vault folder.
+func (o collectionObject) Delete() (dbus.ObjectPath, *dbus.Error) {
+	return noPrompt, dbus.NewError(errNotSupported, []interface{}{"the collection cannot be deleted"})
+}
+
+func (o collectionObject) SearchItems(attributes map[string]string) ([]dbus.ObjectPath, *dbus.Error) {
+	unlocked, _, derr := serviceObject(o).SearchItems(attributes)
+	return unlocked, derr
+}
+
+func (o collectionObject) CreateItem(sender dbus.Sender, properties map[string]dbus.Variant, secret Secret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s := o.s
+	sess, derr := s.session(sender, secret.Session)
+	if derr != nil {
+		return noPrompt, noPrompt, derr
+	}
+	value, err := sess.decrypt(secret)
+	if err != nil {
+		return noPrompt, noPrompt, dbus.NewError(errInvalidArgs, []interface{}{err.Error()})
+	}
+	var label string
+	if v, ok := properties[ifaceItem+".Label"]; ok {
+		label, _ = v.Value().(string)
+	}
+	attrs := map[string]string{}
+	if v, ok := properties[ifaceItem+".Attributes"]; ok {
+		if a, ok := v.Value().(map[string]string); ok {
+			attrs = a
+		}
+	}
+	// mnu's own session key must not end up inside the vault it unlocks.
+	if attrs["service"] == keychain.ServiceName {
+		return noPrompt, noPrompt, dbus.NewError(errNotSupported, []interface{}{"the mnu session key is not stored in the vault"})
+	}
+
+	if replace {
+		existing, err := s.store.Search(attrs)
+		if err != nil {
+			return noPrompt, noPrompt, failed(err)
+		}
+		for _, it := range existing {
+			if !reflect.DeepEqual(it.Attributes, attrs) {
+				continue
+			}
+			err := s.store.Update(it.ID, func(it *Item) {
+				it.Label = label
+				it.Secret = value
+				it.ContentType = secret.ContentType
+			})
+			if err != nil {
+				return noPrompt, noPrompt, failed(err)
+			}
+			s.emit("ItemChanged", itemPath(it.ID))
+			return itemPath(it.ID), noPrompt, nil
+		}
+	}
+
+	it, err := s.store.Create(label, attrs, value, secret.ContentType)
+	if err != nil {
+		return noPrompt, noPrompt, failed(err)
+	}
+	paths, err := s.itemPaths([]*Item{it})
+	if err != nil {
+		return noPrompt, noPrompt, failed(err)
+	}
+	s.emit("ItemCreated", paths[0])
+	return paths[0], noPrompt, nil
+}
+
+// itemObject implements org.freedesktop.Secret.Item.
+type itemObject struct {
+	s  *Service
+	id string
+}
+
+// Delete moves the item to the vault's trash, where it can be restored.
+func (o itemObject) Delete() (dbus.ObjectPath, *dbus.Error) {
+	s := o.s
+	path := itemPath(o.id)
+	if err := s.store.Delete(o.id); err != nil {
+		return noPrompt, failed(err)
+	}
+	s.mu.Lock()
+	delete(s.items, path)
+	s.unexport(path, ifaceItem)
+	s.mu.Unlock()
+	s.emit("ItemDeleted", path)
+	return noPrompt, nil
+}
+
+func (o itemObject) GetSecret(sender dbus.Sender, session dbus.ObjectPath) (Secret, *dbus.Error) {
+	sess, derr := o.s.session(sender, session)
+	if derr != nil {
+		return Secret{}, derr
+	}
+	it, derr := o.s.item(itemPath(o.id))
+	if derr != nil {
+		return Secret{}, derr
+	}
+	secret, err := sess.encrypt(it.Secret, it.ContentType)
+	if err != nil {
+		return Secret{}, failed(err)
+	}
+	return secret, nil
+}
+
+func (o itemObject) SetSecret(sender dbus.Sender, secret Secret) *dbus.Error {
+	sess, derr := o.s.session(sender, secret.Session)
+	if derr != nil {
+		return derr
+	}
+	value, err := sess.decrypt(secret)
+	if err != nil {
+		return dbus.NewError(errInvalidArgs, []interface{}{err.Error()})
+	}
+	err = o.s.store.Update(o.id, func(it *Item) {
+		it.Secret = value
+		it.ContentType = secret.ContentType
+	})
+	if err != nil {
+		return failed(err)
+	}
+	o.s.emit("ItemChanged", itemPath(o.id))
+	return nil
+}
+
+// sessionObject implements org.freedesktop.Secret.Session.
+type sessionObject struct {
+	s    *Service
+	path dbus.ObjectPath
+}
+
+func (o sessionObject) Close(sender dbus.Sender) *dbus.Error {
+	if _, derr := o.s.session(sender, o.path); derr != nil {
+		return derr
+	}
+	o.s.mu.Lock()
+	o.s.closeSession(o.path)
+	o.s.mu.Unlock()
+	return nil
+}
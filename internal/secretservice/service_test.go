@@ -0,0 +1,164 @@
+package secretservice
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a dbus-daemon of its own for the test and connects to
+// it twice: once for the service, once for a client.
+func privateBus(t *testing.T) (service, client *dbus.Conn) {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(strings.Replace(busConfig, "%s", filepath.Join(dir, "bus"), 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("dbus-daemon", "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon printed no address: %v", err)
+	}
+	connect := func() *dbus.Conn {
+		conn, err := dbus.Connect(strings.TrimSpace(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	return connect(), connect()
+}
+
+func TestServiceOverDBus(t *testing.T) {
+	serviceConn, client := privateBus(t)
+	v := &fakeVault{}
+	store, err := OpenStore(v, "Secret Service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New(serviceConn, store, "mnu").Register(false); err != nil {
+		t.Fatal(err)
+	}
+	svc := client.Object(busName, servicePath)
+
+	// OpenSession with a DH key exchange.
+	c := newDHClient(t)
+	var output dbus.Variant
+	var sessionPath dbus.ObjectPath
+	if err := svc.Call(ifaceService+".OpenSession", 0, algDH, dbus.MakeVariant(c.pub)).Store(&output, &sessionPath); err != nil {
+		t.Fatal(err)
+	}
+	c.finish(t, output)
+
+	// CreateItem through the default alias, as libsecret does.
+	attrs := map[string]string{"service": "gh:github.com", "username": "alice"}
+	create := func(secret string, replace bool) dbus.ObjectPath {
+		t.Helper()
+		iv, value := c.seal(t, []byte(secret), pkcs7(len(secret)))
+		props := map[string]dbus.Variant{
+			ifaceItem + ".Label":      dbus.MakeVariant("GitHub token"),
+			ifaceItem + ".Attributes": dbus.MakeVariant(attrs),
+		}
+		var item, prompt dbus.ObjectPath
+		err := client.Object(busName, defaultAliasPath).Call(ifaceCollection+".CreateItem", 0,
+			props, Secret{Session: sessionPath, Parameters: iv, Value: value, ContentType: "text/plain"}, replace,
+		).Store(&item, &prompt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prompt != noPrompt {
+			t.Errorf("CreateItem asked for prompt %q", prompt)
+		}
+		return item
+	}
+	item := create("ghp_first", false)
+
+	// SearchItems finds it by any subset of its attributes.
+	var unlocked, locked []dbus.ObjectPath
+	if err := svc.Call(ifaceService+".SearchItems", 0, map[string]string{"username": "alice"}).Store(&unlocked, &locked); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unlocked, []dbus.ObjectPath{item}) || len(locked) != 0 {
+		t.Fatalf("SearchItems = %v, %v, want [%s]", unlocked, locked, item)
+	}
+
+	getSecret := func() string {
+		t.Helper()
+		var secret Secret
+		if err := client.Object(busName, item).Call(ifaceItem+".GetSecret", 0, sessionPath).Store(&secret); err != nil {
+			t.Fatal(err)
+		}
+		if secret.ContentType != "text/plain" {
+			t.Errorf("content type %q", secret.ContentType)
+		}
+		return string(c.open(t, secret))
+	}
+	if got := getSecret(); got != "ghp_first" {
+		t.Errorf("GetSecret = %q, want ghp_first", got)
+	}
+
+	// The secret went to the vault as a note with the attributes as fields.
+	if len(v.items) != 1 || v.items[0]["notes"] != "ghp_first" || v.items[0]["name"] != "GitHub token" {
+		t.Errorf("vault holds %v", v.items)
+	}
+
+	// Replacing an item with the same attributes updates it.
+	if again := create("ghp_second", true); again != item {
+		t.Errorf("replace created %s, want %s updated", again, item)
+	}
+	if got := getSecret(); got != "ghp_second" || len(v.items) != 1 {
+		t.Errorf("after replace: GetSecret = %q with %d vault items", got, len(v.items))
+	}
+
+	// Attributes are readable as a property.
+	var prop dbus.Variant
+	if err := client.Object(busName, item).Call(ifaceProperties+".Get", 0, ifaceItem, "Attributes").Store(&prop); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := prop.Value().(map[string]string); !reflect.DeepEqual(got, attrs) {
+		t.Errorf("Attributes = %v, want %v", prop.Value(), attrs)
+	}
+
+	// Sessions belong to the connection that opened them.
+	var stolen Secret
+	err = serviceConn.Object(busName, item).Call(ifaceItem+".GetSecret", 0, sessionPath).Store(&stolen)
+	if derr, ok := err.(dbus.Error); !ok || derr.Name != errNoSession {
+		t.Errorf("GetSecret with another client's session: %v, want %s", err, errNoSession)
+	}
+}
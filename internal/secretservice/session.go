@@ -0,0 +1,129 @@
+package secretservice
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Transfer algorithms a client may ask for in OpenSession.
+const (
+	algPlain = "plain"
+	algDH    = "dh-ietf1024-sha256-aes128-cbc-pkcs7"
+)
+
+// dhPrime is the 1024-bit MODP group from RFC 2409 (second Oakley group),
+// which the Secret Service spec uses with generator 2.
+var dhPrime, _ = new(big.Int).SetString(
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1"+
+		"29024E088A67CC74020BBEA63B139B22514A08798E3404DD"+
+		"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245"+
+		"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381"+
+		"FFFFFFFFFFFFFFFF", 16)
+
+var dhGenerator = big.NewInt(2)
+
+// Secret is the (oayays) struct secrets travel in.
+type Secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// session is an open transfer session. key is nil for plain transfers.
+type session struct {
+	path  dbus.ObjectPath
+	owner string
+	key   []byte
+}
+
+// negotiate sets up the session's key for algorithm and returns the output
+// OpenSession hands back to the client.
+func (s *session) negotiate(algorithm string, input dbus.Variant) (dbus.Variant, error) {
+	switch algorithm {
+	case algPlain:
+		return dbus.MakeVariant(""), nil
+	case algDH:
+		peer, ok := input.Value().([]byte)
+		if !ok {
+			return dbus.Variant{}, errors.New("public key must be a byte array")
+		}
+		y := new(big.Int).SetBytes(peer)
+		if y.Cmp(big.NewInt(1)) <= 0 || y.Cmp(new(big.Int).Sub(dhPrime, big.NewInt(1))) >= 0 {
+			return dbus.Variant{}, errors.New("invalid public key")
+		}
+		x, err := rand.Int(rand.Reader, new(big.Int).Sub(dhPrime, big.NewInt(2)))
+		if err != nil {
+			return dbus.Variant{}, err
+		}
+		x.Add(x, big.NewInt(1))
+		pub := new(big.Int).Exp(dhGenerator, x, dhPrime)
+		shared := new(big.Int).Exp(y, x, dhPrime)
+
+		// Clients pad the shared secret to the size of the prime.
+		ikm := shared.FillBytes(make([]byte, 128))
+		key, err := hkdf.Key(sha256.New, ikm, nil, "", 16)
+		if err != nil {
+			return dbus.Variant{}, err
+		}
+		s.key = key
+		return dbus.MakeVariant(pub.FillBytes(make([]byte, 128))), nil
+	default:
+		return dbus.Variant{}, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
+
+// encrypt wraps a secret for the client.
+func (s *session) encrypt(secret []byte, contentType string) (Secret, error) {
+	out := Secret{Session: s.path, ContentType: contentType}
+	if s.key == nil {
+		out.Parameters = []byte{}
+		out.Value = secret
+		return out, nil
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return out, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return out, err
+	}
+	pad := aes.BlockSize - len(secret)%aes.BlockSize
+	buf := append(append([]byte{}, secret...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(buf, buf)
+	out.Parameters = iv
+	out.Value = buf
+	return out, nil
+}
+
+// decrypt unwraps a secret sent by the client.
+func (s *session) decrypt(in Secret) ([]byte, error) {
+	if s.key == nil {
+		return in.Value, nil
+	}
+	if len(in.Parameters) != aes.BlockSize || len(in.Value) == 0 || len(in.Value)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed encrypted secret")
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte{}, in.Value...)
+	cipher.NewCBCDecrypter(block, in.Parameters).CryptBlocks(buf, buf)
+	pad := int(buf[len(buf)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(buf[len(buf)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, errors.New("bad padding")
+	}
+	return buf[:len(buf)-pad], nil
+}
@@ -0,0 +1,209 @@
+package secretservice
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// dhClient is the client side of dh-ietf1024-sha256-aes128-cbc-pkcs7 as
+// libsecret implements it.
+type dhClient struct {
+	x   *big.Int
+	pub []byte
+	key []byte
+}
+
+func newDHClient(t *testing.T) *dhClient {
+	t.Helper()
+	x, err := rand.Int(rand.Reader, dhPrime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dhClient{x: x, pub: new(big.Int).Exp(dhGenerator, x, dhPrime).Bytes()}
+}
+
+// finish derives the key from the server's public key.
+func (c *dhClient) finish(t *testing.T, output dbus.Variant) {
+	t.Helper()
+	serverPub, ok := output.Value().([]byte)
+	if !ok || len(serverPub) != 128 {
+		t.Fatalf("OpenSession output %v, want a 128-byte public key", output)
+	}
+	shared := new(big.Int).Exp(new(big.Int).SetBytes(serverPub), c.x, dhPrime)
+	key, err := hkdf.Key(sha256.New, shared.FillBytes(make([]byte, 128)), nil, "", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.key = key
+}
+
+// seal encrypts plain followed by padding, which is PKCS#7 unless a test
+// wants it broken.
+func (c *dhClient) seal(t *testing.T, plain, padding []byte) (iv, value []byte) {
+	t.Helper()
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv = make([]byte, aes.BlockSize)
+	rand.Read(iv)
+	value = append(append([]byte{}, plain...), padding...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(value, value)
+	return iv, value
+}
+
+func pkcs7(n int) []byte {
+	pad := aes.BlockSize - n%aes.BlockSize
+	return bytes.Repeat([]byte{byte(pad)}, pad)
+}
+
+func (c *dhClient) open(t *testing.T, s Secret) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Parameters) != aes.BlockSize || len(s.Value)%aes.BlockSize != 0 || len(s.Value) == 0 {
+		t.Fatalf("malformed secret: %d byte IV, %d byte value", len(s.Parameters), len(s.Value))
+	}
+	buf := append([]byte{}, s.Value...)
+	cipher.NewCBCDecrypter(block, s.Parameters).CryptBlocks(buf, buf)
+	pad := int(buf[len(buf)-1])
+	if pad < 1 || pad > aes.BlockSize || !bytes.Equal(buf[len(buf)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		t.Fatalf("server sent bad padding % x", buf[len(buf)-aes.BlockSize:])
+	}
+	return buf[:len(buf)-pad]
+}
+
+func dhSession(t *testing.T) (*session, *dhClient) {
+	t.Helper()
+	c := newDHClient(t)
+	s := &session{path: "/s"}
+	output, err := s.negotiate(algDH, dbus.MakeVariant(c.pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.finish(t, output)
+	return s, c
+}
+
+func TestDHKeyAgreement(t *testing.T) {
+	s, c := dhSession(t)
+	if !bytes.Equal(s.key, c.key) {
+		t.Fatalf("server key % x, client key % x", s.key, c.key)
+	}
+	// Every session gets its own key.
+	s2, _ := dhSession(t)
+	if bytes.Equal(s.key, s2.key) {
+		t.Error("two sessions agreed on the same key")
+	}
+}
+
+func TestDHRoundTrip(t *testing.T) {
+	s, c := dhSession(t)
+	for _, n := range []int{0, 1, 15, 16, 17, 31, 32, 100} {
+		plain := make([]byte, n)
+		rand.Read(plain)
+
+		iv, value := c.seal(t, plain, pkcs7(n))
+		got, err := s.decrypt(Secret{Session: s.path, Parameters: iv, Value: value})
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decrypt of %d bytes = % x, %v", n, got, err)
+		}
+
+		out, err := s.encrypt(plain, "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Session != s.path || out.ContentType != "text/plain" {
+			t.Errorf("encrypt returned session %q, content type %q", out.Session, out.ContentType)
+		}
+		if len(out.Value) != n+len(pkcs7(n)) {
+			t.Errorf("encrypt of %d bytes gave %d bytes, want %d", n, len(out.Value), n+len(pkcs7(n)))
+		}
+		if got := c.open(t, out); !bytes.Equal(got, plain) {
+			t.Errorf("client decrypted % x, want % x", got, plain)
+		}
+	}
+}
+
+func TestDHRejectsBadSecrets(t *testing.T) {
+	s, c := dhSession(t)
+	plain := []byte("hunter2")
+	iv, good := c.seal(t, plain, pkcs7(len(plain)))
+	block := func(padding []byte) []byte {
+		_, v := c.seal(t, nil, padding)
+		return v
+	}
+	for _, tt := range []struct {
+		name       string
+		parameters []byte
+		value      []byte
+	}{
+		{"no IV", nil, good},
+		{"short IV", iv[:8], good},
+		{"long IV", append(append([]byte{}, iv...), 0), good},
+		{"empty value", iv, nil},
+		{"partial block", iv, good[:15]},
+		{"extra byte", iv, append(append([]byte{}, good...), 0)},
+		{"zero padding", iv, block(bytes.Repeat([]byte{0}, 16))},
+		{"padding longer than a block", iv, block(bytes.Repeat([]byte{17}, 16))},
+		{"inconsistent padding", iv, block(append(bytes.Repeat([]byte{'x'}, 12), 9, 4, 4, 4))},
+	} {
+		if got, err := s.decrypt(Secret{Session: s.path, Parameters: tt.parameters, Value: tt.value}); err == nil {
+			t.Errorf("%s: decrypt returned % x, want an error", tt.name, got)
+		}
+	}
+}
+
+func TestNegotiateRejects(t *testing.T) {
+	pMinus1 := new(big.Int).Sub(dhPrime, big.NewInt(1))
+	for _, tt := range []struct {
+		name      string
+		algorithm string
+		input     dbus.Variant
+	}{
+		{"unknown algorithm", "dh-ietf2048-sha512-aes256", dbus.MakeVariant(newDHClient(t).pub)},
+		{"public key not bytes", algDH, dbus.MakeVariant("AAAA")},
+		{"public key zero", algDH, dbus.MakeVariant([]byte{0})},
+		{"public key one", algDH, dbus.MakeVariant([]byte{1})},
+		{"public key p-1", algDH, dbus.MakeVariant(pMinus1.Bytes())},
+		{"public key p", algDH, dbus.MakeVariant(dhPrime.Bytes())},
+		{"public key too large", algDH, dbus.MakeVariant(bytes.Repeat([]byte{0xff}, 200))},
+	} {
+		s := &session{}
+		if _, err := s.negotiate(tt.algorithm, tt.input); err == nil {
+			t.Errorf("%s: negotiate succeeded", tt.name)
+		}
+		if s.key != nil {
+			t.Errorf("%s: key set after a failed negotiation", tt.name)
+		}
+	}
+}
+
+func TestPlainSession(t *testing.T) {
+	s := &session{path: "/s"}
+	output, err := s.negotiate(algPlain, dbus.MakeVariant(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Value() != "" {
+		t.Errorf("plain output = %v, want an empty string", output)
+	}
+	out, err := s.encrypt([]byte("hunter2"), "text/plain")
+	if err != nil || string(out.Value) != "hunter2" || len(out.Parameters) != 0 {
+		t.Errorf("encrypt = %+v, %v", out, err)
+	}
+	got, err := s.decrypt(Secret{Value: []byte("hunter2")})
+	if err != nil || string(got) != "hunter2" {
+		t.Errorf("decrypt = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,293 @@
+package secretservice
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+)
+
+// Reserved custom field names. Every other text field is an attribute.
+const (
+	fieldContentType = "mnu:content-type"
+	fieldEncoding    = "mnu:encoding"
+)
+
+// reloadAfter bounds how stale the cached folder contents may get when items
+// are changed outside the service.
+const reloadAfter = time.Minute
+
+// Item is a Secret Service item. It is stored as a secure note in the
+// service's folder: the label is the name, the secret is the note (base64
+// when it is not UTF-8) and each attribute is a text custom field.
+type Item struct {
+	ID          string
+	Label       string
+	Attributes  map[string]string
+	Secret      []byte
+	ContentType string
+	Created     time.Time
+	Modified    time.Time
+}
+
+// Store keeps the items of one vault folder.
+type Store struct {
+	mgr      bwpkg.Manager
+	folderID string
+
+	mu     sync.Mutex
+	items  map[string]*Item
+	loaded time.Time
+}
+
+// OpenStore loads the folder with the given name, creating it if needed.
+func OpenStore(mgr bwpkg.Manager, folder string) (*Store, error) {
+	folders, err := mgr.GetFolders()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folders: %w", err)
+	}
+	var id string
+	for _, f := range folders {
+		if name, _ := f["name"].(string); name == folder {
+			id, _ = f["id"].(string)
+		}
+	}
+	if id == "" {
+		if id, err = mgr.CreateFolder(folder); err != nil {
+			return nil, fmt.Errorf("failed to create folder %q: %w", folder, err)
+		}
+	}
+	s := &Store{mgr: mgr, folderID: id}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	raw, err := s.mgr.GetItems()
+	if err != nil {
+		// Most likely the vault got locked; stop serving cached secrets.
+		s.items = map[string]*Item{}
+		return fmt.Errorf("failed to load items: %w", err)
+	}
+	items := map[string]*Item{}
+	for _, m := range raw {
+		if folder, _ := m["folderId"].(string); folder != s.folderID {
+			continue
+		}
+		if t, _ := m["type"].(float64); int(t) != bwpkg.TypeSecureNote {
+			continue
+		}
+		it := itemFromMap(m)
+		items[it.ID] = it
+	}
+	s.items = items
+	s.loaded = time.Now()
+	return nil
+}
+
+func (s *Store) fresh() error {
+	if time.Since(s.loaded) < reloadAfter {
+		return nil
+	}
+	return s.load()
+}
+
+// Get returns the item with the given ID.
+func (s *Store) Get(id string) (*Item, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.fresh(); err != nil {
+		return nil, false
+	}
+	it, ok := s.items[id]
+	return it, ok
+}
+
+// Search returns the items carrying all of attrs; an empty set matches all.
+func (s *Store) Search(attrs map[string]string) ([]*Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.fresh(); err != nil {
+		return nil, err
+	}
+	var out []*Item
+	for _, it := range s.items {
+		if hasAttributes(it, attrs) {
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+func hasAttributes(it *Item, attrs map[string]string) bool {
+	for k, v := range attrs {
+		if it.Attributes[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Create adds an item to the folder.
+func (s *Store) Create(label string, attrs map[string]string, secret []byte, contentType string) (*Item, error) {
+	it := &Item{Label: label, Attributes: attrs, Secret: secret, ContentType: contentType}
+	m := map[string]interface{}{
+		"type":     bwpkg.TypeSecureNote,
+		"folderId": s.folderID,
+		"secureNote": map[string]interface{}{
+			"type": 0,
+		},
+	}
+	applyItem(m, it)
+	id, err := s.mgr.CreateItem(m)
+	if err != nil {
+		return nil, err
+	}
+	it.ID = id
+	it.Created = time.Now()
+	it.Modified = it.Created
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[id] = it
+	return it, nil
+}
+
+// Update writes label, attributes and secret of an existing item back to
+// the vault.
+func (s *Store) Update(id string, update func(*Item)) error {
+	s.mu.Lock()
+	cur, ok := s.items[id]
+	if !ok {
+		s.mu.Unlock()
+		return errors.New("no such item")
+	}
+	next := *cur
+	next.Attributes = copyAttributes(cur.Attributes)
+	s.mu.Unlock()
+
+	update(&next)
+	m, err := s.mgr.GetItem(id)
+	if err != nil {
+		return err
+	}
+	applyItem(m, &next)
+	if err := s.mgr.EditItem(id, m); err != nil {
+		return err
+	}
+	next.Modified = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[id] = &next
+	return nil
+}
+
+// Delete moves an item to the vault's trash.
+func (s *Store) Delete(id string) error {
+	if err := s.mgr.DeleteItem(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
+
+func copyAttributes(attrs map[string]string) map[string]string {
+	out := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		out[k] = v
+	}
+	return out
+}
+
+func itemFromMap(m map[string]interface{}) *Item {
+	it := &Item{Attributes: map[string]string{}}
+	it.ID, _ = m["id"].(string)
+	it.Label, _ = m["name"].(string)
+	notes, _ := m["notes"].(string)
+	encoding := ""
+	if fields, ok := m["fields"].([]interface{}); ok {
+		for _, f := range fields {
+			fm, ok := f.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if t, _ := fm["type"].(float64); t != 0 {
+				continue
+			}
+			name, _ := fm["name"].(string)
+			value, _ := fm["value"].(string)
+			switch name {
+			case fieldContentType:
+				it.ContentType = value
+			case fieldEncoding:
+				encoding = value
+			default:
+				it.Attributes[name] = value
+			}
+		}
+	}
+	it.Secret = []byte(notes)
+	if encoding == "base64" {
+		if b, err := base64.StdEncoding.DecodeString(notes); err == nil {
+			it.Secret = b
+		}
+	}
+	it.Created = parseTime(m["creationDate"])
+	it.Modified = parseTime(m["revisionDate"])
+	return it
+}
+
+// applyItem sets name, note and text fields of a raw vault item from it,
+// keeping everything else (including hidden and boolean fields) as it is.
+func applyItem(m map[string]interface{}, it *Item) {
+	m["name"] = it.Label
+	var fields []interface{}
+	if old, ok := m["fields"].([]interface{}); ok {
+		for _, f := range old {
+			if fm, ok := f.(map[string]interface{}); ok {
+				if t, _ := fm["type"].(float64); t == 0 {
+					continue
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	text := func(name, value string) {
+		fields = append(fields, map[string]interface{}{"name": name, "value": value, "type": 0})
+	}
+	keys := make([]string, 0, len(it.Attributes))
+	for k := range it.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		text(k, it.Attributes[k])
+	}
+	if it.ContentType != "" {
+		text(fieldContentType, it.ContentType)
+	}
+	if utf8.Valid(it.Secret) && !strings.ContainsRune(string(it.Secret), 0) {
+		m["notes"] = string(it.Secret)
+	} else {
+		m["notes"] = base64.StdEncoding.EncodeToString(it.Secret)
+		text(fieldEncoding, "base64")
+	}
+	m["fields"] = fields
+}
+
+func parseTime(v interface{}) time.Time {
+	s, _ := v.(string)
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
@@ -0,0 +1,226 @@
+package secretservice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+)
+
+// fakeVault is a Manager over items and folders held in memory. Items go
+// through JSON like they would through bw.
+type fakeVault struct {
+	bwpkg.Manager // panics on anything the tests do not expect
+	items         []map[string]interface{}
+	folders       []map[string]interface{}
+	nextID        int
+}
+
+func roundtrip(m map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(m)
+	var out map[string]interface{}
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+func (v *fakeVault) GetItems() ([]map[string]interface{}, error) {
+	out := make([]map[string]interface{}, len(v.items))
+	for i, it := range v.items {
+		out[i] = roundtrip(it)
+	}
+	return out, nil
+}
+
+func (v *fakeVault) GetItem(id string) (map[string]interface{}, error) {
+	for _, it := range v.items {
+		if it["id"] == id {
+			return roundtrip(it), nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func (v *fakeVault) CreateItem(item map[string]interface{}) (string, error) {
+	v.nextID++
+	id := fmt.Sprintf("1b6e2c4a-0000-4000-8000-%012d", v.nextID)
+	item = roundtrip(item)
+	item["id"] = id
+	v.items = append(v.items, item)
+	return id, nil
+}
+
+func (v *fakeVault) EditItem(id string, item map[string]interface{}) error {
+	for i, it := range v.items {
+		if it["id"] == id {
+			v.items[i] = roundtrip(item)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+func (v *fakeVault) DeleteItem(id string) error {
+	for i, it := range v.items {
+		if it["id"] == id {
+			v.items = append(v.items[:i], v.items[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+func (v *fakeVault) GetFolders() ([]map[string]interface{}, error) { return v.folders, nil }
+
+func (v *fakeVault) CreateFolder(name string) (string, error) {
+	id := fmt.Sprintf("folder%d", len(v.folders))
+	v.folders = append(v.folders, map[string]interface{}{"id": id, "name": name})
+	return id, nil
+}
+
+func TestItemMapping(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		item Item
+	}{
+		{"text", Item{Label: "GitHub token", Attributes: map[string]string{"service": "gh:github.com", "username": "alice"}, Secret: []byte("ghp_123"), ContentType: "text/plain"}},
+		{"no attributes", Item{Label: "bare", Attributes: map[string]string{}, Secret: []byte("x")}},
+		{"binary", Item{Label: "key", Attributes: map[string]string{"xdg:schema": "org.example.Key"}, Secret: []byte{0xff, 0xfe, 0x00, 0x01}, ContentType: "application/octet-stream"}},
+		{"NUL in UTF-8", Item{Label: "nul", Attributes: map[string]string{}, Secret: []byte("a\x00b")}},
+		{"reserved-looking attribute", Item{Label: "odd", Attributes: map[string]string{"mnu:other": "kept"}, Secret: []byte("s")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := map[string]interface{}{"id": "1", "type": bwpkg.TypeSecureNote}
+			applyItem(m, &tt.item)
+			got := itemFromMap(roundtrip(m))
+			if got.Label != tt.item.Label || string(got.Secret) != string(tt.item.Secret) || got.ContentType != tt.item.ContentType {
+				t.Errorf("got label %q, secret %q, content type %q", got.Label, got.Secret, got.ContentType)
+			}
+			if !reflect.DeepEqual(got.Attributes, tt.item.Attributes) {
+				t.Errorf("got attributes %v, want %v", got.Attributes, tt.item.Attributes)
+			}
+		})
+	}
+}
+
+func TestApplyItemFields(t *testing.T) {
+	// Attributes become text fields in a stable order, binary secrets are
+	// stored as base64, and hidden or boolean fields the user added in
+	// Bitwarden are left alone.
+	m := roundtrip(map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"name": "old", "value": "gone", "type": 0},
+			map[string]interface{}{"name": "pin", "value": "1234", "type": 1},
+			map[string]interface{}{"name": "flag", "value": "true", "type": 2},
+		},
+	})
+	applyItem(m, &Item{
+		Label:       "label",
+		Attributes:  map[string]string{"b": "2", "a": "1"},
+		Secret:      []byte{0xff},
+		ContentType: "application/octet-stream",
+	})
+	m = roundtrip(m)
+	var fields []string
+	for _, f := range m["fields"].([]interface{}) {
+		fm := f.(map[string]interface{})
+		fields = append(fields, fmt.Sprintf("%v=%v/%v", fm["name"], fm["value"], fm["type"]))
+	}
+	want := []string{"pin=1234/1", "flag=true/2", "a=1/0", "b=2/0", "mnu:content-type=application/octet-stream/0", "mnu:encoding=base64/0"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %q, want %q", fields, want)
+	}
+	if m["notes"] != "/w==" || m["name"] != "label" {
+		t.Errorf("notes %v, name %v", m["notes"], m["name"])
+	}
+
+	// Hidden and boolean fields are not attributes.
+	it := itemFromMap(m)
+	if !reflect.DeepEqual(it.Attributes, map[string]string{"a": "1", "b": "2"}) {
+		t.Errorf("attributes = %v", it.Attributes)
+	}
+}
+
+func TestStore(t *testing.T) {
+	v := &fakeVault{items: []map[string]interface{}{
+		// Neither a note elsewhere nor a login in the folder belongs to
+		// the service.
+		{"id": "other", "type": 2, "name": "elsewhere", "notes": "x"},
+	}}
+	s, err := OpenStore(v, "Secret Service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.folders) != 1 {
+		t.Fatalf("OpenStore did not create the folder: %v", v.folders)
+	}
+	folderID := v.folders[0]["id"]
+	v.items = append(v.items, map[string]interface{}{"id": "login", "type": 1, "name": "login", "folderId": folderID})
+	if err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := s.Create("a", map[string]string{"service": "svc", "user": "alice"}, []byte("pw-a"), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("b", map[string]string{"service": "svc", "user": "bob"}, []byte("pw-b"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if created := v.items[len(v.items)-1]; created["folderId"] != folderID || created["type"] != float64(bwpkg.TypeSecureNote) {
+		t.Errorf("created %v", created)
+	}
+
+	search := func(attrs map[string]string) []string {
+		t.Helper()
+		items, err := s.Search(attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, it := range items {
+			labels = append(labels, it.Label)
+		}
+		return labels
+	}
+	if got := search(map[string]string{"user": "alice"}); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("search user=alice: %q", got)
+	}
+	if got := search(map[string]string{"service": "svc"}); len(got) != 2 {
+		t.Errorf("search service=svc: %q", got)
+	}
+	if got := search(map[string]string{"service": "svc", "user": "carol"}); len(got) != 0 {
+		t.Errorf("search user=carol: %q", got)
+	}
+	if got := search(nil); len(got) != 2 {
+		t.Errorf("search all: %q, want the two service items", got)
+	}
+
+	if err := s.Update(a.ID, func(it *Item) { it.Secret = []byte("pw-a2"); it.Attributes["user"] = "alice2" }); err != nil {
+		t.Fatal(err)
+	}
+	// A fresh store sees what was written to the vault.
+	s2, err := OpenStore(v, "Secret Service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := s2.Get(a.ID)
+	if !ok || string(got.Secret) != "pw-a2" || got.Attributes["user"] != "alice2" || got.ContentType != "text/plain" {
+		t.Errorf("after Update: %+v", got)
+	}
+	if it, _ := s.Get(a.ID); it == a || a.Attributes["user"] != "alice" {
+		t.Error("Update changed the item in place instead of replacing it")
+	}
+
+	if err := s.Delete(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get(a.ID); ok {
+		t.Error("deleted item still found")
+	}
+	if len(v.folders) != 1 {
+		t.Errorf("reopening created another folder")
+	}
+}
@@ -0,0 +1,43 @@
+package serve
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// SocketActivated reports whether systemd passed this process a socket.
+func SocketActivated() bool {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return false
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return err == nil && n > 0
+}
+
+// activationListener returns the socket systemd passed via LISTEN_FDS, if
+// any. The variables are cleared so child processes do not pick them up.
+func activationListener() (net.Listener, bool, error) {
+	if !SocketActivated() {
+		return nil, false, nil
+	}
+	n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if n > 1 {
+		return nil, false, fmt.Errorf("expected one socket from systemd, got %d", n)
+	}
+	f := os.NewFile(listenFDsStart, "serve.sock")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid socket from systemd: %w", err)
+	}
+	return ln, true, nil
+}
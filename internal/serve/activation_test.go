@@ -0,0 +1,123 @@
+package serve
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// activationChildEnv makes the test binary act as a socket-activated child
+// in TestActivationListener.
+const activationChildEnv = "MNU_TEST_ACTIVATION_CHILD"
+
+func TestActivationListener(t *testing.T) {
+	if os.Getenv(activationChildEnv) != "" {
+		activationChild()
+		return
+	}
+
+	sock := filepath.Join(t.TempDir(), "serve.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The socket stays with the child, as with systemd.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	f, err := ln.(*net.UnixListener).File()
+	ln.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Like systemd, hand the socket over as fd 3. LISTEN_PID can only be
+	// known after the fork, so the child sets it from LISTEN_PID_SELF.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestActivationListener$")
+	cmd.Env = append(os.Environ(), activationChildEnv+"=1", "LISTEN_FDS=1", "LISTEN_PID_SELF=1")
+	cmd.ExtraFiles = []*os.File{f}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	r := bufio.NewReader(out)
+	ready, err := r.ReadString('\n')
+	if err != nil || ready != "listening\n" {
+		t.Fatalf("child said %q (%v), want listening", ready, err)
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "activated\n" {
+		t.Errorf("got %q over the passed socket, want activated", reply)
+	}
+	if env, _ := r.ReadString('\n'); env != "env cleared\n" {
+		t.Errorf("child said %q, want env cleared", env)
+	}
+}
+
+// activationChild takes the socket on fd 3 and answers one connection.
+func activationChild() {
+	if os.Getenv("LISTEN_PID_SELF") != "" {
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	}
+	ln, ok, err := activationListener()
+	if err != nil || !ok {
+		fmt.Printf("no listener: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("listening")
+	conn, err := ln.Accept()
+	if err != nil {
+		os.Exit(1)
+	}
+	fmt.Fprintln(conn, "activated")
+	conn.Close()
+	if os.Getenv("LISTEN_PID") == "" && os.Getenv("LISTEN_FDS") == "" {
+		fmt.Println("env cleared")
+	}
+	os.Exit(0)
+}
+
+func TestActivationListenerOtherPID(t *testing.T) {
+	// The variables were meant for a parent process.
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getppid()))
+	t.Setenv("LISTEN_FDS", "1")
+	if SocketActivated() {
+		t.Error("SocketActivated() with another process's LISTEN_PID")
+	}
+	ln, ok, err := activationListener()
+	if ln != nil || ok || err != nil {
+		t.Errorf("activationListener() = %v, %v, %v; want nothing", ln, ok, err)
+	}
+}
+
+func TestActivationListenerTooMany(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	if _, _, err := activationListener(); err == nil {
+		t.Error("activationListener() accepted two sockets")
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS was not cleared")
+	}
+}
@@ -0,0 +1,148 @@
+package serve
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in the items the advertiser lists, so clients
+// can still tell that a secret is there without it leaving bw serve.
+const Redacted = "<redacted>"
+
+// itemCache is the advertiser's in-memory copy of the vault's items with
+// their secrets redacted. It is reloaded from bw serve after anything that
+// may have changed the vault: a write or unlock through the proxy, a sync or
+// lock, or a restart of bw serve.
+type itemCache struct {
+	mu         sync.Mutex
+	items      []map[string]interface{}
+	generation uint64
+	loaded     bool
+}
+
+// list returns the cached items, loading them from srv first if the vault
+// changed since they were loaded.
+func (c *itemCache) list(srv *Server) ([]map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gen := srv.generation.Load()
+	if c.loaded && c.generation == gen {
+		return c.items, nil
+	}
+	c.items, c.loaded = nil, false
+	data, err := srv.call("GET", "/list/object/items")
+	if err != nil {
+		return nil, err
+	}
+	raw, _ := data["data"].([]interface{})
+	items := make([]map[string]interface{}, 0, len(raw))
+	for _, r := range raw {
+		if m, ok := r.(map[string]interface{}); ok {
+			items = append(items, redact(m))
+		}
+	}
+	c.items, c.generation, c.loaded = items, gen, true
+	return items, nil
+}
+
+// drop forgets the cached items.
+func (c *itemCache) drop() {
+	c.mu.Lock()
+	c.items, c.loaded = nil, false
+	c.mu.Unlock()
+}
+
+// search returns the cached items whose name or username contains query,
+// ignoring case, the same way the TUI filters its list.
+func (c *itemCache) search(srv *Server, query string) ([]map[string]interface{}, error) {
+	items, err := c.list(srv)
+	if err != nil {
+		return nil, err
+	}
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return items, nil
+	}
+	var found []map[string]interface{}
+	for _, m := range items {
+		name, _ := m["name"].(string)
+		var username string
+		if login, ok := m["login"].(map[string]interface{}); ok {
+			username, _ = login["username"].(string)
+		}
+		if strings.Contains(strings.ToLower(name+" "+username), q) {
+			found = append(found, m)
+		}
+	}
+	return found, nil
+}
+
+// get fetches one item with its secrets straight from bw serve; secrets are
+// never cached.
+func (c *itemCache) get(srv *Server, id string) (map[string]interface{}, error) {
+	if id == "" {
+		return nil, fmt.Errorf("missing item id")
+	}
+	return srv.call("GET", "/object/item/"+url.PathEscape(id))
+}
+
+// redact returns a copy of a raw item without its secrets. Secrets that are
+// set become Redacted; credential blobs and history are left out.
+func redact(m map[string]interface{}) map[string]interface{} {
+	out := copyMap(m)
+	delete(out, "passwordHistory")
+	redactKeys(out, "notes")
+	if login, ok := m["login"].(map[string]interface{}); ok {
+		login = copyMap(login)
+		delete(login, "fido2Credentials")
+		redactKeys(login, "password", "totp")
+		out["login"] = login
+	}
+	if card, ok := m["card"].(map[string]interface{}); ok {
+		card = copyMap(card)
+		redactKeys(card, "number", "code")
+		out["card"] = card
+	}
+	if identity, ok := m["identity"].(map[string]interface{}); ok {
+		identity = copyMap(identity)
+		redactKeys(identity, "ssn", "passportNumber", "licenseNumber")
+		out["identity"] = identity
+	}
+	if sshKey, ok := m["sshKey"].(map[string]interface{}); ok {
+		sshKey = copyMap(sshKey)
+		redactKeys(sshKey, "privateKey")
+		out["sshKey"] = sshKey
+	}
+	if fields, ok := m["fields"].([]interface{}); ok {
+		redacted := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			if fm, ok := f.(map[string]interface{}); ok {
+				fm = copyMap(fm)
+				redactKeys(fm, "value")
+				redacted = append(redacted, fm)
+			}
+		}
+		out["fields"] = redacted
+	}
+	return out
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func redactKeys(m map[string]interface{}, keys ...string) {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok && strings.TrimSpace(s) != "" {
+			m[k] = Redacted
+		} else if m[k] != nil {
+			m[k] = nil
+		}
+	}
+}
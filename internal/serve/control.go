@@ -0,0 +1,305 @@
+package serve
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The control protocol is JSON over the advertiser socket: the client writes
+// one Request on a line and reads one Response line back. Lines starting
+// with "{" select it; anything else is a line command (see session.go).
+
+// ProtocolVersion is the control protocol version spoken by this build.
+// Requests for a newer version are refused; 0 means 1. Version 2 added the
+// item commands.
+const ProtocolVersion = 2
+
+// Control commands.
+const (
+	CmdPing     = "ping"
+	CmdStatus   = "status"
+	CmdLock     = "lock"
+	CmdSync     = "sync"
+	CmdShutdown = "shutdown"
+
+	// CmdList and CmdSearch return the cached items, all or those matching
+	// Request.Query, with their secrets Redacted. CmdGet returns the item
+	// Request.ID with its secrets, fetched from bw serve.
+	CmdList   = "list"
+	CmdSearch = "search"
+	CmdGet    = "get"
+)
+
+// controlTimeout bounds a control request; sync can take a while.
+const controlTimeout = time.Minute
+
+type Request struct {
+	Version int    `json:"version"`
+	Command string `json:"command"`
+	Query   string `json:"query,omitempty"`
+	ID      string `json:"id,omitempty"`
+}
+
+type Response struct {
+	Version int                      `json:"version"`
+	OK      bool                     `json:"ok"`
+	Error   string                   `json:"error,omitempty"`
+	Status  *Status                  `json:"status,omitempty"`
+	Items   []map[string]interface{} `json:"items,omitempty"`
+	Item    map[string]interface{}   `json:"item,omitempty"`
+}
+
+// Status describes a running advertiser and the vault behind it.
+type Status struct {
+	Socket        string `json:"socket"`
+	PID           int    `json:"pid"`
+	BWPID         int    `json:"bwPid,omitempty"`
+	UptimeSeconds int64  `json:"uptimeSeconds"`
+	Restarts      int    `json:"restarts"`
+	// Activated is set when systemd started the advertiser on demand.
+	Activated bool `json:"activated"`
+	// Vault is locked, unlocked, unauthenticated or unknown.
+	Vault    string `json:"vault"`
+	Profile  string `json:"profile,omitempty"`
+	Server   string `json:"server,omitempty"`
+	LastSync string `json:"lastSync,omitempty"`
+}
+
+// Control sends command to the running advertiser. It returns ErrNotRunning
+// when there is none and the advertiser's message when the command failed.
+func Control(command string) (*Response, error) {
+	return Do(Request{Command: command})
+}
+
+// Do sends req to the running advertiser like Control, filling in the
+// protocol version.
+func Do(req Request) (*Response, error) {
+	req.Version = ProtocolVersion
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := request(string(data), controlTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var resp Response
+	if err := json.Unmarshal([]byte(reply), &resp); err != nil {
+		return nil, fmt.Errorf("invalid reply from mnu-bw serve: %w", err)
+	}
+	if !resp.OK {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// advertiser serves the advertiser socket of RunAdvertiser.
+type advertiser struct {
+	srv       *Server // set once ready is closed
+	activated bool
+	session   sessionHolder
+	items     itemCache
+	lastUsed  atomic.Int64 // unix nanoseconds
+
+	ready    chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+	shutdown func()
+}
+
+func (a *advertiser) touch() { a.lastUsed.Store(time.Now().UnixNano()) }
+
+// idleFor is the time since the advertiser socket or the vault API was
+// last used.
+func (a *advertiser) idleFor() time.Duration {
+	last := a.lastUsed.Load()
+	select {
+	case <-a.ready:
+		last = max(last, a.srv.lastUsed.Load())
+	default:
+	}
+	return time.Since(time.Unix(0, last))
+}
+
+// watchIdle shuts the advertiser down after timeout without use.
+func (a *advertiser) watchIdle(timeout time.Duration) {
+	tick := time.NewTicker(min(timeout/4, time.Minute))
+	defer tick.Stop()
+	for {
+		select {
+		case <-a.stopped:
+			return
+		case <-tick.C:
+			if idle := a.idleFor(); idle >= timeout {
+				log.Printf("Idle for %s; shutting down", idle.Round(time.Second))
+				a.shutdown()
+				return
+			}
+		}
+	}
+}
+
+// waitReady waits until bw serve is up; false means the advertiser stopped.
+func (a *advertiser) waitReady() bool {
+	select {
+	case <-a.ready:
+		return true
+	case <-a.stopped:
+		return false
+	}
+}
+
+func (a *advertiser) handle(c net.Conn) {
+	c.SetReadDeadline(time.Now().Add(commandWait))
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+	line = strings.TrimRight(line, "\r\n")
+	cmd, arg, _ := strings.Cut(line, " ")
+	if cmd != "SESSION" && !a.waitReady() {
+		return
+	}
+	if strings.HasPrefix(line, "{") {
+		a.control(c, line)
+		return
+	}
+	var reply string
+	switch cmd {
+	case "ENDPOINT":
+		reply = a.srv.Endpoint.Socket + " " + a.srv.Endpoint.Token
+	case "SESSION":
+		reply = a.session.session(arg)
+	default:
+		reply = "ERR unknown command"
+	}
+	_, _ = c.Write([]byte(reply + "\n"))
+}
+
+func (a *advertiser) control(c net.Conn, line string) {
+	var req Request
+	resp := Response{Version: ProtocolVersion}
+	var err error
+	if err = json.Unmarshal([]byte(line), &req); err != nil {
+		err = fmt.Errorf("invalid request: %w", err)
+	} else if req.Version > ProtocolVersion {
+		err = fmt.Errorf("unsupported protocol version %d (want %d)", req.Version, ProtocolVersion)
+	} else {
+		err = a.run(req, &resp)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.OK = true
+	}
+	_ = json.NewEncoder(c).Encode(resp)
+	if resp.OK && req.Command == CmdShutdown {
+		a.shutdown()
+	}
+}
+
+func (a *advertiser) run(req Request, resp *Response) error {
+	var err error
+	switch req.Command {
+	case CmdPing, CmdShutdown:
+		return nil
+	case CmdStatus:
+		st := a.srv.status()
+		st.Activated = a.activated
+		resp.Status = &st
+		return nil
+	case CmdLock:
+		_, err = a.srv.call("POST", "/lock")
+		// Whatever bw serve says, nothing of the vault stays in memory.
+		a.items.drop()
+		a.srv.generation.Add(1)
+		if err != nil {
+			return err
+		}
+		// The held session key died with the lock.
+		a.session.session("DELETE")
+		return nil
+	case CmdSync:
+		_, err = a.srv.call("POST", "/sync")
+		a.srv.generation.Add(1)
+		return err
+	case CmdList:
+		resp.Items, err = a.items.list(a.srv)
+		return err
+	case CmdSearch:
+		resp.Items, err = a.items.search(a.srv, req.Query)
+		return err
+	case CmdGet:
+		resp.Item, err = a.items.get(a.srv, req.ID)
+		return err
+	}
+	return fmt.Errorf("unknown command %q", req.Command)
+}
+
+// status reports on the server and asks bw serve about the vault.
+func (s *Server) status() Status {
+	s.mu.Lock()
+	st := Status{
+		Socket:        s.Endpoint.Socket,
+		PID:           os.Getpid(),
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+		Restarts:      s.restarts,
+		Vault:         "unknown",
+	}
+	if s.bw != nil {
+		st.BWPID = s.bw.cmd.Process.Pid
+	}
+	s.mu.Unlock()
+
+	data, err := s.call("GET", "/status")
+	if err != nil {
+		return st
+	}
+	tmpl, _ := data["template"].(map[string]interface{})
+	if v, _ := tmpl["status"].(string); v != "" {
+		st.Vault = v
+	}
+	st.Profile, _ = tmpl["userEmail"].(string)
+	st.Server, _ = tmpl["serverUrl"].(string)
+	st.LastSync, _ = tmpl["lastSync"].(string)
+	return st
+}
+
+// call sends a request straight to bw serve and returns the data of its
+// reply.
+func (s *Server) call(method, path string) (map[string]interface{}, error) {
+	req, err := http.NewRequest(method, "http://bw"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := UnixClient(s.bwSock)
+	client.Timeout = controlTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		Success bool                   `json:"success"`
+		Message string                 `json:"message"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	if !reply.Success {
+		return nil, fmt.Errorf("%s %s failed: %s", method, path, reply.Message)
+	}
+	return reply.Data, nil
+}
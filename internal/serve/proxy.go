@@ -0,0 +1,63 @@
+package serve
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// Endpoint is where the vault API can be reached: a Unix socket only the
+// current user can open, and the token each request must carry as
+// "Authorization: Bearer <token>".
+type Endpoint struct {
+	Socket string
+	Token  string
+}
+
+// UnixClient returns an HTTP client whose connections all go to socket,
+// whatever host the request URL names.
+func UnixClient(socket string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// proxy forwards authenticated requests to the bw serve socket. The token is
+// stripped before forwarding, so bw serve never sees it.
+func (s *Server) proxy(token string) http.Handler {
+	target := &url.URL{Scheme: "http", Host: "bw"}
+	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.Transport = UnixClient(s.bwSock).Transport
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r.Header.Del("Authorization")
+		s.lastUsed.Store(time.Now().UnixNano())
+		rp.ServeHTTP(w, r)
+		// Anything but a read may have unlocked, locked or edited the vault.
+		if r.Method != http.MethodGet {
+			s.generation.Add(1)
+		}
+	})
+}
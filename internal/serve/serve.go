@@ -1,15 +1,17 @@
 package serve
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/netbrain/mnu/internal/util"
@@ -17,97 +19,206 @@ import (
 
 const advertiseSock = "serve.sock"
 
-// Start launches `bw serve` on a random localhost port and waits for readiness.
-// The returned cmd is owned by the caller and should be killed on exit.
-func Start() (string, *exec.Cmd, error) {
-	l, err := net.Listen("tcp", "127.0.0.1:0")
+// startTimeout bounds how long bw serve may take to answer after starting.
+const startTimeout = 5 * time.Second
+
+// Server is a `bw serve` listening on a Unix socket in a private directory,
+// reachable only through the authenticating proxy at Endpoint.
+type Server struct {
+	Endpoint Endpoint
+
+	dir    string
+	bwSock string
+	ln     net.Listener
+
+	started  time.Time
+	lastUsed atomic.Int64 // unix nanoseconds of the last proxied request
+	// generation changes whenever the vault may have changed under the
+	// advertiser's item cache.
+	generation atomic.Uint64
+
+	mu       sync.Mutex
+	bw       *process
+	restarts int
+	closed   bool
+}
+
+// Start launches `bw serve` and the proxy in front of it and waits for
+// readiness. The caller owns the returned server and must Close it.
+func Start() (*Server, error) {
+	runtimeDir, err := util.GetRuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(runtimeDir, "mnu-serve-")
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to reserve a port: %w", err)
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	s := &Server{dir: dir, bwSock: filepath.Join(dir, "bw.sock"), started: time.Now()}
+	if err := s.startBW(); err != nil {
+		s.Close()
+		return nil, err
 	}
-	port := l.Addr().(*net.TCPAddr).Port
-	_ = l.Close()
 
-	cmd := exec.Command("bw", "serve", "--port", strconv.Itoa(port))
-	cmd.Env = os.Environ()
-	if err := cmd.Start(); err != nil {
-		return "", nil, fmt.Errorf("failed to start bw serve: %w", err)
+	token, err := newToken()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	apiSock := filepath.Join(dir, "api.sock")
+	if s.ln, err = util.ListenPrivate(apiSock); err != nil {
+		s.Close()
+		return nil, err
 	}
+	go http.Serve(s.ln, s.proxy(token))
+	s.Endpoint = Endpoint{Socket: apiSock, Token: token}
+	return s, nil
+}
 
-	apiURL := fmt.Sprintf("http://127.0.0.1:%d", port)
-	if err := waitReady(apiURL, 5*time.Second); err != nil {
-		_ = cmd.Process.Kill()
-		return "", nil, err
+// Close stops the proxy and bw serve and removes their sockets.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	p := s.bw
+	s.mu.Unlock()
+	if s.ln != nil {
+		s.ln.Close()
 	}
-	return apiURL, cmd, nil
+	p.stop()
+	_ = os.RemoveAll(s.dir)
 }
 
-// FindAdvertised connects to the Unix domain socket (if any) to obtain the API URL.
-func FindAdvertised() (string, bool) {
-	configDir, err := util.GetConfigDir()
+// FindAdvertised asks the advertiser socket (if any) for the API endpoint and
+// checks that the vault API behind it answers.
+func FindAdvertised() (Endpoint, bool) {
+	// A socket-activated advertiser only answers once bw serve is up.
+	reply, err := request("ENDPOINT", startTimeout+time.Second)
 	if err != nil {
-		return "", false
+		return Endpoint{}, false
 	}
-	sock := filepath.Join(configDir, advertiseSock)
-	conn, err := net.DialTimeout("unix", sock, 200*time.Millisecond)
-	if err != nil {
-		return "", false
+	socket, token, ok := strings.Cut(reply, " ")
+	if !ok || socket == "" || token == "" {
+		return Endpoint{}, false
 	}
-	defer conn.Close()
-	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
-	b, err := bufio.NewReader(conn).ReadBytes('\n')
-	if err != nil && len(b) == 0 {
-		return "", false
+	ep := Endpoint{Socket: socket, Token: token}
+	if err := ep.check(); err != nil {
+		return Endpoint{}, false
 	}
-	return string(bytes.TrimSpace(b)), true
+	return ep, true
 }
 
-// RunAdvertiser starts `bw serve`, then listens on a Unix socket to advertise its URL.
-// It blocks forever handling simple info requests from clients.
-func RunAdvertiser() error {
-	apiURL, cmd, err := Start()
+// Options adjust RunAdvertiser.
+type Options struct {
+	// IdleTimeout, if set, shuts the advertiser down once neither the
+	// advertiser socket nor the vault API has been used for this long.
+	IdleTimeout time.Duration
+}
+
+// RunAdvertiser listens on a Unix socket, or takes the one passed by systemd
+// socket activation, and starts `bw serve` under supervision behind it. It
+// blocks until interrupted, idle or told to shut down, handling requests
+// from clients (see session.go and control.go), including holding the
+// session key for the memory session store and answering item requests from
+// an in-memory list (see cache.go).
+func RunAdvertiser(opts Options) error {
+	ln, activated, err := activationListener()
 	if err != nil {
 		return err
 	}
-	defer func() { _ = cmd.Process.Kill() }()
+	if !activated {
+		configDir, err := util.GetConfigDir()
+		if err != nil {
+			return err
+		}
+		sock := filepath.Join(configDir, advertiseSock)
+		if ln, err = util.ListenPrivate(sock); err != nil {
+			return err
+		}
+		// An activated socket belongs to systemd and stays.
+		defer os.Remove(sock)
+	}
+	defer ln.Close()
 
-	configDir, err := util.GetConfigDir()
-	if err != nil {
-		return err
+	a := &advertiser{
+		activated: activated,
+		ready:     make(chan struct{}),
+		stopped:   make(chan struct{}),
 	}
-	sock := filepath.Join(configDir, advertiseSock)
-	// Remove any stale socket
-	_ = os.Remove(sock)
-	ln, err := net.Listen("unix", sock)
-	if err != nil {
-		return fmt.Errorf("failed to listen on socket: %w", err)
+	a.touch()
+	a.shutdown = func() {
+		a.once.Do(func() {
+			close(a.stopped)
+			ln.Close()
+		})
+	}
+
+	// Shut down on signals so bw serve and the sockets do not outlive us.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			a.shutdown()
+		case <-a.stopped:
+		}
+	}()
+
+	// Start bw serve while already accepting, so clients that woke a
+	// socket-activated service wait for it instead of being refused.
+	var startErr error
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		srv, err := Start()
+		if err != nil {
+			startErr = err
+			a.shutdown()
+			return
+		}
+		a.srv = srv
+		close(a.ready)
+		go srv.Supervise(a.stopped)
+		// Warm the item cache in case the vault is already unlocked.
+		go a.items.list(srv)
+	}()
+	defer func() {
+		<-started
+		if a.srv != nil {
+			a.srv.Close()
+		}
+	}()
+
+	if opts.IdleTimeout > 0 {
+		go a.watchIdle(opts.IdleTimeout)
 	}
-	defer func() { ln.Close(); _ = os.Remove(sock) }()
-	_ = os.Chmod(sock, 0600)
 
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			return err
+			select {
+			case <-a.stopped:
+				<-started
+				return startErr
+			default:
+				return err
+			}
 		}
+		a.touch()
 		go func(c net.Conn) {
 			defer c.Close()
-			_, _ = c.Write([]byte(apiURL + "\n"))
+			a.handle(c)
 		}(conn)
 	}
 }
 
-func waitReady(apiURL string, timeout time.Duration) error {
-	client := &http.Client{Timeout: time.Second}
+func waitReady(bwSock string, timeout time.Duration) error {
+	client := UnixClient(bwSock)
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
-		resp, err := client.Get(apiURL + "/status")
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode == http.StatusOK {
-				return nil
-			}
+		if checkStatus(client, "", time.Second) == nil {
+			return nil
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
-	return fmt.Errorf("bw serve did not become ready on %s within timeout", apiURL)
+	return errors.New("bw serve did not become ready within timeout")
 }
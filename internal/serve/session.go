@@ -0,0 +1,110 @@
+package serve
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netbrain/mnu/internal/util"
+)
+
+// Besides the JSON protocol (see control.go), clients may send one of these
+// command lines after connecting to the advertiser socket:
+//
+//	ENDPOINT            -> <api socket> <token>
+//	SESSION GET         -> OK <key> | ERR <message>
+//	SESSION SET <key>   -> OK
+//	SESSION DELETE      -> OK
+//
+// Connections that send nothing within commandWait are closed.
+const commandWait = time.Second
+
+var (
+	// ErrNotRunning means no advertiser is listening.
+	ErrNotRunning = errors.New("mnu-bw serve is not running")
+	// ErrNoSession means the advertiser holds no session key.
+	ErrNoSession = errors.New("no session key held by mnu-bw serve")
+)
+
+// sessionHolder is the advertiser's in-memory session key.
+type sessionHolder struct {
+	mu  sync.Mutex
+	key string
+}
+
+func (h *sessionHolder) session(arg string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	op, key, _ := strings.Cut(arg, " ")
+	switch op {
+	case "GET":
+		if h.key == "" {
+			return "ERR " + ErrNoSession.Error()
+		}
+		return "OK " + h.key
+	case "SET":
+		h.key = key
+		return "OK"
+	case "DELETE":
+		h.key = ""
+		return "OK"
+	}
+	return "ERR unknown session command"
+}
+
+// request sends one command to the advertiser and returns its reply line,
+// giving up after timeout.
+func request(command string, timeout time.Duration) (string, error) {
+	configDir, err := util.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.DialTimeout("unix", filepath.Join(configDir, advertiseSock), 200*time.Millisecond)
+	if err != nil {
+		return "", ErrNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func sessionRequest(command string) (string, error) {
+	reply, err := request(command, time.Second)
+	if err != nil {
+		return "", err
+	}
+	if msg, ok := strings.CutPrefix(reply, "ERR "); ok {
+		if msg == ErrNoSession.Error() {
+			return "", ErrNoSession
+		}
+		return "", errors.New(msg)
+	}
+	value, _ := strings.CutPrefix(reply, "OK")
+	return strings.TrimPrefix(value, " "), nil
+}
+
+// GetSession returns the session key held by the advertiser.
+func GetSession() (string, error) { return sessionRequest("SESSION GET") }
+
+// SetSession hands a session key to the advertiser.
+func SetSession(key string) error {
+	_, err := sessionRequest("SESSION SET " + key)
+	return err
+}
+
+// DeleteSession makes the advertiser forget its session key.
+func DeleteSession() error {
+	_, err := sessionRequest("SESSION DELETE")
+	return err
+}
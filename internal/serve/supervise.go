@@ -0,0 +1,196 @@
+package serve
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// healthInterval is how often Supervise polls /status.
+	healthInterval = 10 * time.Second
+	// maxFailedChecks unanswered polls in a row count as a hang.
+	maxFailedChecks = 3
+	// Restart delays double from minBackoff up to maxBackoff and reset once
+	// bw serve passes a health check.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// process is one run of `bw serve`.
+type process struct {
+	cmd    *exec.Cmd
+	stderr *tailBuffer
+	done   chan struct{}
+	err    error // exit status, valid once done is closed
+}
+
+// startBW runs `bw serve` on the server's socket and waits until it answers.
+func (s *Server) startBW() error {
+	_ = os.Remove(s.bwSock)
+	p := &process{
+		cmd:    exec.Command("bw", "serve", "--hostname", "unix://"+s.bwSock),
+		stderr: &tailBuffer{},
+		done:   make(chan struct{}),
+	}
+	p.cmd.Env = os.Environ()
+	p.cmd.Stderr = p.stderr
+	if err := p.cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start bw serve: %w", err)
+	}
+	go func() {
+		p.err = p.cmd.Wait()
+		close(p.done)
+	}()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		p.stop()
+		return errors.New("server closed")
+	}
+	s.bw = p
+	s.mu.Unlock()
+
+	if err := waitReady(s.bwSock, 5*time.Second); err != nil {
+		p.stop()
+		return err
+	}
+	return nil
+}
+
+// stop kills the process and waits for it to exit.
+func (p *process) stop() {
+	if p == nil {
+		return
+	}
+	_ = p.cmd.Process.Kill()
+	<-p.done
+}
+
+// exitReason describes why the process ended, with the end of its stderr.
+func (p *process) exitReason() string {
+	reason := fmt.Sprint(p.err)
+	if p.err == nil {
+		reason = "exited"
+	}
+	if tail := p.stderr.lastLine(); tail != "" {
+		reason += ": " + tail
+	}
+	return reason
+}
+
+// Supervise keeps bw serve running until stop is closed. It restarts bw
+// serve when it exits or stops answering /status, logging why and waiting
+// longer after each restart that does not come up healthy. The endpoint
+// stays the same, so clients are unaffected apart from having to unlock the
+// vault again.
+func (s *Server) Supervise(stop <-chan struct{}) {
+	tick := time.NewTicker(healthInterval)
+	defer tick.Stop()
+	backoff := minBackoff
+	failed := 0
+	for {
+		s.mu.Lock()
+		p := s.bw
+		s.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-p.done:
+			log.Printf("bw serve crashed: %s", p.exitReason())
+		case <-tick.C:
+			if err := checkStatus(UnixClient(s.bwSock), "", healthInterval/2); err != nil {
+				failed++
+				log.Printf("bw serve health check failed (%d/%d): %v", failed, maxFailedChecks, err)
+				if failed < maxFailedChecks {
+					continue
+				}
+				log.Println("bw serve is not answering")
+			} else {
+				failed = 0
+				backoff = minBackoff
+				continue
+			}
+		}
+
+		failed = 0
+		p.stop()
+		for {
+			log.Printf("Restarting bw serve in %s", backoff)
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			if err := s.startBW(); err != nil {
+				log.Printf("Failed to restart bw serve: %v", err)
+				continue
+			}
+			s.mu.Lock()
+			s.restarts++
+			s.mu.Unlock()
+			s.generation.Add(1)
+			log.Println("bw serve restarted; the vault must be unlocked again")
+			break
+		}
+	}
+}
+
+// check confirms that the vault API behind the endpoint answers.
+func (ep Endpoint) check() error {
+	return checkStatus(UnixClient(ep.Socket), ep.Token, 2*time.Second)
+}
+
+func checkStatus(client *http.Client, token string, timeout time.Duration) error {
+	client.Timeout = timeout
+	req, err := http.NewRequest("GET", "http://bw/status", nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status check failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// tailBuffer keeps the last few KiB written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const tailSize = 4096
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > tailSize {
+		t.buf = t.buf[len(t.buf)-tailSize:]
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) lastLine() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := strings.Split(strings.TrimSpace(string(t.buf)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
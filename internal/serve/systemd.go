@@ -0,0 +1,99 @@
+package serve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/netbrain/mnu/internal/util"
+)
+
+// Names of the systemd user units written by InstallSystemd.
+const (
+	ServiceUnit = "mnu-bw-serve.service"
+	SocketUnit  = "mnu-bw-serve.socket"
+)
+
+// DefaultIdleTimeout is the idle timeout written into the service unit when
+// none is given.
+const DefaultIdleTimeout = 30 * time.Minute
+
+// SystemdUnits returns the contents of the service and socket units that run
+// exe as a socket-activated `mnu-bw serve`, stopping after idle.
+func SystemdUnits(exe string, idle time.Duration) (service, socket string, err error) {
+	configDir, err := util.GetConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	// bw is found through the PATH of the installing shell; systemd's own
+	// is usually too short.
+	service = fmt.Sprintf(`[Unit]
+Description=mnu-bw serve: bw serve behind an authenticating proxy
+Requires=%[1]s
+After=%[1]s
+
+[Service]
+ExecStart=%[2]s serve --idle-timeout %[3]s
+Environment=PATH=%[4]s
+Restart=on-failure
+
+[Install]
+Also=%[1]s
+`, SocketUnit, systemdQuote(exe), idle, systemdEscape(os.Getenv("PATH")))
+	socket = fmt.Sprintf(`[Unit]
+Description=mnu-bw serve advertiser socket
+
+[Socket]
+ListenStream=%s
+SocketMode=0600
+DirectoryMode=0700
+
+[Install]
+WantedBy=sockets.target
+`, systemdEscape(filepath.Join(configDir, advertiseSock)))
+	return service, socket, nil
+}
+
+// InstallSystemd writes the units to the systemd user unit directory and
+// returns it.
+func InstallSystemd(exe string, idle time.Duration) (string, error) {
+	service, socket, err := SystemdUnits(exe, idle)
+	if err != nil {
+		return "", err
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	dir = filepath.Join(dir, "systemd", "user")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ServiceUnit), []byte(service), 0644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, SocketUnit), []byte(socket), 0644); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// systemdEscape escapes specifiers, which systemd expands in unit values.
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// systemdQuote quotes a command path containing spaces for ExecStart.
+func systemdQuote(s string) string {
+	s = systemdEscape(s)
+	if strings.ContainsAny(s, " \t\"\\") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package serve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSystemdUnits(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "50% off")
+	if err := os.MkdirAll(home, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", "/opt/100%/bin:/usr/bin")
+
+	service, socket, err := SystemdUnits("/opt/my apps/mnu-bw", 45*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantService := `[Unit]
+Description=mnu-bw serve: bw serve behind an authenticating proxy
+Requires=mnu-bw-serve.socket
+After=mnu-bw-serve.socket
+
+[Service]
+ExecStart="/opt/my apps/mnu-bw" serve --idle-timeout 45m0s
+Environment=PATH=/opt/100%%/bin:/usr/bin
+Restart=on-failure
+
+[Install]
+Also=mnu-bw-serve.socket
+`
+	if service != wantService {
+		t.Errorf("service unit:\n%s\nwant:\n%s", service, wantService)
+	}
+	wantSocket := `[Unit]
+Description=mnu-bw serve advertiser socket
+
+[Socket]
+ListenStream=` + filepath.Dir(home) + `/50%% off/.config/mnu/serve.sock
+SocketMode=0600
+DirectoryMode=0700
+
+[Install]
+WantedBy=sockets.target
+`
+	if socket != wantSocket {
+		t.Errorf("socket unit:\n%s\nwant:\n%s", socket, wantSocket)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/usr/bin/mnu-bw", "/usr/bin/mnu-bw"},
+		{"/opt/my apps/mnu-bw", `"/opt/my apps/mnu-bw"`},
+		{`/opt/a"b/mnu-bw`, `"/opt/a\"b/mnu-bw"`},
+		{`/opt/a\b/mnu-bw`, `"/opt/a\\b/mnu-bw"`},
+		{"/opt/100%/mnu-bw", "/opt/100%%/mnu-bw"},
+	}
+	for _, tt := range tests {
+		if got := systemdQuote(tt.in); got != tt.want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInstallSystemd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	got, err := InstallSystemd("/usr/bin/mnu-bw", DefaultIdleTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "systemd", "user"); got != want {
+		t.Errorf("InstallSystemd wrote to %s, want %s", got, want)
+	}
+	for _, unit := range []string{ServiceUnit, SocketUnit} {
+		if _, err := os.Stat(filepath.Join(got, unit)); err != nil {
+			t.Error(err)
+		}
+	}
+}
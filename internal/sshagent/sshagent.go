@@ -0,0 +1,250 @@
+// Package sshagent serves SSH keys stored in the Bitwarden vault over the
+// ssh-agent protocol. Keys are held in memory only and can be dropped at any
+// time, e.g. when the vault locks.
+package sshagent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+	ErrReadOnly = errors.New("keys are managed by the vault")
+	ErrDenied   = errors.New("signing request denied")
+)
+
+// ConfirmFunc is asked before every signature. Returning false denies it.
+type ConfirmFunc func(key *agent.Key) bool
+
+// Agent is an ssh-agent whose keys come from the vault. Clients cannot add
+// or remove keys.
+type Agent struct {
+	mu      sync.Mutex
+	keyring agent.ExtendedAgent
+	confirm ConfirmFunc
+}
+
+// New returns an empty agent. confirm may be nil to sign without asking.
+func New(confirm ConfirmFunc) *Agent {
+	return &Agent{keyring: agent.NewKeyring().(agent.ExtendedAgent), confirm: confirm}
+}
+
+// SetKeys replaces all keys held by the agent.
+func (a *Agent) SetKeys(keys []agent.AddedKey) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.keyring.RemoveAll(); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := a.keyring.Add(k); err != nil {
+			return fmt.Errorf("failed to add key %q: %w", k.Comment, err)
+		}
+	}
+	return nil
+}
+
+// Clear drops all keys.
+func (a *Agent) Clear() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_ = a.keyring.RemoveAll()
+}
+
+// Watch keeps the keys in step with the vault, checking it every interval
+// until stop is closed: keys are dropped as soon as the vault locks and
+// reloaded once it is unlocked again. The keys are assumed to be loaded.
+func (a *Agent) Watch(mgr bwpkg.Manager, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	loaded := true
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			loaded = a.refresh(mgr, loaded)
+		}
+	}
+}
+
+// refresh checks the vault once and reports whether keys are loaded after.
+func (a *Agent) refresh(mgr bwpkg.Manager, loaded bool) bool {
+	unlocked, err := bwpkg.VaultUnlocked(mgr)
+	if err != nil || !unlocked {
+		if loaded {
+			a.Clear()
+			log.Println("Vault locked; dropped all keys")
+		}
+		return false
+	}
+	if loaded {
+		return true
+	}
+	keys, err := LoadKeys(mgr)
+	if err == nil {
+		err = a.SetKeys(keys)
+	}
+	if err != nil {
+		log.Printf("Failed to reload keys: %v", err)
+		return false
+	}
+	log.Printf("Vault unlocked; loaded %d key(s)", len(keys))
+	return true
+}
+
+// Serve accepts connections on ln until it is closed.
+func (a *Agent) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			_ = agent.ServeAgent(a, c)
+		}(conn)
+	}
+}
+
+func (a *Agent) List() ([]*agent.Key, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.keyring.List()
+}
+
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+func (a *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	if a.confirm != nil {
+		k, err := a.find(key)
+		if err != nil {
+			return nil, err
+		}
+		if !a.confirm(k) {
+			return nil, ErrDenied
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.keyring.SignWithFlags(key, data, flags)
+}
+
+func (a *Agent) find(key ssh.PublicKey) (*agent.Key, error) {
+	keys, err := a.List()
+	if err != nil {
+		return nil, err
+	}
+	want := key.Marshal()
+	for _, k := range keys {
+		if bytes.Equal(k.Blob, want) {
+			return k, nil
+		}
+	}
+	return nil, errors.New("key not found")
+}
+
+func (a *Agent) Signers() ([]ssh.Signer, error) {
+	// Signers bypass confirmation, so they are not handed out.
+	return nil, ErrReadOnly
+}
+
+func (a *Agent) Add(key agent.AddedKey) error   { return ErrReadOnly }
+func (a *Agent) Remove(key ssh.PublicKey) error { return ErrReadOnly }
+func (a *Agent) RemoveAll() error               { return ErrReadOnly }
+func (a *Agent) Lock(passphrase []byte) error   { return ErrReadOnly }
+func (a *Agent) Unlock(passphrase []byte) error { return ErrReadOnly }
+func (a *Agent) Extension(string, []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
+
+var pemKeyPattern = regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----.*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)
+
+// maxKeyAttachment bounds the size of attachments considered as key files.
+const maxKeyAttachment = 64 * 1024
+
+// LoadKeys collects private keys from the vault: SSH key items, PEM blocks in
+// notes, and attachments that look like key files (id_*, *.pem, *.key).
+// Passphrase-protected keys are skipped.
+func LoadKeys(mgr bwpkg.Manager) ([]agent.AddedKey, error) {
+	raw, err := mgr.GetItems()
+	if err != nil {
+		return nil, err
+	}
+	var keys []agent.AddedKey
+	add := func(pem []byte, comment string) {
+		priv, err := ssh.ParseRawPrivateKey(pem)
+		if err != nil {
+			log.Printf("Skipping key %q: %v", comment, err)
+			return
+		}
+		keys = append(keys, agent.AddedKey{PrivateKey: priv, Comment: comment})
+	}
+	for _, m := range raw {
+		item := bwpkg.ItemFromMap(m)
+		if sshKey, ok := m["sshKey"].(map[string]interface{}); ok {
+			if pk, ok := sshKey["privateKey"].(string); ok && pk != "" {
+				add([]byte(pk), item.Name)
+			}
+		}
+		if notes, ok := m["notes"].(string); ok {
+			for _, block := range pemKeyPattern.FindAllString(notes, -1) {
+				add([]byte(block), item.Name)
+			}
+		}
+		attachments, _ := m["attachments"].([]interface{})
+		for _, at := range attachments {
+			am, ok := at.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := am["id"].(string)
+			name, _ := am["fileName"].(string)
+			if id == "" || !looksLikeKeyFile(name) || attachmentSize(am) > maxKeyAttachment {
+				continue
+			}
+			data, err := mgr.GetAttachment(item.ID, id)
+			if err != nil {
+				log.Printf("Skipping attachment %q of %q: %v", name, item.Name, err)
+				continue
+			}
+			if pemKeyPattern.Match(data) {
+				add(data, item.Name+" ("+name+")")
+			}
+		}
+	}
+	return keys, nil
+}
+
+func looksLikeKeyFile(name string) bool {
+	base := strings.ToLower(path.Base(name))
+	if strings.HasSuffix(base, ".pub") {
+		return false
+	}
+	return strings.HasPrefix(base, "id_") || strings.HasSuffix(base, ".pem") || strings.HasSuffix(base, ".key")
+}
+
+func attachmentSize(am map[string]interface{}) int {
+	var n int
+	switch v := am["size"].(type) {
+	case string:
+		fmt.Sscan(v, &n)
+	case float64:
+		n = int(v)
+	}
+	return n
+}
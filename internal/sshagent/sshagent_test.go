@@ -0,0 +1,311 @@
+package sshagent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	"github.com/netbrain/mnu/internal/util"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// fakeVault is a Manager over items and attachments held in memory.
+type fakeVault struct {
+	bwpkg.Manager // panics on anything the tests do not expect
+	items         []map[string]interface{}
+	attachments   map[string][]byte // by item ID + "/" + attachment ID
+	locked        bool
+}
+
+func (v *fakeVault) IsLoggedIn() (bool, error) { return !v.locked, nil }
+
+func (v *fakeVault) GetItems() ([]map[string]interface{}, error) { return v.items, nil }
+
+func (v *fakeVault) GetAttachment(itemID, attachmentID string) ([]byte, error) {
+	data, ok := v.attachments[itemID+"/"+attachmentID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func newEd25519(t *testing.T) (ssh.PublicKey, []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshPub, pem.EncodeToMemory(block)
+}
+
+func newRSA(t *testing.T) (ssh.PublicKey, []byte) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshPub, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+}
+
+// keyVault holds one key of every kind LoadKeys understands, plus some it
+// must skip. It returns the public keys expected by comment.
+func keyVault(t *testing.T) (*fakeVault, map[string]ssh.PublicKey) {
+	t.Helper()
+	itemPub, itemKey := newEd25519(t)
+	notePub, noteKey := newRSA(t)
+	filePub, fileKey := newEd25519(t)
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := pem.EncodeToMemory(block)
+
+	v := &fakeVault{
+		items: []map[string]interface{}{
+			{"id": "1", "name": "deploy", "type": float64(5), "sshKey": map[string]interface{}{"privateKey": string(itemKey)}},
+			{"id": "2", "name": "server notes", "type": float64(2), "notes": "root on db1:\n" + string(noteKey) + "\nrotate yearly"},
+			{"id": "3", "name": "laptop", "type": float64(2), "attachments": []interface{}{
+				map[string]interface{}{"id": "a", "fileName": "id_ed25519", "size": "411"},
+				map[string]interface{}{"id": "b", "fileName": "id_ed25519.pub", "size": "100"},
+				map[string]interface{}{"id": "c", "fileName": "huge.key", "size": "1048576"},
+				map[string]interface{}{"id": "d", "fileName": "photo.jpg", "size": "1000"},
+			}},
+			{"id": "4", "name": "protected", "type": float64(5), "sshKey": map[string]interface{}{"privateKey": string(encrypted)}},
+			{"id": "5", "name": "website", "type": float64(1), "notes": "no keys here"},
+		},
+		attachments: map[string][]byte{
+			"3/a": fileKey,
+			"3/b": []byte("ssh-ed25519 AAAA laptop"),
+			"3/c": fileKey,
+			"3/d": fileKey,
+		},
+	}
+	return v, map[string]ssh.PublicKey{
+		"deploy":              itemPub,
+		"server notes":        notePub,
+		"laptop (id_ed25519)": filePub,
+	}
+}
+
+func checkKeys(t *testing.T, keys []*agent.Key, want map[string]ssh.PublicKey) {
+	t.Helper()
+	var got []string
+	for _, k := range keys {
+		got = append(got, k.Comment)
+		pub, ok := want[k.Comment]
+		if !ok {
+			t.Errorf("unexpected key %q", k.Comment)
+			continue
+		}
+		if string(k.Blob) != string(pub.Marshal()) {
+			t.Errorf("key %q has the wrong public key", k.Comment)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got keys %q, want %d keys", got, len(want))
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	v, want := keyVault(t)
+	keys, err := LoadKeys(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(nil)
+	if err := a.SetKeys(keys); err != nil {
+		t.Fatal(err)
+	}
+	list, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKeys(t, list, want)
+}
+
+func TestLooksLikeKeyFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"id_rsa":          true,
+		"ssh/ID_ED25519":  true,
+		"server.pem":      true,
+		"tls.key":         true,
+		"id_rsa.pub":      false,
+		"notes.txt":       false,
+		"keys.tar.gz":     false,
+		"id_ed25519.PUB":  false,
+		"backup/host.key": true,
+	} {
+		if got := looksLikeKeyFile(name); got != want {
+			t.Errorf("looksLikeKeyFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRefreshFollowsLock(t *testing.T) {
+	v, want := keyVault(t)
+	a := New(nil)
+	keys, err := LoadKeys(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetKeys(keys); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := a.refresh(v, true)
+	if list, _ := a.List(); !loaded || len(list) != len(want) {
+		t.Fatalf("while unlocked: loaded = %v with %d keys, want %d keys", loaded, len(list), len(want))
+	}
+
+	v.locked = true
+	loaded = a.refresh(v, loaded)
+	if list, _ := a.List(); loaded || len(list) != 0 {
+		t.Fatalf("after locking: loaded = %v with %d keys, want none", loaded, len(list))
+	}
+	loaded = a.refresh(v, loaded)
+	if list, _ := a.List(); loaded || len(list) != 0 {
+		t.Fatalf("still locked: loaded = %v with %d keys, want none", loaded, len(list))
+	}
+
+	v.locked = false
+	loaded = a.refresh(v, loaded)
+	list, _ := a.List()
+	if !loaded {
+		t.Fatal("after unlocking: keys not reloaded")
+	}
+	checkKeys(t, list, want)
+}
+
+// serve runs a on a private socket and returns a client connected to it.
+func serve(t *testing.T, a *Agent) agent.ExtendedAgent {
+	t.Helper()
+	ln, err := util.ListenPrivate(filepath.Join(t.TempDir(), "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go a.Serve(ln)
+	conn, err := net.Dial("unix", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return agent.NewClient(conn)
+}
+
+func TestClient(t *testing.T) {
+	v, want := keyVault(t)
+	keys, err := LoadKeys(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(nil)
+	if err := a.SetKeys(keys); err != nil {
+		t.Fatal(err)
+	}
+	client := serve(t, a)
+
+	// What ssh-add -l shows.
+	list, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKeys(t, list, want)
+
+	data := []byte("session data")
+	for comment, pub := range want {
+		sig, err := client.Sign(pub, data)
+		if err != nil {
+			t.Errorf("Sign with %q: %v", comment, err)
+			continue
+		}
+		if err := pub.Verify(data, sig); err != nil {
+			t.Errorf("signature by %q does not verify: %v", comment, err)
+		}
+	}
+
+	// The vault owns the keys; clients cannot change them.
+	_, extra := newEd25519(t)
+	priv, err := ssh.ParseRawPrivateKey(extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Add(agent.AddedKey{PrivateKey: priv}); err == nil {
+		t.Error("Add succeeded")
+	}
+	if err := client.RemoveAll(); err == nil {
+		t.Error("RemoveAll succeeded")
+	}
+	if list, _ := client.List(); len(list) != len(want) {
+		t.Errorf("client changed the keys: %d left, want %d", len(list), len(want))
+	}
+
+	a.Clear()
+	if list, err := client.List(); err != nil || len(list) != 0 {
+		t.Errorf("List after Clear = %d keys, %v, want none", len(list), err)
+	}
+}
+
+func TestClientConfirm(t *testing.T) {
+	pub, key := newEd25519(t)
+	priv, err := ssh.ParseRawPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		asked []string
+		allow bool
+	)
+	a := New(func(k *agent.Key) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		asked = append(asked, k.Comment)
+		return allow
+	})
+	if err := a.SetKeys([]agent.AddedKey{{PrivateKey: priv, Comment: "deploy"}}); err != nil {
+		t.Fatal(err)
+	}
+	client := serve(t, a)
+
+	if _, err := client.Sign(pub, []byte("data")); err == nil {
+		t.Error("Sign succeeded although the request was denied")
+	}
+	mu.Lock()
+	allow = true
+	mu.Unlock()
+	if _, err := client.Sign(pub, []byte("data")); err != nil {
+		t.Errorf("Sign after confirmation: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(asked, []string{"deploy", "deploy"}) {
+		t.Errorf("confirm asked for %q, want deploy twice", asked)
+	}
+}
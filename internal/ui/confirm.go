@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	style "github.com/netbrain/mnu/internal/style"
+)
+
+type confirmModel struct {
+	question string
+	answer   bool
+}
+
+type confirmTimeoutMsg struct{}
+
+func (m confirmModel) Init() tea.Cmd { return nil }
+
+func (m confirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case confirmTimeoutMsg:
+		return m, tea.Quit
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "y", "Y":
+			m.answer = true
+			return m, tea.Quit
+		case "n", "N", "enter", "esc", "ctrl+c":
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m confirmModel) View() string {
+	return style.DocStyle.Render(m.question + " [y/N]")
+}
+
+// Confirm asks a yes/no question on the controlling terminal (/dev/tty) and
+// reports the answer. No answer within timeout counts as no.
+func Confirm(question string, timeout time.Duration) (bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer tty.Close()
+
+	p := tea.NewProgram(confirmModel{question: question}, tea.WithInput(tty), tea.WithOutput(tty))
+	go func() {
+		time.Sleep(timeout)
+		p.Send(confirmTimeoutMsg{})
+	}()
+	final, err := p.Run()
+	if err != nil {
+		return false, err
+	}
+	return final.(confirmModel).answer, nil
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	cfgpkg "github.com/netbrain/mnu/internal/config"
+	"github.com/netbrain/mnu/internal/pin"
+)
+
+type pinUnlockResultMsg struct{ err error }
+
+type pinSetMsg struct{ err error }
+
+// pinSupported reports whether PIN unlock is enabled and the manager can
+// resume a session from a stored key.
+func (m model) pinSupported() bool {
+	if !m.cfg.PinUnlock {
+		return false
+	}
+	_, ok := m.manager.(bwpkg.SessionUnlocker)
+	return ok
+}
+
+// pinUnavailable explains why pin_unlock does nothing when it is set but the
+// manager cannot resume a session, as in API mode.
+func (m model) pinUnavailable() string {
+	if !m.cfg.PinUnlock || m.pinSupported() {
+		return ""
+	}
+	return "pin_unlock has no effect in API mode; set api_mode: false to unlock with a PIN"
+}
+
+// unlockState picks the PIN prompt when a PIN is set, else the password.
+func (m model) unlockState() viewState {
+	if m.pinSupported() && pin.Available() {
+		return statePinPrompt
+	}
+	return stateUnlockPrompt
+}
+
+func (m model) statusLine() string {
+	if m.status == "" {
+		return ""
+	}
+	return "\n\n" + m.status
+}
+
+func (m model) updatePinPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		v := m.pinInput.Value()
+		if v == "" {
+			m.status = "PIN cannot be empty"
+			return m, nil
+		}
+		m.pinInput.SetValue("")
+		m.status = "Unlocking…"
+		return m, pinUnlockCmd(m.manager, v)
+	case tea.KeyTab:
+		m.pinInput.SetValue("")
+		m.status = ""
+		m.state = stateUnlockPrompt
+		return m, nil
+	case tea.KeyEsc, tea.KeyCtrlC:
+		return m, tea.Quit
+	default:
+		var cmd tea.Cmd
+		m.pinInput, cmd = m.pinInput.Update(msg)
+		return m, cmd
+	}
+}
+
+func (m model) updateSetPin(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		v := m.pinInput.Value()
+		if v != "" && len(v) < pin.MinLength {
+			m.status = pin.ErrTooShort.Error()
+			return m, nil
+		}
+		m.pinInput.SetValue("")
+		m.status = ""
+		if v == "" {
+			// The old PIN wraps a session that no longer exists.
+			_ = pin.Wipe()
+			m.session = ""
+			m.state = stateLoadingItems
+			return m, loadItemsCmd(m.manager)
+		}
+		return m, setPinCmd(v, m.session, m.cfg)
+	case tea.KeyEsc:
+		m.pinInput.SetValue("")
+		_ = pin.Wipe()
+		m.session = ""
+		m.state = stateLoadingItems
+		return m, loadItemsCmd(m.manager)
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	default:
+		var cmd tea.Cmd
+		m.pinInput, cmd = m.pinInput.Update(msg)
+		return m, cmd
+	}
+}
+
+func (m model) handlePinUnlock(msg pinUnlockResultMsg) (tea.Model, tea.Cmd) {
+	var wrong *pin.WrongPINError
+	switch {
+	case msg.err == nil:
+		m.status = ""
+		m.state = stateLoadingItems
+		return m, loadItemsCmd(m.manager)
+	case errors.As(msg.err, &wrong):
+		m.status = fmt.Sprintf("Wrong PIN (%d attempt(s) left)", wrong.Remaining)
+		return m, nil
+	case errors.Is(msg.err, bwpkg.ErrSessionInvalid):
+		_ = pin.Wipe()
+		m.status = "The vault was locked since the PIN was set; enter the master password"
+	case errors.Is(msg.err, pin.ErrWiped):
+		m.status = "Too many wrong PINs; enter the master password"
+	case errors.Is(msg.err, pin.ErrNoPIN):
+		m.status = "PIN expired; enter the master password"
+	default:
+		m.status = fmt.Sprintf("PIN unlock failed: %v", msg.err)
+	}
+	m.state = stateUnlockPrompt
+	return m, nil
+}
+
+func pinUnlockCmd(mgr bwpkg.Manager, p string) tea.Cmd {
+	return func() tea.Msg {
+		unlocker, ok := mgr.(bwpkg.SessionUnlocker)
+		if !ok {
+			return pinUnlockResultMsg{err: errors.New("PIN unlock is not supported in API mode")}
+		}
+		session, err := pin.Unlock(p)
+		if err != nil {
+			return pinUnlockResultMsg{err: err}
+		}
+		return pinUnlockResultMsg{err: unlocker.UnlockWithSession(session)}
+	}
+}
+
+func setPinCmd(p, session string, cfg *cfgpkg.Config) tea.Cmd {
+	return func() tea.Msg {
+		return pinSetMsg{err: pin.Set(p, session, cfg.PinTTL, cfg.PinMaxAttempts)}
+	}
+}
@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"errors"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	bwpkg "github.com/netbrain/mnu/internal/bw"
+	"github.com/netbrain/mnu/internal/keychain"
+	"github.com/netbrain/mnu/internal/pin"
+)
+
+// sessionCheckInterval is how often an open TUI looks at the session TTL.
+const sessionCheckInterval = 30 * time.Second
+
+type sessionTickMsg struct{}
+
+type sessionExpiredMsg struct{}
+
+func sessionTickCmd() tea.Cmd {
+	return tea.Tick(sessionCheckInterval, func(time.Time) tea.Msg { return sessionTickMsg{} })
+}
+
+// checkSessionCmd reports an expired session. IsLoggedIn has already locked
+// the vault by then.
+func checkSessionCmd(mgr bwpkg.Manager) tea.Cmd {
+	return func() tea.Msg {
+		if _, err := mgr.IsLoggedIn(); errors.Is(err, keychain.ErrSessionExpired) {
+			return sessionExpiredMsg{}
+		}
+		return nil
+	}
+}
+
+// handleSessionTick checks the session while the vault is being browsed.
+func (m model) handleSessionTick() (tea.Model, tea.Cmd) {
+	switch m.state {
+	case stateList, stateActionMenu, stateReport:
+		return m, tea.Batch(sessionTickCmd(), checkSessionCmd(m.manager))
+	}
+	return m, sessionTickCmd()
+}
+
+// sessionExpired forgets the loaded items and asks for the master password.
+// The PIN wraps the session that was just locked, so it goes too.
+func (m model) sessionExpired() (tea.Model, tea.Cmd) {
+	_ = pin.Wipe()
+	m.allItems = nil
+	m.visibleItems = nil
+	m.list.SetItems(nil)
+	m.search.SetValue("")
+	m.selected = bwListItem{}
+	m.status = "Session expired; enter the master password"
+	m.state = m.unlockState()
+	return m, nil
+}
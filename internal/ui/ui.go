@@ -1,7 +1,9 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -9,9 +11,11 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/netbrain/mnu/internal/audit"
 	bwpkg "github.com/netbrain/mnu/internal/bw"
 	clipboard "github.com/netbrain/mnu/internal/clipboard"
 	cfgpkg "github.com/netbrain/mnu/internal/config"
+	"github.com/netbrain/mnu/internal/keychain"
 	style "github.com/netbrain/mnu/internal/style"
 )
 
@@ -24,11 +28,16 @@ const (
 	stateActionMenu
 	stateCopying
 	stateDone
+	stateAuditing
+	stateReport
+	statePinPrompt
+	stateSetPin
 )
 
 type viewState int
 
 type bwListItem struct {
+	item        bwpkg.Item
 	id          string
 	title       string
 	username    string
@@ -53,9 +62,34 @@ func (a actionItem) Title() string       { return a.label }
 func (a actionItem) Description() string { return "" }
 func (a actionItem) FilterValue() string { return a.label }
 
+type reportItem struct{ finding audit.Finding }
+
+func (r reportItem) Title() string {
+	return fmt.Sprintf("[%s] %s", r.finding.Kind, r.finding.Item.Name)
+}
+func (r reportItem) Description() string { return r.finding.Detail }
+func (r reportItem) FilterValue() string { return r.finding.Item.Name }
+
+// Options adjust how the TUI starts.
+type Options struct {
+	// URL, if set, limits the list to items with a login URI matching it,
+	// until the user clears the filter with Ctrl-X.
+	URL string
+	// Query is the initial search text.
+	Query string
+	// IDs, if set, limits the list to these items.
+	IDs []string
+	// Pick makes Enter choose the selected item and quit instead of opening
+	// the action menu. See Pick.
+	Pick bool
+	// TTY is the terminal Pick runs on; /dev/tty when empty.
+	TTY string
+}
+
 type model struct {
 	manager bwpkg.Manager
 	cfg     *cfgpkg.Config
+	opts    Options
 
 	state  viewState
 	width  int
@@ -63,9 +97,12 @@ type model struct {
 
 	// login
 	password textinput.Model
+	pinInput textinput.Model
+	session  string // fresh session key, held until a PIN is set or skipped
 
 	// list browsing
 	allItems     []bwListItem
+	vaultItems   []bwListItem // allItems before the URL filter
 	visibleItems []bwListItem
 	list         list.Model
 	search       textinput.Model
@@ -73,6 +110,13 @@ type model struct {
 	// action menu
 	selected bwListItem
 	actions  list.Model
+	menuFrom viewState // state to return to on Esc
+
+	// vault health report
+	report list.Model
+
+	// picker result
+	picked string
 
 	// feedback
 	status string
@@ -108,11 +152,16 @@ type copyResultMsg struct {
 	err    error
 }
 
+type auditResultMsg struct {
+	findings []audit.Finding
+	err      error
+}
+
 type copyIndicatorClearMsg struct{ gen int }
 type copyIndicatorTickMsg struct{ gen int }
 
 // InitialModel constructs the UI model to be passed to tea.NewProgram.
-func InitialModel(manager bwpkg.Manager, cfg *cfgpkg.Config) tea.Model {
+func InitialModel(manager bwpkg.Manager, cfg *cfgpkg.Config, opts Options) tea.Model {
 	// password input
 	pw := textinput.New()
 	pw.Placeholder = "Enter your Bitwarden master password"
@@ -121,6 +170,13 @@ func InitialModel(manager bwpkg.Manager, cfg *cfgpkg.Config) tea.Model {
 	pw.EchoCharacter = '•'
 	pw.Focus()
 
+	// PIN input
+	pi := textinput.New()
+	pi.Prompt = "PIN: "
+	pi.EchoMode = textinput.EchoPassword
+	pi.EchoCharacter = '•'
+	pi.Focus()
+
 	// search input
 	si := textinput.New()
 	si.Placeholder = "Search..."
@@ -141,18 +197,31 @@ func InitialModel(manager bwpkg.Manager, cfg *cfgpkg.Config) tea.Model {
 	act.SetShowHelp(false)
 	act.SetShowPagination(false)
 
+	// report list
+	rep := list.New([]list.Item{}, style.NewListDelegate(), 0, 0)
+	rep.SetShowTitle(false)
+	rep.SetShowStatusBar(false)
+	rep.SetFilteringEnabled(false)
+	rep.SetShowHelp(false)
+
 	return model{
 		manager:  manager,
 		cfg:      cfg,
+		opts:     opts,
 		state:    stateCheckingLogin,
 		password: pw,
+		pinInput: pi,
 		search:   si,
 		list:     l,
 		actions:  act,
+		report:   rep,
 	}
 }
 
 func (m model) Init() tea.Cmd {
+	if m.cfg.SessionTTL > 0 {
+		return tea.Batch(checkLoginCmd(m.manager), sessionTickCmd())
+	}
 	return checkLoginCmd(m.manager)
 }
 
@@ -173,6 +242,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			pwWidth = 1
 		}
 		m.password.Width = pwWidth
+		m.pinInput.Width = max(1, contentWidth-lipgloss.Width(m.pinInput.Prompt))
 
 		searchWidth := contentWidth - lipgloss.Width(m.search.Prompt)
 		if searchWidth < 1 {
@@ -186,14 +256,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else if m.state == stateActionMenu {
 			// Fit to exactly the number of actions (single-line items)
 			m.actions.SetSize(m.width, max(1, len(m.actions.Items())))
+		} else if m.state == stateReport {
+			m.report.SetSize(m.width, max(5, m.height-4))
 		}
 		return m, nil
 
 	case loginStatusMsg:
+		if errors.Is(msg.err, keychain.ErrSessionExpired) {
+			return m.sessionExpired()
+		}
 		if msg.err != nil {
 			m.err = msg.err
 			m.status = fmt.Sprintf("Error checking login: %v", msg.err)
-			m.state = stateUnlockPrompt
+			m.state = m.unlockState()
 			return m, nil
 		}
 		if msg.loggedIn {
@@ -201,7 +276,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, loadItemsCmd(m.manager)
 		}
 		// not logged in
-		m.state = stateUnlockPrompt
+		m.status = m.pinUnavailable()
+		m.state = m.unlockState()
 		return m, nil
 
 	case unlockResultMsg:
@@ -210,8 +286,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// keep in prompt
 			return m, nil
 		}
-		// success; load items
+		// success; offer a PIN, then load items
 		m.password.SetValue("")
+		m.status = ""
+		if m.pinSupported() {
+			m.session = msg.session
+			m.state = stateSetPin
+			return m, nil
+		}
+		m.state = stateLoadingItems
+		return m, loadItemsCmd(m.manager)
+
+	case pinUnlockResultMsg:
+		return m.handlePinUnlock(msg)
+
+	case sessionTickMsg:
+		return m.handleSessionTick()
+
+	case sessionExpiredMsg:
+		return m.sessionExpired()
+
+	case pinSetMsg:
+		m.session = ""
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Failed to set PIN: %v", msg.err)
+		}
 		m.state = stateLoadingItems
 		return m, loadItemsCmd(m.manager)
 
@@ -221,12 +320,38 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		m.allItems = msg.items
-		m.visibleItems = msg.items
-		items := make([]list.Item, len(m.visibleItems))
-		for i := range m.visibleItems {
-			items[i] = m.visibleItems[i]
+		if len(m.opts.IDs) > 0 {
+			ids := make(map[string]bool, len(m.opts.IDs))
+			for _, id := range m.opts.IDs {
+				ids[id] = true
+			}
+			var only []bwListItem
+			for _, it := range m.allItems {
+				if ids[it.id] {
+					only = append(only, it)
+				}
+			}
+			m.allItems = only
+		}
+		m.vaultItems = m.allItems
+		if m.opts.URL != "" {
+			var matched []bwListItem
+			for _, it := range m.allItems {
+				if it.item.MatchesURL(m.opts.URL) {
+					matched = append(matched, it)
+				}
+			}
+			m.allItems = matched
+			if len(matched) == 0 {
+				m.status = fmt.Sprintf("No items match %s (Ctrl-X: show all items)", m.opts.URL)
+			} else {
+				m.status = fmt.Sprintf("Items matching %s (Ctrl-X: show all items)", m.opts.URL)
+			}
+		}
+		if q := strings.TrimSpace(m.opts.Query); q != "" {
+			m.search.SetValue(q)
 		}
-		m.list.SetItems(items)
+		m = m.refilter()
 		m.state = stateList
 		// Focus the search input so typing filters immediately
 		m.password.Blur()
@@ -236,6 +361,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case auditResultMsg:
+		if m.state != stateAuditing {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Audit failed: %v", msg.err)
+			m.state = stateList
+			return m, nil
+		}
+		items := make([]list.Item, len(msg.findings))
+		for i, f := range msg.findings {
+			items[i] = reportItem{finding: f}
+		}
+		m.report.SetItems(items)
+		m.report.Select(0)
+		if m.width > 0 && m.height > 0 {
+			m.report.SetSize(m.width, max(5, m.height-4))
+		}
+		m.state = stateReport
+		return m, nil
+
 	case copyResultMsg:
 		// After copying, show an icon and countdown until clipboard is cleared.
 		if msg.err == nil {
@@ -304,6 +450,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, cmd
 			}
 
+		case statePinPrompt:
+			return m.updatePinPrompt(msg)
+
+		case stateSetPin:
+			return m.updateSetPin(msg)
+
 		case stateList:
 			switch msg.Type {
 			case tea.KeyCtrlC:
@@ -324,43 +476,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			case tea.KeyEnter:
 				if itm, ok := m.list.SelectedItem().(bwListItem); ok {
-					m.selected = itm
-					// build actions in order: Password, Username, OTP (if available)
-					acts := []list.Item{actionItem{label: "Password", kind: "password"}, actionItem{label: "Username", kind: "username"}}
-					if itm.hasTotp {
-						acts = append(acts, actionItem{label: "OTP", kind: "otp"})
+					if m.opts.Pick {
+						m.picked = itm.id
+						return m, tea.Quit
 					}
-					// set actions list and preselect Password (index 0)
-					m.actions.SetItems(m.buildActions())
-					m.actions.Select(0)
-					// size to show exactly all actions (single-line)
-					if m.width > 0 {
-						m.actions.SetSize(m.width, max(1, len(m.actions.Items())))
-					}
-					m.state = stateActionMenu
-					return m, nil
+					return m.openActionMenu(itm), nil
 				}
+			case tea.KeyCtrlR:
+				// Vault health report
+				m.state = stateAuditing
+				return m, auditCmd(m.manager, m.cfg)
+			case tea.KeyCtrlX:
+				// Drop the URL filter and show the whole vault.
+				if m.opts.URL != "" {
+					m.opts.URL = ""
+					m.allItems = m.vaultItems
+					m.status = ""
+					return m.refilter(), nil
+				}
+				return m, nil
 			default:
 				// Update search input first (it's focused)
 				var cmd tea.Cmd
 				m.search, cmd = m.search.Update(msg)
-				// Re-filter based on search query
-				q := strings.ToLower(strings.TrimSpace(m.search.Value()))
-				if q == "" {
-					m.visibleItems = m.allItems
-				} else {
-					m.visibleItems = make([]bwListItem, 0, len(m.allItems))
-					for _, it := range m.allItems {
-						if strings.Contains(strings.ToLower(it.title+" "+it.desc), q) {
-							m.visibleItems = append(m.visibleItems, it)
-						}
-					}
-				}
-				li := make([]list.Item, len(m.visibleItems))
-				for i := range m.visibleItems {
-					li[i] = m.visibleItems[i]
-				}
-				m.list.SetItems(li)
+				m = m.refilter()
 				// Forward only navigation keys to the list so typing doesn't get eaten
 				if isListNavKey(msg) {
 					m.list, _ = m.list.Update(msg)
@@ -368,13 +507,44 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, cmd
 			}
 
-		case stateActionMenu:
+		case stateAuditing:
+			switch msg.Type {
+			case tea.KeyCtrlC:
+				return m, tea.Quit
+			case tea.KeyEsc:
+				m.state = stateList
+				return m, nil
+			}
+			return m, nil
+
+		case stateReport:
 			switch msg.Type {
 			case tea.KeyCtrlC:
 				return m, tea.Quit
 			case tea.KeyEsc:
 				m.state = stateList
 				return m, nil
+			case tea.KeyEnter:
+				if it, ok := m.report.SelectedItem().(reportItem); ok {
+					return m.openActionMenu(m.listItemByID(it.finding.Item)), nil
+				}
+				return m, nil
+			default:
+				if isListNavKey(msg) {
+					var cmd tea.Cmd
+					m.report, cmd = m.report.Update(msg)
+					return m, cmd
+				}
+				return m, nil
+			}
+
+		case stateActionMenu:
+			switch msg.Type {
+			case tea.KeyCtrlC:
+				return m, tea.Quit
+			case tea.KeyEsc:
+				m.state = m.menuFrom
+				return m, nil
 			case tea.KeyEnter:
 				if it, ok := m.actions.SelectedItem().(actionItem); ok {
 					return m, m.copyCmd(it.kind, m.selected.id, m.selected.username)
@@ -415,13 +585,24 @@ func (m model) View() string {
 	case stateCheckingLogin:
 		return style.DocStyle.Render("Checking login status…")
 	case stateUnlockPrompt:
-		return style.DocStyle.Render(m.password.View())
+		return style.DocStyle.Render(m.password.View() + m.statusLine())
+	case statePinPrompt:
+		return style.DocStyle.Render(m.pinInput.View() + "\n\n(Tab: use master password)" + m.statusLine())
+	case stateSetPin:
+		return style.DocStyle.Render("Set a PIN for quick unlock (Enter to skip)\n" + m.pinInput.View() + m.statusLine())
 	case stateLoadingItems:
 		return style.DocStyle.Render("Loading items…")
 	case stateList:
-		return style.DocStyle.Render(m.search.View() + "\n\n" + m.list.View())
+		return style.DocStyle.Render(m.search.View() + m.statusLine() + "\n\n" + m.list.View())
 	case stateActionMenu:
 		return style.DocStyle.Render("Selected: " + m.selected.title + "\n" + m.actions.View())
+	case stateAuditing:
+		return style.DocStyle.Render("Auditing vault…")
+	case stateReport:
+		if len(m.report.Items()) == 0 {
+			return style.DocStyle.Render("Vault health report: no issues found (Esc to go back)")
+		}
+		return style.DocStyle.Render(fmt.Sprintf("Vault health report: %d issue(s) (Enter to open item, Esc to go back)", len(m.report.Items())) + "\n\n" + m.report.View())
 	case stateDone:
 		return style.DocStyle.Render("")
 	default:
@@ -429,6 +610,28 @@ func (m model) View() string {
 	}
 }
 
+// Pick runs the TUI as an item picker on opts.TTY (default /dev/tty) and
+// returns the ID of the chosen item, or "" if the user quit. Standard input
+// and output are left alone, so callers can speak a protocol over them.
+func Pick(manager bwpkg.Manager, cfg *cfgpkg.Config, opts Options) (string, error) {
+	path := opts.TTY
+	if path == "" {
+		path = "/dev/tty"
+	}
+	tty, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+	opts.Pick = true
+	p := tea.NewProgram(InitialModel(manager, cfg, opts), tea.WithInput(tty), tea.WithOutput(tty))
+	final, err := p.Run()
+	if err != nil {
+		return "", err
+	}
+	return final.(model).picked, nil
+}
+
 // Commands and helpers
 
 func checkLoginCmd(mgr bwpkg.Manager) tea.Cmd {
@@ -447,7 +650,7 @@ func unlockCmd(mgr bwpkg.Manager, password string) tea.Cmd {
 
 func loadItemsCmd(mgr bwpkg.Manager) tea.Cmd {
 	return func() tea.Msg {
-		raw, err := mgr.GetItems()
+		raw, err := bwpkg.ListItems(mgr, "")
 		if err != nil {
 			return itemsLoadedMsg{nil, err}
 		}
@@ -459,6 +662,72 @@ func loadItemsCmd(mgr bwpkg.Manager) tea.Cmd {
 	}
 }
 
+func auditCmd(mgr bwpkg.Manager, cfg *cfgpkg.Config) tea.Cmd {
+	return func() tea.Msg {
+		opts := audit.Options{MaxAge: time.Duration(cfg.AuditMaxAgeDays) * 24 * time.Hour}
+		if cfg.BreachDB != "" {
+			db, err := audit.OpenBreachDB(cfg.BreachDB)
+			if err != nil {
+				return auditResultMsg{err: err}
+			}
+			defer db.Close()
+			opts.Breaches = db
+		}
+		entries, err := audit.Collect(mgr)
+		if err != nil {
+			return auditResultMsg{err: err}
+		}
+		findings, err := audit.Check(entries, opts)
+		return auditResultMsg{findings: findings, err: err}
+	}
+}
+
+// refilter shows the items matching the search query.
+func (m model) refilter() model {
+	q := strings.TrimSpace(m.search.Value())
+	if q == "" {
+		m.visibleItems = m.allItems
+	} else {
+		m.visibleItems = make([]bwListItem, 0, len(m.allItems))
+		for _, it := range m.allItems {
+			if it.item.Matches(q) {
+				m.visibleItems = append(m.visibleItems, it)
+			}
+		}
+	}
+	li := make([]list.Item, len(m.visibleItems))
+	for i := range m.visibleItems {
+		li[i] = m.visibleItems[i]
+	}
+	m.list.SetItems(li)
+	return m
+}
+
+// openActionMenu selects itm and shows its actions, preselecting the first.
+func (m model) openActionMenu(itm bwListItem) model {
+	m.selected = itm
+	m.menuFrom = m.state
+	m.actions.SetItems(m.buildActions())
+	m.actions.Select(0)
+	// size to show exactly all actions (single-line)
+	if m.width > 0 {
+		m.actions.SetSize(m.width, max(1, len(m.actions.Items())))
+	}
+	m.state = stateActionMenu
+	return m
+}
+
+// listItemByID returns the loaded list entry for it, falling back to one
+// built from its metadata when it is not part of the current list.
+func (m model) listItemByID(it bwpkg.Item) bwListItem {
+	for _, li := range m.allItems {
+		if li.id == it.ID {
+			return li
+		}
+	}
+	return bwListItemFromItem(it)
+}
+
 func (m model) buildActions() []list.Item {
 	// Build actions with optional indicator icons appended
 	items := []list.Item{}
@@ -563,97 +832,26 @@ func (m model) copyCmd(kind, id, username string) tea.Cmd {
 	}
 }
 
-func str(v interface{}) string {
-	if v == nil {
-		return ""
-	}
-	switch t := v.(type) {
-	case string:
-		return t
-	default:
-		return fmt.Sprintf("%v", v)
-	}
-}
-
-func getMap(m map[string]interface{}, keys ...string) map[string]interface{} {
-	cur := m
-	for _, k := range keys {
-		v, ok := cur[k]
-		if !ok {
-			return nil
-		}
-		mv, ok := v.(map[string]interface{})
-		if !ok {
-			return nil
-		}
-		cur = mv
-	}
-	return cur
-}
-
-func getStringDeep(m map[string]interface{}, keys ...string) string {
-	if len(keys) == 0 {
-		return ""
-	}
-	// try top-level
-	if v, ok := m[keys[0]]; ok && len(keys) == 1 {
-		return str(v)
-	}
-	// try deep path
-	mm := getMap(m, keys[:len(keys)-1]...)
-	if mm == nil {
-		return ""
-	}
-	return str(mm[keys[len(keys)-1]])
+func bwListItemFromMap(m map[string]interface{}) bwListItem {
+	return bwListItemFromItem(bwpkg.ItemFromMap(m))
 }
 
-func bwListItemFromMap(m map[string]interface{}) bwListItem {
-	id := getStringDeep(m, "id")
-	if id == "" {
-		id = getStringDeep(m, "data", "id")
-	}
-	title := getStringDeep(m, "name")
-	if title == "" {
-		title = getStringDeep(m, "data", "name")
-	}
+func bwListItemFromItem(it bwpkg.Item) bwListItem {
+	title := it.Name
 	if title == "" {
 		title = "(no title)"
 	}
-	username := getStringDeep(m, "login", "username")
-	if username == "" {
-		username = getStringDeep(m, "data", "login", "username")
-	}
-	hasUsername := strings.TrimSpace(username) != ""
-	desc := username
-	hasTotp := false
-	totp := getStringDeep(m, "login", "totp")
-	if totp == "" {
-		totp = getStringDeep(m, "data", "login", "totp")
-	}
-	if strings.TrimSpace(totp) != "" {
-		hasTotp = true
-	}
-	// Extract first URI if present
-	url := firstURIFromItem(m)
-	hasURL := strings.TrimSpace(url) != ""
-
-	// Detect password presence without storing it
-	pw := getStringDeep(m, "login", "password")
-	if pw == "" {
-		pw = getStringDeep(m, "data", "login", "password")
-	}
-	hasPassword := strings.TrimSpace(pw) != ""
-
 	return bwListItem{
-		id:          id,
+		item:        it,
+		id:          it.ID,
 		title:       title,
-		username:    username,
-		desc:        desc,
-		hasTotp:     hasTotp,
-		url:         url,
-		hasURL:      hasURL,
-		hasUsername: hasUsername,
-		hasPassword: hasPassword,
+		username:    it.Username,
+		desc:        it.Username,
+		hasTotp:     it.HasTotp,
+		url:         it.URI,
+		hasURL:      it.URI != "",
+		hasUsername: strings.TrimSpace(it.Username) != "",
+		hasPassword: it.HasPassword,
 	}
 }
 
@@ -664,65 +862,6 @@ func max(a, b int) int {
 	return b
 }
 
-// firstURIFromItem tries to find the first URL/URI from common Bitwarden item shapes.
-func firstURIFromItem(m map[string]interface{}) string {
-	// Try login.uris (array of objects with uri field)
-	if login, ok := m["login"].(map[string]interface{}); ok {
-		if s := firstURIFromLogin(login); s != "" {
-			return s
-		}
-	}
-	// Try data.login.uris
-	if data, ok := m["data"].(map[string]interface{}); ok {
-		if login, ok := data["login"].(map[string]interface{}); ok {
-			if s := firstURIFromLogin(login); s != "" {
-				return s
-			}
-		}
-	}
-	// Fall back to possible direct fields
-	if s := strings.TrimSpace(getStringDeep(m, "login", "uri")); s != "" {
-		return s
-	}
-	if s := strings.TrimSpace(getStringDeep(m, "data", "login", "uri")); s != "" {
-		return s
-	}
-	return ""
-}
-
-func firstURIFromLogin(login map[string]interface{}) string {
-	if v, ok := login["uris"]; ok {
-		switch vv := v.(type) {
-		case []interface{}:
-			for _, it := range vv {
-				// item can be a map with uri key, or a string
-				if mp, ok := it.(map[string]interface{}); ok {
-					s := strings.TrimSpace(str(mp["uri"]))
-					if s != "" {
-						return s
-					}
-				} else if s, ok := it.(string); ok {
-					s = strings.TrimSpace(s)
-					if s != "" {
-						return s
-					}
-				}
-			}
-		case map[string]interface{}:
-			s := strings.TrimSpace(str(vv["uri"]))
-			if s != "" {
-				return s
-			}
-		case string:
-			s := strings.TrimSpace(vv)
-			if s != "" {
-				return s
-			}
-		}
-	}
-	return ""
-}
-
 // isListNavKey returns true if the key should be handled by the list for navigation.
 func isListNavKey(k tea.KeyMsg) bool {
 	switch k.Type {
@@ -0,0 +1,123 @@
+// Package urimatch implements Bitwarden's login URI match detection.
+package urimatch
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Strategy is a Bitwarden URI match type, as stored in login.uris[].match.
+type Strategy int
+
+const (
+	Domain Strategy = iota
+	Host
+	StartsWith
+	Exact
+	RegularExpression
+	Never
+)
+
+// ParseStrategy converts a raw match value from item JSON. A missing (null)
+// value means the default strategy, which is Domain.
+func ParseStrategy(v interface{}) Strategy {
+	switch t := v.(type) {
+	case float64:
+		return Strategy(t)
+	case int:
+		return Strategy(t)
+	}
+	return Domain
+}
+
+// Match reports whether url matches the item URI uri under the strategy s.
+func Match(uri string, s Strategy, rawURL string) bool {
+	uri = strings.TrimSpace(uri)
+	if uri == "" || rawURL == "" {
+		return false
+	}
+	switch s {
+	case Domain:
+		d := BaseDomain(rawURL)
+		return d != "" && d == BaseDomain(uri)
+	case Host:
+		h := HostOf(rawURL)
+		return h != "" && h == HostOf(uri)
+	case StartsWith:
+		return strings.HasPrefix(rawURL, uri)
+	case Exact:
+		return rawURL == uri
+	case RegularExpression:
+		re := compile(uri)
+		return re != nil && re.MatchString(rawURL)
+	}
+	return false
+}
+
+// patterns caches compiled regular expression URIs, nil for invalid ones,
+// since every item is matched again on each filter pass.
+var patterns sync.Map
+
+func compile(uri string) *regexp.Regexp {
+	if re, ok := patterns.Load(uri); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile("(?i)" + uri)
+	if err != nil {
+		re = nil
+	}
+	patterns.Store(uri, re)
+	return re
+}
+
+// HostOf returns the lower-cased host (including any port) of a URI. URIs
+// without a scheme are parsed as http URLs, like Bitwarden does.
+func HostOf(uri string) string {
+	u := parse(uri)
+	if u == nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// BaseDomain returns the registrable domain of a URI, using the public suffix
+// list (e.g. accounts.google.co.uk -> google.co.uk). IP addresses and single
+// label hosts such as localhost are returned as-is.
+func BaseDomain(uri string) string {
+	u := parse(uri)
+	if u == nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return ""
+	}
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return host
+	}
+	d, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return ""
+	}
+	return d
+}
+
+func parse(uri string) *url.URL {
+	uri = strings.TrimSpace(uri)
+	if uri == "" {
+		return nil
+	}
+	if !strings.Contains(uri, "://") {
+		uri = "http://" + uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil
+	}
+	return u
+}
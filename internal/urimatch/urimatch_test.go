@@ -0,0 +1,100 @@
+package urimatch
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		uri   string
+		s     Strategy
+		url   string
+		match bool
+	}{
+		{"domain subdomain", "https://google.co.uk", Domain, "https://accounts.google.co.uk/signin", true},
+		{"domain reverse", "https://accounts.google.co.uk", Domain, "https://mail.google.co.uk", true},
+		{"domain public suffix", "https://google.co.uk", Domain, "https://bbc.co.uk", false},
+		{"domain scheme-less uri", "github.com", Domain, "https://gist.github.com/", true},
+		{"domain other", "https://github.com", Domain, "https://gitlab.com", false},
+		{"domain ip", "http://192.168.1.1", Domain, "http://192.168.1.1:8080/admin", true},
+		{"domain other ip", "http://192.168.1.1", Domain, "http://192.168.1.2", false},
+		{"domain localhost", "localhost:3000", Domain, "http://localhost:8080", true},
+		{"domain empty url", "https://github.com", Domain, "", false},
+		{"domain empty uri", "  ", Domain, "https://github.com", false},
+		{"host", "https://accounts.google.co.uk", Host, "https://accounts.google.co.uk/x", true},
+		{"host subdomain", "https://google.co.uk", Host, "https://accounts.google.co.uk", false},
+		{"host port", "localhost:3000", Host, "http://localhost:3000/", true},
+		{"host other port", "localhost:3000", Host, "http://localhost:8080/", false},
+		{"host ip port", "10.0.0.1:8443", Host, "https://10.0.0.1:8443/login", true},
+		{"host case", "https://GitHub.com", Host, "https://github.com", true},
+		{"starts with", "https://example.com/app", StartsWith, "https://example.com/app/login", true},
+		{"starts with other path", "https://example.com/app", StartsWith, "https://example.com/other", false},
+		{"exact", "https://example.com/login", Exact, "https://example.com/login", true},
+		{"exact query", "https://example.com/login", Exact, "https://example.com/login?next=/", false},
+		{"regex", `^https://(www\.)?example\.com/`, RegularExpression, "https://WWW.example.com/x", true},
+		{"regex no match", `^https://example\.com/$`, RegularExpression, "https://example.com/x", false},
+		{"regex invalid", `(unclosed`, RegularExpression, "https://example.com/(unclosed", false},
+		{"never", "https://example.com", Never, "https://example.com", false},
+		{"unknown strategy", "https://example.com", Strategy(42), "https://example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Twice, the second time from the pattern cache.
+			for range 2 {
+				if got := Match(tt.uri, tt.s, tt.url); got != tt.match {
+					t.Fatalf("Match(%q, %d, %q) = %v, want %v", tt.uri, tt.s, tt.url, got, tt.match)
+				}
+			}
+		})
+	}
+}
+
+func TestBaseDomain(t *testing.T) {
+	tests := map[string]string{
+		"https://accounts.google.co.uk/signin": "google.co.uk",
+		"google.co.uk":                         "google.co.uk",
+		"http://127.0.0.1:8080":                "127.0.0.1",
+		"http://[::1]:8080/":                   "::1",
+		"localhost:3000":                       "localhost",
+		"https://user@sub.example.com":         "example.com",
+		"":                                     "",
+		"https://":                             "",
+	}
+	for uri, want := range tests {
+		if got := BaseDomain(uri); got != want {
+			t.Errorf("BaseDomain(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
+
+func TestHostOf(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.com:8443/x": "example.com:8443",
+		"example.com":                "example.com",
+		"localhost:3000":             "localhost:3000",
+		"":                           "",
+	}
+	for uri, want := range tests {
+		if got := HostOf(uri); got != want {
+			t.Errorf("HostOf(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want Strategy
+	}{
+		{nil, Domain},
+		{float64(0), Domain},
+		{float64(1), Host},
+		{float64(4), RegularExpression},
+		{5, Never},
+		{"3", Domain},
+	}
+	for _, tt := range tests {
+		if got := ParseStrategy(tt.v); got != tt.want {
+			t.Errorf("ParseStrategy(%#v) = %d, want %d", tt.v, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package util
 
 import (
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 )
 
 // GetConfigDir returns ~/.config/mnu, creating it if necessary.
@@ -19,6 +23,21 @@ func GetConfigDir() (string, error) {
 	return configDir, nil
 }
 
+// GetRuntimeDir returns $XDG_RUNTIME_DIR/mnu for sockets and other per-login
+// state, creating it if necessary. Without XDG_RUNTIME_DIR it falls back to
+// the config directory.
+func GetRuntimeDir() (string, error) {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		return GetConfigDir()
+	}
+	runtimeDir := filepath.Join(base, "mnu")
+	if err := os.MkdirAll(runtimeDir, 0700); err != nil {
+		return "", err
+	}
+	return runtimeDir, nil
+}
+
 // AcquireAppLock attempts to acquire an exclusive, non-blocking lock on a lock file
 // in the mnu config directory. It returns the open file handle which must be kept
 // open for the lifetime of the process to hold the lock. Call ReleaseAppLock to unlock.
@@ -64,3 +83,28 @@ func ReleaseAppLock(f *os.File) error {
 	_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
 	return f.Close()
 }
+
+// umaskMu serializes ListenPrivate, as the umask is process-wide.
+var umaskMu sync.Mutex
+
+// ListenPrivate listens on a Unix socket that only the current user can
+// connect to, replacing a stale one. It refuses if the socket still answers,
+// rather than pulling it from under the process serving it. The socket is
+// created under a 0077 umask, so there is no moment in which others could
+// connect to it.
+func ListenPrivate(sock string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", sock, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("already running: %s is in use", sock)
+	}
+	_ = os.Remove(sock)
+	umaskMu.Lock()
+	old := syscall.Umask(0077)
+	ln, err := net.Listen("unix", sock)
+	syscall.Umask(old)
+	umaskMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket: %w", err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListenPrivate(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+
+	// A socket left behind by a process that died is replaced.
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := ListenPrivate(sock)
+	if err != nil {
+		t.Fatalf("ListenPrivate over a stale socket: %v", err)
+	}
+	defer ln.Close()
+	st, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := st.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("socket mode = %v, want no access for group or others", perm)
+	}
+
+	// One that still answers is left alone.
+	if second, err := ListenPrivate(sock); err == nil {
+		second.Close()
+		t.Fatal("ListenPrivate took over a socket in use")
+	} else if !strings.Contains(err.Error(), "already running") {
+		t.Errorf("ListenPrivate on a socket in use: %v, want already running", err)
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("socket in use was removed: %v", err)
+	}
+	conn.Close()
+}